@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const tokenIssuer = "digital-trainer-backend"
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// dummyPasswordHash is a hash of no user's password, at the default cost,
+// for checkPassword to compare against when it has no hash to check
+const dummyPasswordHash = "$2a$10$9uqxLEiy6DWwhPqY4EI6Oejyz5JsFiKH/GkvMnv9xLhEBp28F6MEO"
+
+// checkPassword reports whether password matches passwordHash. without a
+// hash, as for an unknown email, it still pays for a comparison, so that
+// the time taken doesn't tell which emails are registered
+func checkPassword(passwordHash string, password string) bool {
+	if passwordHash == "" {
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+}
+
+// issueAccessToken signs a short-lived access token identifying the user
+func issueAccessToken(config *authConfig, userID string, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(config.accessTokenTTL)
+	claims := jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    tokenIssuer,
+		Subject:   userID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(config.jwtSecret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// parseAccessToken verifies the access token and returns the user it identifies
+func parseAccessToken(config *authConfig, token string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+		}
+		return config.jwtSecret, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if !claims.VerifyIssuer(tokenIssuer, true) {
+		return "", fmt.Errorf("unexpected token issuer: %s", claims.Issuer)
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// newRefreshToken generates an opaque refresh token, returning both the
+// value to hand to the client and the hash to store
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
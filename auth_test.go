@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := hashPassword("hunter22")
+	if err != nil {
+		t.Fatalf("cannot hash password: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		passwordHash string
+		password     string
+		expected     bool
+	}{
+		{"matching password", hash, "hunter22", true},
+		{"wrong password", hash, "hunter23", false},
+		{"no hash, as for an unknown email", "", "hunter22", false},
+		{"no hash and no password", "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := checkPassword(test.passwordHash, test.password); got != test.expected {
+				t.Fatalf("expected %t, got %t", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDummyPasswordHashCost(t *testing.T) {
+	// comparing against the dummy hash must take as long as against a real one
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil {
+		t.Fatalf("invalid dummy password hash: %v", err)
+	}
+	if cost != bcrypt.DefaultCost {
+		t.Fatalf("expected the dummy password hash to have cost %d, got %d", bcrypt.DefaultCost, cost)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// bounds of a plausible body weight in kilograms
+const (
+	minWeightKg = 20
+	maxWeightKg = 500
+)
+
+// compendiumMETs are the metabolic equivalents of common activities from
+// the 2011 Compendium of Physical Activities, keyed by lowercase name. they
+// seed the MET of activities when created, and are kept in step with the
+// seed of migration 0012
+var compendiumMETs = map[string]float64{
+	"walk":              3.5,
+	"walking":           3.5,
+	"hike":              6.0,
+	"hiking":            6.0,
+	"jog":               7.0,
+	"jogging":           7.0,
+	"run":               9.8,
+	"running":           9.8,
+	"bike":              7.5,
+	"biking":            7.5,
+	"cycling":           7.5,
+	"swim":              6.0,
+	"swimming":          6.0,
+	"rowing":            7.0,
+	"elliptical":        5.0,
+	"jump rope":         11.8,
+	"climbing":          8.0,
+	"yoga":              2.5,
+	"pilates":           3.0,
+	"dancing":           5.0,
+	"strength training": 3.5,
+	"weight lifting":    3.5,
+	"weightlifting":     3.5,
+	"basketball":        6.5,
+	"soccer":            7.0,
+	"tennis":            7.3,
+	"golf":              4.8,
+}
+
+// lookupMET returns the compendium MET of an activity named name, or nil
+// if it is not a common one
+func lookupMET(name string) *float64 {
+	met, ok := compendiumMETs[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil
+	}
+	return &met
+}
+
+// estimateCaloriesBurned returns the kilocalories burned over d by someone
+// weighing weightKg, doing an activity of the given MET. one MET is taken
+// to be 1 kcal per kilogram of body weight per hour
+func estimateCaloriesBurned(met float64, weightKg float64, d time.Duration) int {
+	return int(math.Round(met * weightKg * d.Hours()))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateCaloriesBurned(t *testing.T) {
+	tests := []struct {
+		name     string
+		met      float64
+		weightKg float64
+		duration time.Duration
+		expected int
+	}{
+		{"an hour is the MET times the weight", 9.8, 70, time.Hour, 686},
+		{"half an hour is half of it", 9.8, 70, 30 * time.Minute, 343},
+		{"rounded to the nearest kilocalorie", 3.5, 61, 10 * time.Minute, 36},
+		{"no time burns nothing", 7.5, 80, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := estimateCaloriesBurned(test.met, test.weightKg, test.duration)
+			if got != test.expected {
+				t.Fatalf("expected %d kcal, got %d", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestLookupMET(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected *float64
+	}{
+		{"running", floatPointer(9.8)},
+		{"  Weight Lifting ", floatPointer(3.5)},
+		{"Morning run", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := lookupMET(test.name)
+			if (got == nil) != (test.expected == nil) || got != nil && *got != *test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func floatPointer(f float64) *float64 {
+	return &f
+}
+
+func TestCompendiumSeededByMigration(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("cannot load migrations: %v", err)
+	}
+	var seed string
+	for _, m := range migrations {
+		if m.version == 12 {
+			seed = m.up
+		}
+	}
+
+	for name, met := range compendiumMETs {
+		row := fmt.Sprintf("('%s', %s)", name, strconv.FormatFloat(met, 'f', 1, 64))
+		if !strings.Contains(seed, row) {
+			t.Errorf("expected migration 12 to seed %s", row)
+		}
+	}
+}
+
+func TestActivitiesMETSeededFromCompendium(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+
+	rw := s.doJSON("POST", "/v1/activities", tokens.AccessToken, map[string]string{
+		"name": "Running",
+	})
+	expectStatus(t, rw, http.StatusCreated)
+	var activity PostActivitiesResponse
+	decodeBody(t, rw, &activity)
+	if activity.MET == nil || *activity.MET != 9.8 {
+		t.Fatalf("expected the compendium MET of running, got %v", activity.MET)
+	}
+
+	// the seeded MET is the activity's own, and survives a rename
+	rw = s.do("PATCH", "/v1/activities/"+activity.ActivityID, tokens.AccessToken, map[string]string{
+		"Content-Type": "application/merge-patch+json",
+	}, strings.NewReader(`{"name": "Morning run"}`))
+	expectStatus(t, rw, http.StatusNoContent)
+
+	rw = s.do("GET", "/v1/activities/"+activity.ActivityID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	var renamed GetActivitiesResponse
+	decodeBody(t, rw, &renamed)
+	if renamed.MET == nil || *renamed.MET != 9.8 {
+		t.Fatalf("expected the MET to be kept, got %v", renamed.MET)
+	}
+
+	rw = s.doJSON("POST", "/v1/activities", tokens.AccessToken, map[string]string{
+		"name": "Underwater basket weaving",
+	})
+	expectStatus(t, rw, http.StatusCreated)
+	var uncommon PostActivitiesResponse
+	decodeBody(t, rw, &uncommon)
+	if uncommon.MET != nil {
+		t.Fatalf("expected no MET for an uncommon activity, got %v", *uncommon.MET)
+	}
+}
+
+func TestWorkoutsCaloriesReestimated(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+
+	// weighing 80 kg until a week ago, and 60 kg since
+	for _, weight := range []struct {
+		kg  int
+		ago time.Duration
+	}{
+		{80, 60 * 24 * time.Hour},
+		{60, 7 * 24 * time.Hour},
+	} {
+		rw := s.doJSON("POST", "/v1/measurements", tokens.AccessToken, map[string]interface{}{
+			"kind":        "weight",
+			"value":       weight.kg,
+			"unit":        "kg",
+			"measured_at": time.Now().Add(-weight.ago).UTC().Format(time.RFC3339),
+		})
+		expectStatus(t, rw, http.StatusCreated)
+	}
+
+	rw := s.doJSON("POST", "/v1/workouts", tokens.AccessToken, map[string]interface{}{
+		"activity_id": activityID,
+		"timestamp":   time.Now().Add(-30 * 24 * time.Hour).UTC().Format(time.RFC3339),
+		"duration":    int64(time.Hour / time.Millisecond),
+	})
+	expectStatus(t, rw, http.StatusCreated)
+	var workout PostWorkoutsResponse
+	decodeBody(t, rw, &workout)
+	if !workout.CaloriesEstimated || workout.CaloriesBurned != 784 {
+		t.Fatalf("expected 784 estimated kcal, got %+v", workout)
+	}
+
+	tests := []struct {
+		name     string
+		patch    string
+		expected int
+	}{
+		{"duration", `{"duration": 1800000}`, 392},
+		{"timestamp", `{"timestamp": "` + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339) + `"}`, 294},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := s.do("PATCH", "/v1/workouts/"+workout.WorkoutID, tokens.AccessToken, map[string]string{
+				"Content-Type": "application/merge-patch+json",
+			}, strings.NewReader(test.patch))
+			expectStatus(t, rw, http.StatusNoContent)
+
+			rw = s.do("GET", "/v1/workouts/"+workout.WorkoutID, tokens.AccessToken, nil, nil)
+			expectStatus(t, rw, http.StatusOK)
+			var patched GetWorkoutsResponse
+			decodeBody(t, rw, &patched)
+			if !patched.CaloriesEstimated || patched.CaloriesBurned != test.expected {
+				t.Fatalf("expected %d estimated kcal, got %+v", test.expected, patched)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const migrateUsage = "usage: digital_trainer_backend migrate up|down [steps]|status"
+
+func runMigrateCommand(log *logrus.Logger, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+	switch args[0] {
+	case "up", "down", "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q, %s", args[0], migrateUsage)
+	}
+
+	c, err := initConfig(log)
+	if err != nil {
+		return fmt.Errorf("cannot initialize digital trainer config: %w", err)
+	}
+	if c.storage != storagePostgres {
+		return fmt.Errorf("migrations are only supported with %q storage", storagePostgres)
+	}
+
+	db, err := initDatabase(log, c)
+	if err != nil {
+		return fmt.Errorf("cannot initialize digital trainer database: %w", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = migrateUp(ctx, log, db)
+		if err != nil {
+			return err
+		}
+		fmt.Println("database is up to date")
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		err = migrateDown(ctx, log, db, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted up to %d migration(s)\n", steps)
+	case "status":
+		statuses, err := getMigrationStatus(ctx, log, db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.appliedAt != nil {
+				appliedAt = status.appliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.version, status.name, appliedAt)
+		}
+		w.Flush()
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/sirupsen/logrus"
@@ -11,17 +13,45 @@ import (
 type appData struct {
 	config *config
 
-	db *pgxpool.Pool
+	db         *pgxpool.Pool
+	repository repository
+	metrics    *metrics
 }
 
 type config struct {
-	db *dbConfig
+	storage string
+	server  *serverConfig
+	db      *dbConfig
+	auth    *authConfig
+}
+
+type serverConfig struct {
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	shutdownTimeout time.Duration
+	// requestTimeout bounds each request's context, 0 disables it
+	requestTimeout time.Duration
+	// streamTimeout bounds requests to streaming routes in place of
+	// requestTimeout and the read and write timeouts, 0 disables it
+	streamTimeout time.Duration
+	// errorFormat is the format of error responses, the legacy envelope
+	// unless clients have moved on to problem details
+	errorFormat string
 }
 
 type dbConfig struct {
 	host string
 	user string
 	pass string
+
+	autoMigrate bool
+}
+
+type authConfig struct {
+	jwtSecret       []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
 }
 
 func initAppData(log *logrus.Logger) (*appData, error) {
@@ -30,19 +60,22 @@ func initAppData(log *logrus.Logger) (*appData, error) {
 		return nil, fmt.Errorf("cannot initialize digital trainer config: %w", err)
 	}
 
-	db, err := initDatabase(log, c)
+	repository, db, err := initRepository(log, c)
 	if err != nil {
-		return nil, fmt.Errorf("cannot initialize digital trainer database: %w", err)
+		return nil, fmt.Errorf("cannot initialize digital trainer repository: %w", err)
 	}
 
-	err = initDatabaseSchema(log, db)
+	metrics, err := initMetrics(log, db)
 	if err != nil {
-		log.WithError(err).Warnln("cannot initialize digital trainer database schema. this is probably just because it's already established")
+		repository.Close()
+		return nil, fmt.Errorf("cannot initialize digital trainer metrics: %w", err)
 	}
 
 	return &appData{
-		config: c,
-		db:     db,
+		config:     c,
+		db:         db,
+		repository: repository,
+		metrics:    metrics,
 	}, nil
 }
 
@@ -50,12 +83,62 @@ func initConfig(log *logrus.Logger) (*config, error) {
 	viper.SetEnvPrefix("DTB")
 	viper.AutomaticEnv()
 
+	viper.SetDefault("storage", storagePostgres)
+	viper.SetDefault("http_read_timeout", 15*time.Second)
+	viper.SetDefault("http_write_timeout", 30*time.Second)
+	viper.SetDefault("http_idle_timeout", 60*time.Second)
+	viper.SetDefault("shutdown_timeout", 20*time.Second)
+	viper.SetDefault("request_timeout", 10*time.Second)
+	viper.SetDefault("stream_timeout", 10*time.Minute)
+	viper.SetDefault("error_format", errorFormatLegacy)
+	viper.SetDefault("db_auto_migrate", true)
+	viper.SetDefault("access_token_ttl", 15*time.Minute)
+	viper.SetDefault("refresh_token_ttl", 30*24*time.Hour)
+
 	config := &config{
+		storage: strings.ToLower(viper.GetString("storage")),
+		server: &serverConfig{
+			readTimeout:     viper.GetDuration("http_read_timeout"),
+			writeTimeout:    viper.GetDuration("http_write_timeout"),
+			idleTimeout:     viper.GetDuration("http_idle_timeout"),
+			shutdownTimeout: viper.GetDuration("shutdown_timeout"),
+			requestTimeout:  viper.GetDuration("request_timeout"),
+			streamTimeout:   viper.GetDuration("stream_timeout"),
+			errorFormat:     strings.ToLower(viper.GetString("error_format")),
+		},
 		db: &dbConfig{
 			host: viper.GetString("db_host"),
 			user: viper.GetString("db_user"),
 			pass: viper.GetString("db_pass"),
+
+			autoMigrate: viper.GetBool("db_auto_migrate"),
 		},
+		auth: &authConfig{
+			jwtSecret:       []byte(viper.GetString("jwt_secret")),
+			accessTokenTTL:  viper.GetDuration("access_token_ttl"),
+			refreshTokenTTL: viper.GetDuration("refresh_token_ttl"),
+		},
+	}
+
+	switch config.storage {
+	case storagePostgres, storageMemory:
+	default:
+		return nil, fmt.Errorf("invalid storage type %q, must be one of %q or %q", config.storage, storagePostgres, storageMemory)
+	}
+
+	switch config.server.errorFormat {
+	case errorFormatProblem, errorFormatLegacy:
+	default:
+		return nil, fmt.Errorf("invalid error format %q, must be one of %q or %q", config.server.errorFormat, errorFormatProblem, errorFormatLegacy)
+	}
+
+	// a secret of its own would invalidate tokens on every restart, and
+	// between replicas
+	if len(config.auth.jwtSecret) == 0 {
+		return nil, fmt.Errorf("no jwt secret configured")
+	}
+	if config.auth.accessTokenTTL <= 0 || config.auth.refreshTokenTTL <= 0 {
+		return nil, fmt.Errorf("token ttls must be positive")
 	}
 
 	return config, nil
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var achievementTitles = map[string]string{
+	achievementFirstWorkout:               "First workout",
+	achievementStreak7Days:                "7-day streak",
+	achievementWorkouts100:                "100 workouts",
+	achievementPersonalBestDuration:       "Personal best duration",
+	achievementPersonalBestCaloriesBurned: "Personal best calories burned",
+}
+
+type GetAchievementsResponse struct {
+	Achievements []GetAchievementsResponseItem `json:"achievements"`
+	Streak       GetAchievementsResponseStreak `json:"streak"`
+}
+
+// GetAchievementsResponseItem holds an earned achievement. the value of a
+// personal best is in milliseconds for duration and kilocalories for
+// calories burned
+type GetAchievementsResponseItem struct {
+	Code       string  `json:"code"`
+	Title      string  `json:"title"`
+	ActivityID *string `json:"activity_id,omitempty"`
+	WorkoutID  string  `json:"workout_id"`
+	Value      *int64  `json:"value,omitempty"`
+	EarnedAt   string  `json:"earned_at"`
+}
+
+// GetAchievementsResponseStreak counts consecutive days with workouts in
+// UTC. the current streak is still alive until the end of today
+type GetAchievementsResponseStreak struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+}
+
+func getAchievementsGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/achievements.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		// get from db
+		achievements, err := getAchievements(r.Context(), log, appData, userID)
+		if err != nil {
+			errorMessage := "error getting achievements from database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		days, err := getWorkoutStats(r.Context(), log, appData, &workoutStatsQuery{
+			userID:   userID,
+			groupBy:  statsGroupByDay,
+			location: time.UTC,
+		})
+		if err != nil {
+			errorMessage := "error getting workout stats from database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		response := GetAchievementsResponse{
+			Achievements: []GetAchievementsResponseItem{},
+		}
+		for _, a := range achievements {
+			response.Achievements = append(response.Achievements, GetAchievementsResponseItem{
+				Code:       a.code,
+				Title:      achievementTitles[a.code],
+				ActivityID: a.activityID,
+				WorkoutID:  a.workoutID,
+				Value:      a.value,
+				EarnedAt:   a.earnedAt.UTC().Format(time.RFC3339),
+			})
+		}
+		response.Streak.Current, response.Streak.Longest = workoutStreaks(days, time.Now())
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// workoutStreaks returns the current and longest runs of consecutive days
+// out of daily stats buckets in chronological order. a run ending yesterday
+// is still current, as there is time left to work out today
+func workoutStreaks(days []*workoutStatsBucket, now time.Time) (int, int) {
+	current, longest := 0, 0
+	var lastDay time.Time
+	for _, b := range days {
+		if current > 0 && b.periodStart.Equal(lastDay.AddDate(0, 0, 1)) {
+			current++
+		} else {
+			current = 1
+		}
+		lastDay = b.periodStart
+		if current > longest {
+			longest = current
+		}
+	}
+
+	today := truncateToPeriod(now, statsGroupByDay, time.UTC)
+	if current > 0 && lastDay.Before(today.AddDate(0, 0, -1)) {
+		current = 0
+	}
+	return current, longest
+}
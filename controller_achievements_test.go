@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAchievementsRecomputed(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+
+	workoutIDs := make(map[string]string)
+	for _, w := range []struct {
+		name     string
+		ago      time.Duration
+		duration time.Duration
+		calories int
+	}{
+		{"oldest", 72 * time.Hour, 30 * time.Minute, 500},
+		{"longest", 48 * time.Hour, 90 * time.Minute, 300},
+		{"latest", 24 * time.Hour, time.Hour, 400},
+	} {
+		rw := s.doJSON("POST", "/v1/workouts", tokens.AccessToken, map[string]interface{}{
+			"activity_id":     activityID,
+			"timestamp":       time.Now().Add(-w.ago).UTC().Format(time.RFC3339),
+			"calories_burned": w.calories,
+			"duration":        int64(w.duration / time.Millisecond),
+		})
+		expectStatus(t, rw, http.StatusCreated)
+		var workout PostWorkoutsResponse
+		decodeBody(t, rw, &workout)
+		workoutIDs[w.name] = workout.WorkoutID
+	}
+
+	// achievements as code:workout, named as above, sorted by code
+	achievements := func() string {
+		t.Helper()
+
+		rw := s.do("GET", "/v1/achievements", tokens.AccessToken, nil, nil)
+		expectStatus(t, rw, http.StatusOK)
+		var response GetAchievementsResponse
+		decodeBody(t, rw, &response)
+
+		names := make(map[string]string)
+		for name, workoutID := range workoutIDs {
+			names[workoutID] = name
+		}
+		var earned []string
+		for _, a := range response.Achievements {
+			earned = append(earned, fmt.Sprintf("%s:%s", a.Code, names[a.WorkoutID]))
+		}
+		sort.Strings(earned)
+		return strings.Join(earned, " ")
+	}
+
+	tests := []struct {
+		name     string
+		deleted  string
+		expected string
+	}{
+		{"earned by the workouts", "", "first_workout:oldest personal_best_calories_burned:oldest personal_best_duration:longest"},
+		{"first workout after deleting it", "oldest", "first_workout:longest personal_best_calories_burned:latest personal_best_duration:longest"},
+		{"personal best after deleting it", "longest", "first_workout:latest personal_best_calories_burned:latest personal_best_duration:latest"},
+		{"none after deleting every workout", "latest", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.deleted != "" {
+				rw := s.do("DELETE", "/v1/workouts/"+workoutIDs[test.deleted], tokens.AccessToken, nil, nil)
+				expectStatus(t, rw, http.StatusNoContent)
+			}
+			if got := achievements(); got != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestWorkoutStreaks(t *testing.T) {
+	now := time.Date(2026, 3, 20, 15, 0, 0, 0, time.UTC)
+	days := func(daysAgo ...int) []*workoutStatsBucket {
+		var buckets []*workoutStatsBucket
+		for _, ago := range daysAgo {
+			buckets = append(buckets, &workoutStatsBucket{
+				periodStart: time.Date(2026, 3, 20-ago, 0, 0, 0, 0, time.UTC),
+				count:       1,
+			})
+		}
+		return buckets
+	}
+
+	tests := []struct {
+		name    string
+		days    []*workoutStatsBucket
+		current int
+		longest int
+	}{
+		{"no workouts", nil, 0, 0},
+		{"worked out today", days(2, 1, 0), 3, 3},
+		{"still current until the end of today", days(3, 2, 1), 3, 3},
+		{"broken by missing yesterday", days(4, 3, 2), 0, 3},
+		{"current shorter than the longest", days(9, 8, 7, 6, 3, 1, 0), 2, 4},
+		{"across months", days(21, 20, 19, 18), 0, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current, longest := workoutStreaks(test.days, now)
+			if current != test.current || longest != test.longest {
+				t.Fatalf("expected current %d and longest %d, got %d and %d", test.current, test.longest, current, longest)
+			}
+		})
+	}
+}
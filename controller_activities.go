@@ -8,33 +8,39 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// GetActivitiesResponse holds an activity, with the MET calories are
+// estimated with
 type GetActivitiesResponse struct {
-	ActivityID string `json:"activity_id"`
-	Name       string `json:"name"`
+	ActivityID string   `json:"activity_id"`
+	Name       string   `json:"name"`
+	MET        *float64 `json:"met,omitempty"`
 }
 
 func getActivitiesGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/activities/{id}.GET",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		activityID := mux.Vars(r)["id"]
 		activity := &activity{
 			activityID: activityID,
+			userID:     userID,
 		}
 
 		// check if row exists
-		err := controllerCheckExists(rw, activity, log, appData)
+		err := controllerCheckExists(r.Context(), rw, activity, log, appData)
 		if err != nil {
 			return
 		}
 
 		// get from db
-		err = controllerDatabaseFunc(rw, activity, activity.Get, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Get, log, appData)
 		if err != nil {
 			return
 		}
@@ -42,6 +48,13 @@ func getActivitiesGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.
 		response := GetActivitiesResponse{
 			ActivityID: activity.activityID,
 			Name:       activity.name,
+			MET:        activity.met,
+		}
+		rw.Header().Set("ETag", formatETag(activity.version))
+		if etagListMatches(r.Header.Get("If-None-Match"), formatETag(activity.version), true) {
+			rw.WriteHeader(http.StatusNotModified)
+			log.Debug("request completed")
+			return
 		}
 		controllerEncodeResponse(rw, log, http.StatusOK, response)
 
@@ -52,21 +65,24 @@ func getActivitiesGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.
 
 type GetAllActivitiesResponse []GetAllActivitiesResponseItem
 type GetAllActivitiesResponseItem struct {
-	ActivityID string `json:"activity_id"`
-	Name       string `json:"name"`
+	ActivityID string   `json:"activity_id"`
+	Name       string   `json:"name"`
+	MET        *float64 `json:"met,omitempty"`
 }
 
 func getActivitiesGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/activities.GET",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		// get from db
-		persistenceObjects, err := controllerDatabaseGetAll(rw, "activity", log, appData)
+		persistenceObjects, err := controllerDatabaseGetAll(r.Context(), rw, "activity", log, appData, userID)
 		if err != nil {
 			return
 		}
@@ -77,6 +93,7 @@ func getActivitiesGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) ht
 			response = append(response, GetAllActivitiesResponseItem{
 				ActivityID: activity.activityID,
 				Name:       activity.name,
+				MET:        activity.met,
 			})
 		}
 
@@ -91,47 +108,57 @@ func getActivitiesGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) ht
 }
 
 type PostActivitiesRequest struct {
-	Name *string `json:"name"`
+	Name *string  `json:"name" validate:"required,notblank"`
+	MET  *float64 `json:"met" validate:"gt=0,max=25"`
 }
 
 type PostActivitiesResponse struct {
-	ActivityID string `json:"activity_id"`
-	Name       string `json:"name"`
+	ActivityID string   `json:"activity_id"`
+	Name       string   `json:"name"`
+	MET        *float64 `json:"met,omitempty"`
 }
 
 func getActivitiesPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/activities.POST",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		var postActivityRequest PostActivitiesRequest
 		err := controllerDecodeRequest(rw, log, r.Body, &postActivityRequest)
 		if err != nil {
 			return
 		}
 
-		err = controllerCheckMissingFields(rw, log, postActivityRequest.Name)
+		err = controllerValidateRequest(rw, log, &postActivityRequest)
 		if err != nil {
 			return
 		}
 
 		activity := &activity{
 			activityID: uuid.NewString(),
+			userID:     userID,
 			name:       *postActivityRequest.Name,
+			met:        postActivityRequest.MET,
+		}
+		// seeded from the compendium, the MET stays with the activity when renamed
+		if activity.met == nil {
+			activity.met = lookupMET(activity.name)
 		}
 
 		// check if row exists
-		// err = controllerCheckExists(rw, activity, log, appData)
+		// err = controllerCheckExists(r.Context(), rw, activity, log, appData)
 		// if err != nil {
 		// 	return
 		// }
 
 		// get from db
-		err = controllerDatabaseFunc(rw, activity, activity.Save, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Save, log, appData)
 		if err != nil {
 			return
 		}
@@ -139,7 +166,9 @@ func getActivitiesPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http
 		response := PostActivitiesResponse{
 			ActivityID: activity.activityID,
 			Name:       activity.name,
+			MET:        activity.met,
 		}
+		rw.Header().Set("ETag", formatETag(activity.version))
 		err = controllerEncodeResponse(rw, log, http.StatusCreated, response)
 		if err != nil {
 			return
@@ -150,48 +179,148 @@ func getActivitiesPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http
 }
 
 type PutActivitiesRequest struct {
-	Name *string `json:"name"`
+	Name *string  `json:"name" validate:"required,notblank"`
+	MET  *float64 `json:"met" validate:"gt=0,max=25"`
 }
 
 func getActivitiesPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/activities/{id}.PUT",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		activityID := mux.Vars(r)["id"]
 		activity := &activity{
 			activityID: activityID,
+			userID:     userID,
 		}
 
 		// check if row exists
-		err := controllerCheckExists(rw, activity, log, appData)
+		err := controllerCheckExists(r.Context(), rw, activity, log, appData)
 		if err != nil {
 			return
 		}
 
+		// check the client has seen the current version, the write checks it again atomically
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Get, log, appData)
+			if err != nil {
+				return
+			}
+			err = controllerCheckIfMatch(rw, log, activity, ifMatch, activity.version)
+			if err != nil {
+				return
+			}
+		}
+
 		var putActivityRequest PutActivitiesRequest
 		err = controllerDecodeRequest(rw, log, r.Body, &putActivityRequest)
 		if err != nil {
 			return
 		}
 
-		err = controllerCheckMissingFields(rw, log, putActivityRequest.Name)
+		err = controllerValidateRequest(rw, log, &putActivityRequest)
 		if err != nil {
 			return
 		}
 
 		activity.name = *putActivityRequest.Name
+		activity.met = putActivityRequest.MET
 
 		// update in db
-		err = controllerDatabaseFunc(rw, activity, activity.Update, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Update, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.Header().Set("ETag", formatETag(activity.version))
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+type PatchActivitiesRequest struct {
+	Name *string  `json:"name" validate:"notblank"`
+	MET  *float64 `json:"met" validate:"gt=0,max=25"`
+}
+
+func getActivitiesPatchHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/activities/{id}.PATCH",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		activityID := mux.Vars(r)["id"]
+		activity := &activity{
+			activityID: activityID,
+			userID:     userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, activity, log, appData)
 		if err != nil {
 			return
 		}
 
+		// get from db, for JSON patch test operations to compare against
+		err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		// check the client has seen the current version, the write checks it again atomically
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			err = controllerCheckIfMatch(rw, log, activity, ifMatch, activity.version)
+			if err != nil {
+				return
+			}
+		}
+
+		var patchActivityRequest PatchActivitiesRequest
+		fields, err := controllerDecodePatch(rw, log, r, GetActivitiesResponse{
+			ActivityID: activity.activityID,
+			Name:       activity.name,
+			MET:        activity.met,
+		}, &patchActivityRequest, "met")
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &patchActivityRequest)
+		if err != nil {
+			return
+		}
+
+		if patchActivityRequest.Name != nil {
+			activity.name = *patchActivityRequest.Name
+		}
+		if containsString(fields, "met") {
+			activity.met = patchActivityRequest.MET
+		}
+
+		// update supplied fields in db, whatever the stored version unless If-Match was given
+		if len(fields) > 0 {
+			if r.Header.Get("If-Match") == "" {
+				activity.version = 0
+			}
+			err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Patch(fields...), log, appData)
+			if err != nil {
+				return
+			}
+		}
+
+		rw.Header().Set("ETag", formatETag(activity.version))
 		rw.WriteHeader(http.StatusNoContent)
 
 		log.Debug("request completed")
@@ -200,26 +329,41 @@ func getActivitiesPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.
 
 func getActivitiesDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/activities/{id}.DELETE",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		activityID := mux.Vars(r)["id"]
 		activity := &activity{
 			activityID: activityID,
+			userID:     userID,
 		}
 
 		// check if row exists
-		err := controllerCheckExists(rw, activity, log, appData)
+		err := controllerCheckExists(r.Context(), rw, activity, log, appData)
 		if err != nil {
 			return
 		}
 
+		// check the client has seen the current version, the write checks it again atomically
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Get, log, appData)
+			if err != nil {
+				return
+			}
+			err = controllerCheckIfMatch(rw, log, activity, ifMatch, activity.version)
+			if err != nil {
+				return
+			}
+		}
+
 		// delete from db
-		err = controllerDatabaseFunc(rw, activity, activity.Delete, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, activity, activity.Delete, log, appData)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+type TokenResponse struct {
+	UserID       string `json:"user_id"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type PostAuthSignupRequest struct {
+	Name     *string `json:"name" validate:"required,notblank"`
+	Email    *string `json:"email" validate:"required,notblank"`
+	Password *string `json:"password" validate:"required,minlen=8"`
+}
+
+func getAuthSignupPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/auth/signup.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		var postSignupRequest PostAuthSignupRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postSignupRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &postSignupRequest)
+		if err != nil {
+			return
+		}
+
+		email := normalizeEmail(*postSignupRequest.Email)
+		existing, err := getUserByEmail(r.Context(), log, appData, email)
+		if err != nil {
+			errorMessage := "error getting user from database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		if existing != nil {
+			errorMessage := "email is already in use"
+			errorStatusCode := http.StatusConflict
+
+			log.Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+			return
+		}
+
+		passwordHash, err := hashPassword(*postSignupRequest.Password)
+		if err != nil {
+			errorMessage := "error hashing password"
+			errorStatusCode := http.StatusInternalServerError
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		user := &user{
+			userID:       uuid.NewString(),
+			name:         *postSignupRequest.Name,
+			email:        email,
+			passwordHash: passwordHash,
+		}
+
+		// save to db
+		err = controllerDatabaseFunc(r.Context(), rw, user, user.Save, log, appData)
+		if err != nil {
+			return
+		}
+
+		response, err := controllerIssueTokens(r.Context(), rw, log, appData, user.userID)
+		if err != nil {
+			return
+		}
+		err = controllerEncodeResponse(rw, log, http.StatusCreated, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type PostAuthLoginRequest struct {
+	Email    *string `json:"email" validate:"required"`
+	Password *string `json:"password" validate:"required"`
+}
+
+func getAuthLoginPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/auth/login.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		var postLoginRequest PostAuthLoginRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postLoginRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &postLoginRequest)
+		if err != nil {
+			return
+		}
+
+		user, err := getUserByEmail(r.Context(), log, appData, normalizeEmail(*postLoginRequest.Email))
+		if err != nil {
+			errorMessage := "error getting user from database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		// an unknown email is checked against no hash, taking as long as a wrong password
+		var passwordHash string
+		if user != nil {
+			passwordHash = user.passwordHash
+		}
+		if !checkPassword(passwordHash, *postLoginRequest.Password) {
+			errorMessage := "invalid email or password"
+			errorStatusCode := http.StatusUnauthorized
+
+			log.Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+			return
+		}
+
+		response, err := controllerIssueTokens(r.Context(), rw, log, appData, user.userID)
+		if err != nil {
+			return
+		}
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type PostAuthRefreshRequest struct {
+	RefreshToken *string `json:"refresh_token" validate:"required"`
+}
+
+func getAuthRefreshPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/auth/refresh.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		var postRefreshRequest PostAuthRefreshRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postRefreshRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &postRefreshRequest)
+		if err != nil {
+			return
+		}
+
+		refreshToken, err := controllerRevokeRefreshToken(r.Context(), rw, log, appData, *postRefreshRequest.RefreshToken)
+		if err != nil {
+			return
+		}
+
+		// rotate, handing out a new refresh token in place of the revoked one
+		response, err := controllerIssueTokens(r.Context(), rw, log, appData, refreshToken.userID)
+		if err != nil {
+			return
+		}
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type PostAuthLogoutRequest struct {
+	RefreshToken *string `json:"refresh_token" validate:"required"`
+}
+
+func getAuthLogoutPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/auth/logout.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		var postLogoutRequest PostAuthLogoutRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postLogoutRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &postLogoutRequest)
+		if err != nil {
+			return
+		}
+
+		_, err = controllerRevokeRefreshToken(r.Context(), rw, log, appData, *postLogoutRequest.RefreshToken)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+// controllerIssueTokens issues a new access token and refresh token pair for the user
+func controllerIssueTokens(ctx context.Context, rw http.ResponseWriter, log *logrus.Entry, appData *appData, userID string) (*TokenResponse, error) {
+	now := time.Now()
+
+	accessToken, accessTokenExpiresAt, err := issueAccessToken(appData.config.auth, userID, now)
+	if err != nil {
+		errorMessage := "error issuing access token"
+		errorStatusCode := http.StatusInternalServerError
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return nil, err
+	}
+
+	token, tokenHash, err := newRefreshToken()
+	if err != nil {
+		errorMessage := "error issuing refresh token"
+		errorStatusCode := http.StatusInternalServerError
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return nil, err
+	}
+
+	refreshToken := &refreshToken{
+		tokenHash: tokenHash,
+		userID:    userID,
+		expiresAt: now.Add(appData.config.auth.refreshTokenTTL),
+	}
+
+	// save to db
+	err = controllerDatabaseFunc(ctx, rw, refreshToken, refreshToken.Save, log, appData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		UserID:       userID,
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenExpiresAt.Sub(now).Seconds()),
+		RefreshToken: token,
+	}, nil
+}
+
+// controllerRevokeRefreshToken revokes the given refresh token, failing if it is unknown or no longer usable
+func controllerRevokeRefreshToken(ctx context.Context, rw http.ResponseWriter, log *logrus.Entry, appData *appData, token string) (*refreshToken, error) {
+	refreshToken := &refreshToken{
+		tokenHash: hashRefreshToken(token),
+	}
+
+	// revoke in db
+	revoked, err := refreshToken.Revoke(ctx, log, appData)
+	if err != nil {
+		errorMessage := "error revoking " + refreshToken.Type() + " in database"
+		errorStatusCode := databaseErrorStatusCode(err)
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return nil, err
+	}
+	if !revoked {
+		errorMessage := "invalid refresh token"
+		errorStatusCode := http.StatusUnauthorized
+
+		log.Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	return refreshToken, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAuthSignupAndLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatalf("expected access and refresh tokens, got %+v", tokens)
+	}
+
+	rw := s.do("GET", "/v1/users/"+tokens.UserID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+
+	// the email is already taken
+	rw = s.doJSON("POST", "/v1/auth/signup", "", map[string]string{
+		"name":     "Someone Else",
+		"email":    "ada@example.com",
+		"password": "hunter22",
+	})
+	expectStatus(t, rw, http.StatusConflict)
+
+	rw = s.doJSON("POST", "/v1/auth/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "hunter22",
+	})
+	expectStatus(t, rw, http.StatusOK)
+
+	var login TokenResponse
+	decodeBody(t, rw, &login)
+	if login.UserID != tokens.UserID {
+		t.Fatalf("expected to log in as %s, got %s", tokens.UserID, login.UserID)
+	}
+
+	rw = s.doJSON("POST", "/v1/auth/login", "", map[string]string{
+		"email":    "ada@example.com",
+		"password": "wrong password",
+	})
+	expectStatus(t, rw, http.StatusUnauthorized)
+}
+
+func TestAuthValidation(t *testing.T) {
+	s := newTestServer(t)
+
+	rw := s.doJSON("POST", "/v1/auth/signup", "", map[string]string{
+		"name":     " ",
+		"password": "short",
+	})
+	expectValidationErrors(t, rw, map[string]string{
+		"name":     "blank",
+		"email":    "required",
+		"password": "too_short",
+	})
+
+	rw = s.doJSON("POST", "/v1/auth/login", "", map[string]string{})
+	expectValidationErrors(t, rw, map[string]string{
+		"email":    "required",
+		"password": "required",
+	})
+
+	rw = s.doJSON("POST", "/v1/auth/refresh", "", map[string]string{})
+	expectValidationErrors(t, rw, map[string]string{
+		"refresh_token": "required",
+	})
+}
+
+func TestAuthRefreshRotation(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("grace@example.com")
+
+	rw := s.doJSON("POST", "/v1/auth/refresh", "", map[string]string{
+		"refresh_token": tokens.RefreshToken,
+	})
+	expectStatus(t, rw, http.StatusOK)
+
+	var refreshed TokenResponse
+	decodeBody(t, rw, &refreshed)
+	if refreshed.RefreshToken == "" || refreshed.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("expected the refresh token to be rotated, got %q", refreshed.RefreshToken)
+	}
+
+	// a refresh token is only usable once
+	rw = s.doJSON("POST", "/v1/auth/refresh", "", map[string]string{
+		"refresh_token": tokens.RefreshToken,
+	})
+	expectStatus(t, rw, http.StatusUnauthorized)
+
+	rw = s.doJSON("POST", "/v1/auth/logout", "", map[string]string{
+		"refresh_token": refreshed.RefreshToken,
+	})
+	expectStatus(t, rw, http.StatusNoContent)
+
+	rw = s.doJSON("POST", "/v1/auth/refresh", "", map[string]string{
+		"refresh_token": refreshed.RefreshToken,
+	})
+	expectStatus(t, rw, http.StatusUnauthorized)
+}
+
+func TestAuthRefreshRotationConcurrent(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("linus@example.com")
+
+	const attempts = 8
+	statusCodes := make(chan int, attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			statusCodes <- s.doJSON("POST", "/v1/auth/refresh", "", map[string]string{
+				"refresh_token": tokens.RefreshToken,
+			}).Code
+		}()
+	}
+
+	refreshed := 0
+	for i := 0; i < attempts; i++ {
+		if <-statusCodes == http.StatusOK {
+			refreshed++
+		}
+	}
+	if refreshed != 1 {
+		t.Fatalf("expected exactly one refresh to succeed, got %d", refreshed)
+	}
+}
+
+func TestAuthRequiresAccessToken(t *testing.T) {
+	s := newTestServer(t)
+
+	rw := s.do("GET", "/v1/workouts", "", nil, nil)
+	expectStatus(t, rw, http.StatusUnauthorized)
+
+	rw = s.do("GET", "/v1/workouts", "not-a-token", nil, nil)
+	expectStatus(t, rw, http.StatusUnauthorized)
+}
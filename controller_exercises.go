@@ -0,0 +1,363 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type GetExercisesResponse struct {
+	ExerciseID string                    `json:"exercise_id"`
+	WorkoutID  string                    `json:"workout_id"`
+	ActivityID string                    `json:"activity_id"`
+	Position   int                       `json:"position"`
+	Notes      string                    `json:"notes"`
+	Sets       []GetExercisesResponseSet `json:"sets"`
+}
+
+type GetExercisesResponseSet struct {
+	Reps   int      `json:"reps"`
+	Weight *float64 `json:"weight,omitempty"`
+	Unit   string   `json:"unit"`
+	RPE    *float64 `json:"rpe,omitempty"`
+	Rest   *int64   `json:"rest,omitempty"`
+}
+
+func newGetExercisesResponse(e *exercise) GetExercisesResponse {
+	response := GetExercisesResponse{
+		ExerciseID: e.exerciseID,
+		WorkoutID:  e.workoutID,
+		ActivityID: e.activityID,
+		Position:   e.position,
+		Notes:      e.notes,
+		Sets:       []GetExercisesResponseSet{},
+	}
+	for _, s := range e.sets {
+		set := GetExercisesResponseSet{
+			Reps:   s.reps,
+			Weight: s.weight,
+			Unit:   s.weightUnit,
+			RPE:    s.rpe,
+		}
+		if s.rest != nil {
+			rest := s.rest.Milliseconds()
+			set.Rest = &rest
+		}
+		response.Sets = append(response.Sets, set)
+	}
+	return response
+}
+
+func getExercisesGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/{id}/exercises/{exercise_id}.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		vars := mux.Vars(r)
+		exercise := &exercise{
+			exerciseID: vars["exercise_id"],
+			workoutID:  vars["id"],
+			userID:     userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, exercise, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		err = controllerDatabaseFunc(r.Context(), rw, exercise, exercise.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, newGetExercisesResponse(exercise))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type GetAllExercisesResponse []GetExercisesResponse
+
+func getExercisesGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/{id}/exercises.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		workout := &workout{
+			workoutID: mux.Vars(r)["id"],
+			userID:    userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, workout, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		exercises, err := getExercises(r.Context(), log, appData, workout)
+		if err != nil {
+			errorMessage := "error getting exercises from database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		response := GetAllExercisesResponse{}
+		for _, exercise := range exercises {
+			response = append(response, newGetExercisesResponse(exercise))
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// ExerciseSetRequest is a single set of an exercise in requests. the
+// weight unit defaults to kg, and the rest is in milliseconds
+type ExerciseSetRequest struct {
+	Reps   *int     `json:"reps" validate:"required,min=0"`
+	Weight *float64 `json:"weight" validate:"min=0"`
+	Unit   *string  `json:"unit" validate:"oneof=kg lb"`
+	RPE    *float64 `json:"rpe" validate:"min=1,max=10"`
+	Rest   *int64   `json:"rest" validate:"min=0"`
+}
+
+// newExerciseSets builds the sets of an exercise request
+func newExerciseSets(requests []ExerciseSetRequest) []exerciseSet {
+	sets := make([]exerciseSet, 0, len(requests))
+	for _, request := range requests {
+		set := exerciseSet{
+			reps:       *request.Reps,
+			weight:     request.Weight,
+			weightUnit: weightUnitKilograms,
+			rpe:        request.RPE,
+		}
+		if request.Unit != nil {
+			set.weightUnit = *request.Unit
+		}
+		if request.Rest != nil {
+			rest := time.Duration(*request.Rest) * time.Millisecond
+			set.rest = &rest
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}
+
+type PostExercisesRequest struct {
+	ActivityID *string              `json:"activity_id" validate:"required,notblank"`
+	Position   *int                 `json:"position" validate:"min=0"`
+	Notes      *string              `json:"notes"`
+	Sets       []ExerciseSetRequest `json:"sets" validate:"maxitems=100"`
+}
+
+func getExercisesPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/{id}/exercises.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		workout := &workout{
+			workoutID: mux.Vars(r)["id"],
+			userID:    userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, workout, log, appData)
+		if err != nil {
+			return
+		}
+
+		var postExerciseRequest PostExercisesRequest
+		err = controllerDecodeRequest(rw, log, r.Body, &postExerciseRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &postExerciseRequest)
+		if err != nil {
+			return
+		}
+
+		exercise := &exercise{
+			exerciseID: uuid.NewString(),
+			workoutID:  workout.workoutID,
+			userID:     userID,
+			activityID: *postExerciseRequest.ActivityID,
+			// appended, unless placed elsewhere
+			position: -1,
+		}
+		if postExerciseRequest.Position != nil {
+			exercise.position = *postExerciseRequest.Position
+		}
+		if postExerciseRequest.Notes != nil {
+			exercise.notes = *postExerciseRequest.Notes
+		}
+		exercise.sets = newExerciseSets(postExerciseRequest.Sets)
+
+		// check referenced activity exists
+		err = controllerCheckExists(r.Context(), rw, &activity{
+			activityID: exercise.activityID,
+			userID:     userID,
+		}, log, appData)
+		if err != nil {
+			return
+		}
+
+		// save to db
+		err = controllerDatabaseFunc(r.Context(), rw, exercise, exercise.Save, log, appData)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusCreated, newGetExercisesResponse(exercise))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type PutExercisesRequest struct {
+	ActivityID *string              `json:"activity_id" validate:"required,notblank"`
+	Position   *int                 `json:"position" validate:"min=0"`
+	Notes      *string              `json:"notes"`
+	Sets       []ExerciseSetRequest `json:"sets" validate:"maxitems=100"`
+}
+
+func getExercisesPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/{id}/exercises/{exercise_id}.PUT",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		vars := mux.Vars(r)
+		exercise := &exercise{
+			exerciseID: vars["exercise_id"],
+			workoutID:  vars["id"],
+			userID:     userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, exercise, log, appData)
+		if err != nil {
+			return
+		}
+
+		var putExerciseRequest PutExercisesRequest
+		err = controllerDecodeRequest(rw, log, r.Body, &putExerciseRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &putExerciseRequest)
+		if err != nil {
+			return
+		}
+
+		exercise.activityID = *putExerciseRequest.ActivityID
+		// kept in place, unless moved
+		exercise.position = -1
+		if putExerciseRequest.Position != nil {
+			exercise.position = *putExerciseRequest.Position
+		}
+		exercise.notes = ""
+		if putExerciseRequest.Notes != nil {
+			exercise.notes = *putExerciseRequest.Notes
+		}
+		exercise.sets = newExerciseSets(putExerciseRequest.Sets)
+
+		// check referenced activity exists
+		err = controllerCheckExists(r.Context(), rw, &activity{
+			activityID: exercise.activityID,
+			userID:     userID,
+		}, log, appData)
+		if err != nil {
+			return
+		}
+
+		// update in db
+		err = controllerDatabaseFunc(r.Context(), rw, exercise, exercise.Update, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+func getExercisesDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/{id}/exercises/{exercise_id}.DELETE",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		vars := mux.Vars(r)
+		exercise := &exercise{
+			exerciseID: vars["exercise_id"],
+			workoutID:  vars["id"],
+			userID:     userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, exercise, log, appData)
+		if err != nil {
+			return
+		}
+
+		// delete from db
+		err = controllerDatabaseFunc(r.Context(), rw, exercise, exercise.Delete, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
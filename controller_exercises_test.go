@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExercisesPositions(t *testing.T) {
+	type operation struct {
+		method   string
+		notes    string
+		position *int
+	}
+	position := func(p int) *int {
+		return &p
+	}
+
+	tests := []struct {
+		name       string
+		operations []operation
+		expected   string
+	}{
+		{"appended by default", nil, "A B C"},
+		{"inserted at a position", []operation{{"POST", "D", position(1)}}, "A D B C"},
+		{"inserted first", []operation{{"POST", "D", position(0)}}, "D A B C"},
+		{"appended past the end", []operation{{"POST", "D", position(10)}}, "A B C D"},
+		{"moved earlier", []operation{{"PUT", "C", position(0)}}, "C A B"},
+		{"moved later", []operation{{"PUT", "A", position(2)}}, "B C A"},
+		{"kept in place past the end", []operation{{"PUT", "A", position(3)}}, "A B C"},
+		{"kept in place without a position", []operation{{"PUT", "B", nil}}, "A B C"},
+		{"deleted", []operation{{"DELETE", "B", nil}}, "A C"},
+		{"deleted then appended", []operation{{"DELETE", "A", nil}, {"POST", "D", nil}}, "B C D"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+
+			tokens := s.signup("ada@example.com")
+			activityID := s.createActivity(tokens.AccessToken)
+			workout := s.createWorkout(tokens.AccessToken, activityID, time.Now().Add(-time.Hour))
+			path := "/v1/workouts/" + workout.WorkoutID + "/exercises"
+
+			ids := make(map[string]string)
+			post := func(notes string, position *int) {
+				request := map[string]interface{}{
+					"activity_id": activityID,
+					"notes":       notes,
+				}
+				if position != nil {
+					request["position"] = *position
+				}
+				rw := s.doJSON("POST", path, tokens.AccessToken, request)
+				expectStatus(t, rw, http.StatusCreated)
+				var exercise GetExercisesResponse
+				decodeBody(t, rw, &exercise)
+				ids[notes] = exercise.ExerciseID
+			}
+			for _, notes := range []string{"A", "B", "C"} {
+				post(notes, nil)
+			}
+
+			for _, o := range test.operations {
+				switch o.method {
+				case "POST":
+					post(o.notes, o.position)
+				case "PUT":
+					request := map[string]interface{}{
+						"activity_id": activityID,
+						"notes":       o.notes,
+					}
+					if o.position != nil {
+						request["position"] = *o.position
+					}
+					rw := s.doJSON("PUT", path+"/"+ids[o.notes], tokens.AccessToken, request)
+					expectStatus(t, rw, http.StatusNoContent)
+				case "DELETE":
+					rw := s.do("DELETE", path+"/"+ids[o.notes], tokens.AccessToken, nil, nil)
+					expectStatus(t, rw, http.StatusNoContent)
+				}
+			}
+
+			rw := s.do("GET", path, tokens.AccessToken, nil, nil)
+			expectStatus(t, rw, http.StatusOK)
+			var exercises GetAllExercisesResponse
+			decodeBody(t, rw, &exercises)
+			var order []string
+			for i, e := range exercises {
+				if e.Position != i {
+					t.Fatalf("expected exercise %s at position %d, got %d", e.Notes, i, e.Position)
+				}
+				order = append(order, e.Notes)
+			}
+			if got := strings.Join(order, " "); got != test.expected {
+				t.Fatalf("expected the exercises %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestExercisesSets(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	workout := s.createWorkout(tokens.AccessToken, activityID, time.Now().Add(-time.Hour))
+	path := "/v1/workouts/" + workout.WorkoutID + "/exercises"
+
+	rw := s.doJSON("POST", path, tokens.AccessToken, map[string]interface{}{
+		"activity_id": activityID,
+		"sets": []map[string]interface{}{
+			{"reps": 5, "weight": 100, "rpe": 8, "rest": 180000},
+			{"reps": 8, "weight": 135, "unit": "lb"},
+			{"reps": 12},
+		},
+	})
+	expectStatus(t, rw, http.StatusCreated)
+	var created GetExercisesResponse
+	decodeBody(t, rw, &created)
+
+	rw = s.do("GET", path+"/"+created.ExerciseID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	var exercise GetExercisesResponse
+	decodeBody(t, rw, &exercise)
+
+	expected := []string{"5 100 kg 8 180000", "8 135 lb - -", "12 - kg - -"}
+	if len(exercise.Sets) != len(expected) {
+		t.Fatalf("expected %d sets, got %+v", len(expected), exercise.Sets)
+	}
+	for i, set := range exercise.Sets {
+		got := fmt.Sprintf("%d %s %s %s %s", set.Reps, formatOptionalTestFloat(set.Weight), set.Unit,
+			formatOptionalTestFloat(set.RPE), formatOptionalTestInt64(set.Rest))
+		if got != expected[i] {
+			t.Fatalf("expected set %d to be %s, got %s", i, expected[i], got)
+		}
+	}
+}
+
+func formatOptionalTestFloat(f *float64) string {
+	if f == nil {
+		return "-"
+	}
+	return fmt.Sprint(*f)
+}
+
+func formatOptionalTestInt64(i *int64) string {
+	if i == nil {
+		return "-"
+	}
+	return fmt.Sprint(*i)
+}
+
+func TestExercisesInvalid(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	workout := s.createWorkout(tokens.AccessToken, activityID, time.Now().Add(-time.Hour))
+	path := "/v1/workouts/" + workout.WorkoutID + "/exercises"
+
+	tests := []struct {
+		name    string
+		request map[string]interface{}
+		errors  map[string]string
+	}{
+		{"without an activity", map[string]interface{}{}, map[string]string{"activity_id": validationCodeRequired}},
+		{"at a negative position", map[string]interface{}{"activity_id": activityID, "position": -1},
+			map[string]string{"position": validationCodeTooSmall}},
+		{"with an invalid set", map[string]interface{}{"activity_id": activityID, "sets": []map[string]interface{}{
+			{"reps": -1, "unit": "stone", "rpe": 11},
+		}}, map[string]string{"sets[0].reps": validationCodeTooSmall, "sets[0].unit": validationCodeInvalidValue,
+			"sets[0].rpe": validationCodeTooLarge}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := s.doJSON("POST", path, tokens.AccessToken, test.request)
+			expectValidationErrors(t, rw, test.errors)
+		})
+	}
+
+	// exercises only exist under their workout and of the user's activities
+	rw := s.doJSON("POST", path, tokens.AccessToken, map[string]interface{}{
+		"activity_id": "00000000-0000-0000-0000-000000000000",
+	})
+	expectStatus(t, rw, http.StatusNotFound)
+
+	rw = s.doJSON("POST", path, tokens.AccessToken, map[string]interface{}{"activity_id": activityID})
+	expectStatus(t, rw, http.StatusCreated)
+	var exercise GetExercisesResponse
+	decodeBody(t, rw, &exercise)
+
+	other := s.createWorkout(tokens.AccessToken, activityID, time.Now().Add(-2*time.Hour))
+	rw = s.do("GET", "/v1/workouts/"+other.WorkoutID+"/exercises/"+exercise.ExerciseID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusNotFound)
+
+	intruder := s.signup("eve@example.com")
+	rw = s.do("GET", path+"/"+exercise.ExerciseID, intruder.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusNotFound)
+}
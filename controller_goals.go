@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type GetGoalsResponse struct {
+	GoalID     string                   `json:"goal_id"`
+	ActivityID *string                  `json:"activity_id,omitempty"`
+	Metric     string                   `json:"metric"`
+	Target     int64                    `json:"target"`
+	Period     string                   `json:"period"`
+	TimeZone   string                   `json:"time_zone"`
+	StartedAt  string                   `json:"started_at"`
+	Progress   GetGoalsResponseProgress `json:"progress"`
+}
+
+// GetGoalsResponseProgress is the progress made in the current period, with
+// expected being where an even pace over the period would be by now
+type GetGoalsResponseProgress struct {
+	PeriodStart string  `json:"period_start"`
+	PeriodEnd   string  `json:"period_end"`
+	Target      int64   `json:"target"`
+	Value       int64   `json:"value"`
+	Expected    int64   `json:"expected"`
+	Percent     float64 `json:"percent"`
+	Status      string  `json:"status"`
+}
+
+func newGetGoalsResponse(g *goal, current *goalPeriod, now time.Time) GetGoalsResponse {
+	return GetGoalsResponse{
+		GoalID:     g.goalID,
+		ActivityID: g.activityID,
+		Metric:     g.metric,
+		Target:     g.target,
+		Period:     g.period,
+		TimeZone:   g.location.String(),
+		StartedAt:  g.startedAt.In(g.location).Format(time.RFC3339),
+		Progress: GetGoalsResponseProgress{
+			PeriodStart: current.periodStart.In(g.location).Format(time.RFC3339),
+			PeriodEnd:   current.periodEnd.In(g.location).Format(time.RFC3339),
+			Target:      current.target,
+			Value:       current.value,
+			Expected:    current.expected(now),
+			Percent:     100 * float64(current.value) / float64(current.target),
+			Status:      current.status(now),
+		},
+	}
+}
+
+// controllerTrackGoal computes the goal's progress in the current period
+// along with its history
+func controllerTrackGoal(ctx context.Context, rw http.ResponseWriter, log *logrus.Entry, appData *appData, g *goal, now time.Time) (*goalPeriod, []*goalPeriod, error) {
+	current, history, err := trackGoal(ctx, log, appData, g, now)
+	if err != nil {
+		errorMessage := "error tracking goal progress in database"
+		errorStatusCode := databaseErrorStatusCode(err)
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return nil, nil, fmt.Errorf("error tracking goal: %w", err)
+	}
+	return current, history, nil
+}
+
+func getGoalsGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/goals/{id}.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		goal := &goal{
+			goalID: mux.Vars(r)["id"],
+			userID: userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, goal, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		err = controllerDatabaseFunc(r.Context(), rw, goal, goal.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		now := time.Now()
+		current, _, err := controllerTrackGoal(r.Context(), rw, log, appData, goal, now)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, newGetGoalsResponse(goal, current, now))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type GetAllGoalsResponse []GetGoalsResponse
+
+func getGoalsGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/goals.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		// get from db
+		persistenceObjects, err := controllerDatabaseGetAll(r.Context(), rw, "goal", log, appData, userID)
+		if err != nil {
+			return
+		}
+
+		now := time.Now()
+		response := GetAllGoalsResponse{}
+		for _, object := range persistenceObjects {
+			goal := object.(*goal)
+			current, _, err := controllerTrackGoal(r.Context(), rw, log, appData, goal, now)
+			if err != nil {
+				return
+			}
+			response = append(response, newGetGoalsResponse(goal, current, now))
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// GoalRequest holds a goal's definition in requests. the target is in
+// kilocalories or milliseconds depending on the metric, and the time zone
+// is an IANA name, UTC unless given
+type GoalRequest struct {
+	ActivityID *string `json:"activity_id" validate:"notblank"`
+	Metric     *string `json:"metric" validate:"required,oneof=calories_burned duration"`
+	Target     *int64  `json:"target" validate:"required,gt=0"`
+	Period     *string `json:"period" validate:"required,oneof=day week month year"`
+	TimeZone   *string `json:"time_zone" validate:"timezone"`
+}
+
+// apply sets the definition of g from the validated request, leaving when
+// it started to the caller
+func (request *GoalRequest) apply(g *goal) error {
+	g.metric = *request.Metric
+	g.target = *request.Target
+	g.period = *request.Period
+
+	g.location = time.UTC
+	if request.TimeZone != nil {
+		location, err := loadTimeZone(*request.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid time_zone: %w", err)
+		}
+		g.location = location
+	}
+
+	g.activityID = request.ActivityID
+	return nil
+}
+
+// controllerApplyGoalRequest validates the request and applies it to g,
+// checking that the activity it is scoped to exists
+func controllerApplyGoalRequest(ctx context.Context, rw http.ResponseWriter, log *logrus.Entry, appData *appData, request *GoalRequest, g *goal) error {
+	err := controllerValidateRequest(rw, log, request)
+	if err != nil {
+		return err
+	}
+
+	err = request.apply(g)
+	if err != nil {
+		errorMessage := "invalid field value"
+		errorStatusCode := http.StatusBadRequest
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return fmt.Errorf("invalid goal: %w", err)
+	}
+
+	// check referenced activity exists
+	if g.activityID != nil {
+		err = controllerCheckExists(ctx, rw, &activity{
+			activityID: *g.activityID,
+			userID:     g.userID,
+		}, log, appData)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getGoalsPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/goals.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		var postGoalRequest GoalRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postGoalRequest)
+		if err != nil {
+			return
+		}
+
+		goal := &goal{
+			goalID: uuid.NewString(),
+			userID: userID,
+		}
+		err = controllerApplyGoalRequest(r.Context(), rw, log, appData, &postGoalRequest, goal)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		goal.startedAt = truncateToPeriod(now, goal.period, goal.location)
+
+		// save to db
+		err = controllerDatabaseFunc(r.Context(), rw, goal, goal.Save, log, appData)
+		if err != nil {
+			return
+		}
+
+		current, _, err := controllerTrackGoal(r.Context(), rw, log, appData, goal, now)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusCreated, newGetGoalsResponse(goal, current, now))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+func getGoalsPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/goals/{id}.PUT",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		goal := &goal{
+			goalID: mux.Vars(r)["id"],
+			userID: userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, goal, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db, recording the periods finished so far with the
+		// previous target
+		err = controllerDatabaseFunc(r.Context(), rw, goal, goal.Get, log, appData)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		_, history, err := controllerTrackGoal(r.Context(), rw, log, appData, goal, now)
+		if err != nil {
+			return
+		}
+		err = recordGoalPeriods(r.Context(), log, appData, goal, history)
+		if err != nil {
+			errorMessage := "error recording goal history in database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		previous := *goal
+
+		var putGoalRequest GoalRequest
+		err = controllerDecodeRequest(rw, log, r.Body, &putGoalRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerApplyGoalRequest(r.Context(), rw, log, appData, &putGoalRequest, goal)
+		if err != nil {
+			return
+		}
+		// the history no longer compares once anything but the target
+		// changes, so the goal starts over from the current period
+		if goal.metric != previous.metric ||
+			goal.period != previous.period ||
+			goal.location.String() != previous.location.String() ||
+			!equalOptionalStrings(goal.activityID, previous.activityID) {
+			goal.startedAt = truncateToPeriod(now, goal.period, goal.location)
+		}
+
+		// update in db
+		err = controllerDatabaseFunc(r.Context(), rw, goal, goal.Update, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+func getGoalsDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/goals/{id}.DELETE",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		goal := &goal{
+			goalID: mux.Vars(r)["id"],
+			userID: userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, goal, log, appData)
+		if err != nil {
+			return
+		}
+
+		// delete from db
+		err = controllerDatabaseFunc(r.Context(), rw, goal, goal.Delete, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+type GetGoalsHistoryResponse []GetGoalsHistoryResponseItem
+
+type GetGoalsHistoryResponseItem struct {
+	PeriodStart string `json:"period_start"`
+	PeriodEnd   string `json:"period_end"`
+	Target      int64  `json:"target"`
+	Value       int64  `json:"value"`
+	Achieved    bool   `json:"achieved"`
+}
+
+func getGoalsHistoryGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/goals/{id}/history.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		goal := &goal{
+			goalID: mux.Vars(r)["id"],
+			userID: userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, goal, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		err = controllerDatabaseFunc(r.Context(), rw, goal, goal.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		_, history, err := controllerTrackGoal(r.Context(), rw, log, appData, goal, time.Now())
+		if err != nil {
+			return
+		}
+
+		response := GetGoalsHistoryResponse{}
+		for _, p := range history {
+			response = append(response, GetGoalsHistoryResponseItem{
+				PeriodStart: p.periodStart.In(goal.location).Format(time.RFC3339),
+				PeriodEnd:   p.periodEnd.In(goal.location).Format(time.RFC3339),
+				Target:      p.target,
+				Value:       p.value,
+				Achieved:    p.value >= p.target,
+			})
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// equalOptionalStrings tells whether a and b are both nil or equal
+func equalOptionalStrings(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
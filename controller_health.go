@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/sirupsen/logrus"
+)
+
+type GetHealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// statuses of health checks. the errors failing a check are only logged,
+// as the endpoints are unauthenticated
+const (
+	healthStatusOK          = "ok"
+	healthStatusNotReady    = "not ready"
+	healthStatusUnreachable = "unreachable"
+	healthStatusUnknown     = "unknown"
+	healthStatusPending     = "migrations are pending"
+)
+
+func getHealthzGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/healthz.GET",
+			"request_id": requestID,
+		})
+		log.Trace("request received")
+
+		// serving this at all means the process is alive
+		response := GetHealthResponse{
+			Status: healthStatusOK,
+		}
+		controllerEncodeResponse(rw, log, http.StatusOK, response)
+
+		log.Trace("request completed")
+	}
+}
+
+func getReadyzGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/readyz.GET",
+			"request_id": requestID,
+		})
+		log.Trace("request received")
+
+		response := GetHealthResponse{
+			Status: healthStatusOK,
+			Checks: map[string]string{},
+		}
+		statusCode := http.StatusOK
+
+		err := appData.repository.Ping(r.Context())
+		if err != nil {
+			log.WithError(err).Warn("repository is not ready")
+			response.Checks["repository"] = healthStatusUnreachable
+			statusCode = http.StatusServiceUnavailable
+		} else {
+			response.Checks["repository"] = healthStatusOK
+		}
+
+		// only postgres has migrations to check
+		if appData.db != nil {
+			pending, err := getPendingMigrationCount(r.Context(), appData.db)
+			switch {
+			case err != nil:
+				log.WithError(err).Warn("cannot check migrations")
+				response.Checks["migrations"] = healthStatusUnknown
+				statusCode = http.StatusServiceUnavailable
+			case pending > 0:
+				log.WithField("pending", pending).Warn("migrations are pending")
+				response.Checks["migrations"] = healthStatusPending
+				statusCode = http.StatusServiceUnavailable
+			default:
+				response.Checks["migrations"] = healthStatusOK
+			}
+		}
+
+		if statusCode != http.StatusOK {
+			response.Status = healthStatusNotReady
+		}
+		controllerEncodeResponse(rw, log, statusCode, response)
+
+		log.Trace("request completed")
+	}
+}
+
+type GetVersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func getVersionGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/version.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		response := GetVersionResponse{
+			Version:   version,
+			Commit:    commit,
+			BuildDate: buildDate,
+			GoVersion: runtime.Version(),
+		}
+		controllerEncodeResponse(rw, log, http.StatusOK, response)
+
+		log.Debug("request completed")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+// unreachableRepository fails every ping
+type unreachableRepository struct {
+	repository
+}
+
+func (unreachableRepository) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestHealthGet(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		unreachable bool
+		statusCode  int
+		status      string
+		checks      map[string]string
+	}{
+		{"alive", "/healthz", false, http.StatusOK, healthStatusOK, nil},
+		{"alive without the repository", "/healthz", true, http.StatusOK, healthStatusOK, nil},
+		{"ready", "/readyz", false, http.StatusOK, healthStatusOK, map[string]string{"repository": healthStatusOK}},
+		{"not ready without the repository", "/readyz", true, http.StatusServiceUnavailable, healthStatusNotReady,
+			map[string]string{"repository": healthStatusUnreachable}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if test.unreachable {
+				s.appData.repository = unreachableRepository{s.appData.repository}
+			}
+
+			// exempt from authentication
+			rw := s.do("GET", test.path, "", nil, nil)
+			expectStatus(t, rw, test.statusCode)
+
+			var response GetHealthResponse
+			decodeBody(t, rw, &response)
+			if response.Status != test.status || len(response.Checks) != len(test.checks) {
+				t.Fatalf("expected status %q with checks %v, got %+v", test.status, test.checks, response)
+			}
+			for check, status := range test.checks {
+				if response.Checks[check] != status {
+					t.Fatalf("expected status %q with checks %v, got %+v", test.status, test.checks, response)
+				}
+			}
+		})
+	}
+}
+
+func TestVersionGet(t *testing.T) {
+	s := newTestServer(t)
+
+	rw := s.do("GET", "/version", "", nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+
+	var response GetVersionResponse
+	decodeBody(t, rw, &response)
+	if response.Version != version || response.Commit != commit || response.BuildDate != buildDate ||
+		response.GoVersion != runtime.Version() {
+		t.Fatalf("expected the build info, got %+v", response)
+	}
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// maxMeasurementsWindow bounds the window of rolling averages in days
+const maxMeasurementsWindow = 365
+
+type GetMeasurementsResponse struct {
+	MeasurementID string  `json:"measurement_id"`
+	Kind          string  `json:"kind"`
+	Value         float64 `json:"value"`
+	Unit          string  `json:"unit"`
+	MeasuredAt    string  `json:"measured_at"`
+}
+
+// newGetMeasurementsResponse gives the value of m in unit, or in the unit
+// of its kind if unit is empty
+func newGetMeasurementsResponse(m *measurement, unit string) GetMeasurementsResponse {
+	kind := measurementKinds[m.kind]
+	if unit == "" {
+		unit = kind.unit
+	}
+	return GetMeasurementsResponse{
+		MeasurementID: m.measurementID,
+		Kind:          m.kind,
+		Value:         kind.fromCanonical(m.value, unit),
+		Unit:          unit,
+		MeasuredAt:    m.measuredAt.Format(time.RFC3339),
+	}
+}
+
+// parseMeasurementUnit returns the unit values are requested in, checking
+// that it is one of the kind's. it is empty if none was requested
+func parseMeasurementUnit(r *http.Request, kind *string) (string, error) {
+	unit := r.URL.Query().Get("unit")
+	if unit == "" {
+		return "", nil
+	}
+	if kind == nil {
+		return "", fmt.Errorf("invalid unit: requires a kind")
+	}
+	if _, ok := measurementKinds[*kind].units[unit]; !ok {
+		return "", fmt.Errorf("invalid unit: must be one of %s", measurementKinds[*kind].unitNames())
+	}
+	return unit, nil
+}
+
+// parseMeasurementQuery builds a query for the requesting user's
+// measurements from the filtering query parameters, along with the window
+// of the rolling averages requested, if any
+func parseMeasurementQuery(r *http.Request, userID string) (*measurementQuery, time.Duration, error) {
+	values := r.URL.Query()
+	query := &measurementQuery{
+		userID: userID,
+	}
+
+	if v := values.Get("kind"); v != "" {
+		if _, ok := measurementKinds[v]; !ok {
+			return nil, 0, fmt.Errorf("invalid kind: must be one of %s", measurementKindNames())
+		}
+		query.kind = &v
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"from", &query.from},
+		{"to", &query.to},
+	} {
+		if v := values.Get(param.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid %s: %w", param.name, err)
+			}
+			*param.dest = &t
+		}
+	}
+
+	var window time.Duration
+	if v := values.Get("window"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 || days > maxMeasurementsWindow {
+			return nil, 0, fmt.Errorf("invalid window: must be between 1 and %d days", maxMeasurementsWindow)
+		}
+		window = time.Duration(days) * 24 * time.Hour
+	}
+	return query, window, nil
+}
+
+// measurementKindNames lists the kinds of measurements for error messages
+func measurementKindNames() string {
+	return fmt.Sprintf("%s, %s, %s, %s or %s",
+		measurementKindWeight, measurementKindBodyFat, measurementKindRestingHeartRate,
+		measurementKindWaist, measurementKindHip)
+}
+
+func getMeasurementsGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/measurements/{id}.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		measurement := &measurement{
+			measurementID: mux.Vars(r)["id"],
+			userID:        userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, measurement, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		err = controllerDatabaseFunc(r.Context(), rw, measurement, measurement.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		unit, err := parseMeasurementUnit(r, &measurement.kind)
+		if err != nil {
+			errorMessage := "invalid query parameters"
+			errorStatusCode := http.StatusBadRequest
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, newGetMeasurementsResponse(measurement, unit))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type GetAllMeasurementsResponse []GetAllMeasurementsResponseItem
+
+// GetAllMeasurementsResponseItem has the rolling average of the value over
+// the requested window, if one was
+type GetAllMeasurementsResponseItem struct {
+	GetMeasurementsResponse
+	RollingAverage *float64 `json:"rolling_average,omitempty"`
+}
+
+func getMeasurementsGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/measurements.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		var unit string
+		query, window, err := parseMeasurementQuery(r, userID)
+		if err == nil {
+			unit, err = parseMeasurementUnit(r, query.kind)
+		}
+		if err != nil {
+			errorMessage := "invalid query parameters"
+			errorStatusCode := http.StatusBadRequest
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		// the averages of the first measurements in range take in the ones
+		// within the window before it
+		from := query.from
+		if window > 0 && from != nil {
+			windowStart := from.Add(-window)
+			query.from = &windowStart
+		}
+
+		// get from db
+		measurements, err := controllerDatabaseQueryMeasurements(r.Context(), rw, query, log, appData)
+		if err != nil {
+			return
+		}
+		var averages []float64
+		if window > 0 {
+			averages = rollingAverages(measurements, window)
+		}
+
+		response := GetAllMeasurementsResponse{}
+		for i, measurement := range measurements {
+			if from != nil && measurement.measuredAt.Before(*from) {
+				continue
+			}
+			item := GetAllMeasurementsResponseItem{
+				GetMeasurementsResponse: newGetMeasurementsResponse(measurement, unit),
+			}
+			if averages != nil {
+				average := measurementKinds[measurement.kind].fromCanonical(averages[i], item.Unit)
+				item.RollingAverage = &average
+			}
+			response = append(response, item)
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// MeasurementRequest holds a measurement in requests. the value is in the
+// unit of its kind unless another of its units is given
+type MeasurementRequest struct {
+	Kind       *string  `json:"kind" validate:"required,oneof=weight body_fat resting_heart_rate waist hip"`
+	Value      *float64 `json:"value" validate:"required"`
+	Unit       *string  `json:"unit" validate:"notblank"`
+	MeasuredAt *string  `json:"measured_at" validate:"required,rfc3339"`
+}
+
+// apply sets m from the validated request, converting the value to the
+// unit of its kind. it returns validationErrors if the unit is not one of
+// the kind's or the value is implausible
+func (request *MeasurementRequest) apply(m *measurement) error {
+	kind := measurementKinds[*request.Kind]
+	unit := kind.unit
+	if request.Unit != nil {
+		unit = *request.Unit
+	}
+	if _, ok := kind.units[unit]; !ok {
+		return validationErrors{{
+			Field:   "unit",
+			Code:    validationCodeInvalidValue,
+			Message: "must be one of " + kind.unitNames(),
+		}}
+	}
+	value := kind.toCanonical(*request.Value, unit)
+	if !kind.plausible(value) {
+		return validationErrors{{
+			Field:   "value",
+			Code:    validationCodeInvalidValue,
+			Message: fmt.Sprintf("must be between %g and %g %s", kind.min, kind.max, kind.unit),
+		}}
+	}
+
+	measuredAt, err := time.Parse(time.RFC3339, *request.MeasuredAt)
+	if err != nil {
+		return fmt.Errorf("invalid measured_at: %w", err)
+	}
+
+	m.kind = *request.Kind
+	m.value = value
+	m.measuredAt = measuredAt
+	return nil
+}
+
+// controllerApplyMeasurementRequest validates the request and applies it
+// to m
+func controllerApplyMeasurementRequest(rw http.ResponseWriter, log *logrus.Entry, request *MeasurementRequest, m *measurement) error {
+	err := controllerValidateRequest(rw, log, request)
+	if err != nil {
+		return err
+	}
+
+	err = request.apply(m)
+	var errs validationErrors
+	if errors.As(err, &errs) {
+		writeValidationErrorResponse(rw, log, errs)
+		return fmt.Errorf("invalid measurement: %w", err)
+	}
+	if err != nil {
+		errorMessage := "invalid field value"
+		errorStatusCode := http.StatusBadRequest
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return fmt.Errorf("invalid measurement: %w", err)
+	}
+	return nil
+}
+
+func getMeasurementsPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/measurements.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		var postMeasurementRequest MeasurementRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postMeasurementRequest)
+		if err != nil {
+			return
+		}
+
+		measurement := &measurement{
+			measurementID: uuid.NewString(),
+			userID:        userID,
+		}
+		err = controllerApplyMeasurementRequest(rw, log, &postMeasurementRequest, measurement)
+		if err != nil {
+			return
+		}
+
+		// save to db
+		err = controllerDatabaseFunc(r.Context(), rw, measurement, measurement.Save, log, appData)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusCreated, newGetMeasurementsResponse(measurement, ""))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+func getMeasurementsPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/measurements/{id}.PUT",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		measurement := &measurement{
+			measurementID: mux.Vars(r)["id"],
+			userID:        userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, measurement, log, appData)
+		if err != nil {
+			return
+		}
+
+		var putMeasurementRequest MeasurementRequest
+		err = controllerDecodeRequest(rw, log, r.Body, &putMeasurementRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerApplyMeasurementRequest(rw, log, &putMeasurementRequest, measurement)
+		if err != nil {
+			return
+		}
+
+		// update in db
+		err = controllerDatabaseFunc(r.Context(), rw, measurement, measurement.Update, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+func getMeasurementsDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/measurements/{id}.DELETE",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		measurement := &measurement{
+			measurementID: mux.Vars(r)["id"],
+			userID:        userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, measurement, log, appData)
+		if err != nil {
+			return
+		}
+
+		// delete from db
+		err = controllerDatabaseFunc(r.Context(), rw, measurement, measurement.Delete, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeasurementsUnits(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	start := time.Now().UTC().Add(-72 * time.Hour).Truncate(time.Second)
+	for i, m := range []struct {
+		value float64
+		unit  string
+	}{
+		{80, ""},
+		{180, "lb"},
+		{78, "kg"},
+	} {
+		request := map[string]interface{}{
+			"kind":        measurementKindWeight,
+			"value":       m.value,
+			"measured_at": start.Add(time.Duration(i) * 24 * time.Hour).Format(time.RFC3339),
+		}
+		if m.unit != "" {
+			request["unit"] = m.unit
+		}
+		rw := s.doJSON("POST", "/v1/measurements", tokens.AccessToken, request)
+		expectStatus(t, rw, http.StatusCreated)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"in the unit of the kind", "?kind=weight", "80 kg, 81.65 kg, 78 kg"},
+		{"in pounds", "?kind=weight&unit=lb", "176.37 lb, 180 lb, 171.96 lb"},
+		{"with rolling averages", "?kind=weight&window=7", "80 kg 80, 81.65 kg 80.82, 78 kg 79.88"},
+		{"with rolling averages in pounds", "?kind=weight&unit=lb&window=7", "176.37 lb 176.37, 180 lb 178.18, 171.96 lb 176.11"},
+		{"with rolling averages from earlier measurements", "?kind=weight&window=7&from=" + start.Add(36*time.Hour).Format(time.RFC3339),
+			"78 kg 79.88"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := s.do("GET", "/v1/measurements"+test.query, tokens.AccessToken, nil, nil)
+			expectStatus(t, rw, http.StatusOK)
+			var measurements GetAllMeasurementsResponse
+			decodeBody(t, rw, &measurements)
+
+			var values []string
+			for _, m := range measurements {
+				value := fmt.Sprintf("%g %s", m.Value, m.Unit)
+				if m.RollingAverage != nil {
+					value += fmt.Sprintf(" %g", *m.RollingAverage)
+				}
+				values = append(values, value)
+			}
+			if got := strings.Join(values, ", "); got != test.expected {
+				t.Fatalf("expected the measurements %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestMeasurementsInvalid(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	measuredAt := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name    string
+		request map[string]interface{}
+		errors  map[string]string
+	}{
+		{"without a kind", map[string]interface{}{"value": 80, "measured_at": measuredAt},
+			map[string]string{"kind": validationCodeRequired}},
+		{"of an unknown kind", map[string]interface{}{"kind": "height", "value": 180, "measured_at": measuredAt},
+			map[string]string{"kind": validationCodeInvalidValue}},
+		{"in a unit of another kind", map[string]interface{}{"kind": measurementKindWeight, "value": 80, "unit": "cm", "measured_at": measuredAt},
+			map[string]string{"unit": validationCodeInvalidValue}},
+		{"implausible once converted", map[string]interface{}{"kind": measurementKindWeight, "value": 40, "unit": "lb", "measured_at": measuredAt},
+			map[string]string{"value": validationCodeInvalidValue}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := s.doJSON("POST", "/v1/measurements", tokens.AccessToken, test.request)
+			expectValidationErrors(t, rw, test.errors)
+		})
+	}
+
+	for _, query := range []string{"?unit=lb", "?kind=weight&unit=cm", "?kind=height", "?kind=weight&window=0", "?window=366"} {
+		t.Run("listing "+query, func(t *testing.T) {
+			rw := s.do("GET", "/v1/measurements"+query, tokens.AccessToken, nil, nil)
+			expectStatus(t, rw, http.StatusBadRequest)
+		})
+	}
+}
@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dateFormat is how calendar days are written in requests and responses
+	dateFormat = "2006-01-02"
+
+	// maxPlanDays bounds the date range a plan can be requested for
+	maxPlanDays = 366
+)
+
+type GetProgramsResponse struct {
+	ProgramID string                       `json:"program_id"`
+	Name      string                       `json:"name"`
+	StartDate string                       `json:"start_date"`
+	TimeZone  string                       `json:"time_zone"`
+	Weeks     int                          `json:"weeks"`
+	Sessions  []GetProgramsResponseSession `json:"sessions"`
+}
+
+type GetProgramsResponseSession struct {
+	SessionID  string  `json:"session_id"`
+	TemplateID string  `json:"template_id"`
+	Week       int     `json:"week"`
+	Day        int     `json:"day"`
+	Date       string  `json:"date"`
+	Completed  bool    `json:"completed"`
+	WorkoutID  *string `json:"workout_id,omitempty"`
+}
+
+func newGetProgramsResponse(p *program) GetProgramsResponse {
+	response := GetProgramsResponse{
+		ProgramID: p.programID,
+		Name:      p.name,
+		StartDate: p.startDate.Format(dateFormat),
+		TimeZone:  p.location.String(),
+		Sessions:  []GetProgramsResponseSession{},
+	}
+	for i := range p.sessions {
+		s := &p.sessions[i]
+		if s.week > response.Weeks {
+			response.Weeks = s.week
+		}
+		response.Sessions = append(response.Sessions, GetProgramsResponseSession{
+			SessionID:  s.sessionID,
+			TemplateID: s.templateID,
+			Week:       s.week,
+			Day:        s.day,
+			Date:       p.date(s).Format(dateFormat),
+			Completed:  s.workoutID != nil,
+			WorkoutID:  s.workoutID,
+		})
+	}
+	return response
+}
+
+func getProgramsGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/programs/{id}.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		program := &program{
+			programID: mux.Vars(r)["id"],
+			userID:    userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, program, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		err = controllerDatabaseFunc(r.Context(), rw, program, program.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, newGetProgramsResponse(program))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type GetAllProgramsResponse []GetProgramsResponse
+
+func getProgramsGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/programs.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		// get from db
+		persistenceObjects, err := controllerDatabaseGetAll(r.Context(), rw, "program", log, appData, userID)
+		if err != nil {
+			return
+		}
+
+		response := GetAllProgramsResponse{}
+		for _, object := range persistenceObjects {
+			response = append(response, newGetProgramsResponse(object.(*program)))
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// ProgramRequest holds a program's schedule in requests. the time zone is
+// an IANA name, UTC unless given
+type ProgramRequest struct {
+	Name      *string                 `json:"name" validate:"required,notblank"`
+	StartDate *string                 `json:"start_date" validate:"required,date"`
+	TimeZone  *string                 `json:"time_zone" validate:"timezone"`
+	Sessions  []ProgramRequestSession `json:"sessions" validate:"maxitems=1000"`
+}
+
+type ProgramRequestSession struct {
+	TemplateID *string `json:"template_id" validate:"required,notblank"`
+	Week       *int    `json:"week" validate:"required,min=1,max=104"`
+	Day        *int    `json:"day" validate:"required,min=1,max=7"`
+}
+
+// apply sets the whole schedule of p from the validated request, giving
+// every session a new id. templateIDs are the ids of the user's templates,
+// and sessions of any other template are returned as validationErrors
+func (request *ProgramRequest) apply(p *program, templateIDs map[string]bool) error {
+	p.name = *request.Name
+
+	startDate, err := time.Parse(dateFormat, *request.StartDate)
+	if err != nil {
+		return fmt.Errorf("invalid start_date: must be formatted as %s", dateFormat)
+	}
+	p.startDate = startDate
+
+	p.location = time.UTC
+	if request.TimeZone != nil {
+		location, err := loadTimeZone(*request.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid time_zone: %w", err)
+		}
+		p.location = location
+	}
+
+	var errs validationErrors
+	p.sessions = make([]programSession, 0, len(request.Sessions))
+	for i, s := range request.Sessions {
+		if !templateIDs[*s.TemplateID] {
+			errs = append(errs, ValidationError{
+				Field:   fmt.Sprintf("sessions[%d].template_id", i),
+				Code:    validationCodeNotFound,
+				Message: "workout template does not exist",
+			})
+			continue
+		}
+		p.sessions = append(p.sessions, programSession{
+			sessionID:  uuid.NewString(),
+			templateID: *s.TemplateID,
+			week:       *s.Week,
+			day:        *s.Day,
+		})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	sort.SliceStable(p.sessions, func(i, j int) bool {
+		if p.sessions[i].week != p.sessions[j].week {
+			return p.sessions[i].week < p.sessions[j].week
+		}
+		return p.sessions[i].day < p.sessions[j].day
+	})
+	return nil
+}
+
+// controllerApplyProgramRequest validates the request and applies it to p,
+// checking the sessions against the user's templates
+func controllerApplyProgramRequest(ctx context.Context, rw http.ResponseWriter, log *logrus.Entry, appData *appData, request *ProgramRequest, p *program) error {
+	err := controllerValidateRequest(rw, log, request)
+	if err != nil {
+		return err
+	}
+
+	templates, err := controllerDatabaseGetAll(ctx, rw, "workout template", log, appData, p.userID)
+	if err != nil {
+		return err
+	}
+	templateIDs := make(map[string]bool)
+	for _, t := range templates {
+		templateIDs[t.(*workoutTemplate).templateID] = true
+	}
+
+	err = request.apply(p, templateIDs)
+	var errs validationErrors
+	if errors.As(err, &errs) {
+		writeValidationErrorResponse(rw, log, errs)
+		return fmt.Errorf("invalid program: %w", err)
+	}
+	if err != nil {
+		errorMessage := "invalid field value"
+		errorStatusCode := http.StatusBadRequest
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return fmt.Errorf("invalid program: %w", err)
+	}
+	return nil
+}
+
+func getProgramsPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/programs.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		var postProgramRequest ProgramRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postProgramRequest)
+		if err != nil {
+			return
+		}
+
+		program := &program{
+			programID: uuid.NewString(),
+			userID:    userID,
+		}
+		err = controllerApplyProgramRequest(r.Context(), rw, log, appData, &postProgramRequest, program)
+		if err != nil {
+			return
+		}
+
+		// save to db
+		err = controllerDatabaseFunc(r.Context(), rw, program, program.Save, log, appData)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusCreated, newGetProgramsResponse(program))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+func getProgramsPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/programs/{id}.PUT",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		program := &program{
+			programID: mux.Vars(r)["id"],
+			userID:    userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, program, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db, to carry completed sessions over to the new schedule
+		err = controllerDatabaseFunc(r.Context(), rw, program, program.Get, log, appData)
+		if err != nil {
+			return
+		}
+		previousSessions := program.sessions
+
+		var putProgramRequest ProgramRequest
+		err = controllerDecodeRequest(rw, log, r.Body, &putProgramRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerApplyProgramRequest(r.Context(), rw, log, appData, &putProgramRequest, program)
+		if err != nil {
+			return
+		}
+		carryOverProgramSessions(program.sessions, previousSessions)
+
+		// update in db
+		err = controllerDatabaseFunc(r.Context(), rw, program, program.Update, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+// carryOverProgramSessions keeps the id and completion of every previous
+// session that is still scheduled for the same week and day with the same
+// template, matching each previous session at most once
+func carryOverProgramSessions(sessions []programSession, previous []programSession) {
+	type sessionKey struct {
+		templateID string
+		week       int
+		day        int
+	}
+	previousByKey := make(map[sessionKey][]programSession)
+	for _, s := range previous {
+		key := sessionKey{s.templateID, s.week, s.day}
+		previousByKey[key] = append(previousByKey[key], s)
+	}
+	for i := range sessions {
+		key := sessionKey{sessions[i].templateID, sessions[i].week, sessions[i].day}
+		if matches := previousByKey[key]; len(matches) > 0 {
+			sessions[i].sessionID = matches[0].sessionID
+			sessions[i].workoutID = matches[0].workoutID
+			previousByKey[key] = matches[1:]
+		}
+	}
+}
+
+func getProgramsDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/programs/{id}.DELETE",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		program := &program{
+			programID: mux.Vars(r)["id"],
+			userID:    userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, program, log, appData)
+		if err != nil {
+			return
+		}
+
+		// delete from db
+		err = controllerDatabaseFunc(r.Context(), rw, program, program.Delete, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+// controllerCompleteProgramSession marks the planned session a newly
+// created workout fulfils as completed, returning its id if there was one.
+// the workout is saved by then, so failing to do so is only logged
+func controllerCompleteProgramSession(ctx context.Context, log *logrus.Entry, appData *appData, w *workout) *string {
+	sessionID, err := completeProgramSession(ctx, log, appData, w)
+	if err != nil {
+		log.WithError(err).Warn("error completing program session")
+		return nil
+	}
+	if sessionID == "" {
+		return nil
+	}
+	log.WithField("session_id", sessionID).Debug("program session completed")
+	return &sessionID
+}
+
+type GetPlanResponse struct {
+	From     string                   `json:"from"`
+	To       string                   `json:"to"`
+	Sessions []GetPlanResponseSession `json:"sessions"`
+}
+
+type GetPlanResponseSession struct {
+	Date                 string  `json:"date"`
+	ProgramID            string  `json:"program_id"`
+	ProgramName          string  `json:"program_name"`
+	SessionID            string  `json:"session_id"`
+	TemplateID           string  `json:"template_id"`
+	TemplateName         string  `json:"template_name"`
+	ActivityID           string  `json:"activity_id"`
+	TargetDuration       *int64  `json:"target_duration,omitempty"`
+	TargetCaloriesBurned *int    `json:"target_calories_burned,omitempty"`
+	TargetSets           *int    `json:"target_sets,omitempty"`
+	Completed            bool    `json:"completed"`
+	WorkoutID            *string `json:"workout_id,omitempty"`
+}
+
+func getPlanGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/plan.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		from, to, err := parsePlanRange(r)
+		if err != nil {
+			errorMessage := "invalid query parameters"
+			errorStatusCode := http.StatusBadRequest
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		// get from db
+		programs, err := controllerDatabaseGetAll(r.Context(), rw, "program", log, appData, userID)
+		if err != nil {
+			return
+		}
+		templates, err := controllerDatabaseGetAll(r.Context(), rw, "workout template", log, appData, userID)
+		if err != nil {
+			return
+		}
+		templatesByID := make(map[string]*workoutTemplate)
+		for _, t := range templates {
+			templatesByID[t.(*workoutTemplate).templateID] = t.(*workoutTemplate)
+		}
+
+		response := GetPlanResponse{
+			From:     from.Format(dateFormat),
+			To:       to.Format(dateFormat),
+			Sessions: []GetPlanResponseSession{},
+		}
+		for _, object := range programs {
+			p := object.(*program)
+			for i := range p.sessions {
+				s := &p.sessions[i]
+				date := p.date(s)
+				if date.Before(from) || date.After(to) {
+					continue
+				}
+				t, ok := templatesByID[s.templateID]
+				if !ok {
+					continue
+				}
+				template := newGetWorkoutTemplatesResponse(t)
+				response.Sessions = append(response.Sessions, GetPlanResponseSession{
+					Date:                 date.Format(dateFormat),
+					ProgramID:            p.programID,
+					ProgramName:          p.name,
+					SessionID:            s.sessionID,
+					TemplateID:           template.TemplateID,
+					TemplateName:         template.Name,
+					ActivityID:           template.ActivityID,
+					TargetDuration:       template.TargetDuration,
+					TargetCaloriesBurned: template.TargetCaloriesBurned,
+					TargetSets:           template.TargetSets,
+					Completed:            s.workoutID != nil,
+					WorkoutID:            s.workoutID,
+				})
+			}
+		}
+		// dates are formatted to sort chronologically
+		sort.SliceStable(response.Sessions, func(i, j int) bool {
+			return response.Sessions[i].Date < response.Sessions[j].Date
+		})
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// parsePlanRange reads the inclusive range of days a plan is requested for,
+// defaulting to the week starting today in UTC
+func parsePlanRange(r *http.Request) (time.Time, time.Time, error) {
+	values := r.URL.Query()
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if v := values.Get("from"); v != "" {
+		var err error
+		from, err = time.Parse(dateFormat, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: must be formatted as %s", dateFormat)
+		}
+	}
+	to := from.AddDate(0, 0, 6)
+	if v := values.Get("to"); v != "" {
+		var err error
+		to, err = time.Parse(dateFormat, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: must be formatted as %s", dateFormat)
+		}
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to: must not be before from")
+	}
+	if to.Sub(from) >= maxPlanDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid range: must not span more than %d days", maxPlanDays)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestProgramsCRUD(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	yesterday := time.Now().UTC().Add(-24 * time.Hour)
+	program := s.createProgram(tokens.AccessToken, activityID, yesterday, 9, 1, 3)
+	templateID := program.Sessions[0].TemplateID
+
+	// sessions are ordered by week and day, and dated from the start
+	start := time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), 0, 0, 0, 0, time.UTC)
+	expected := []struct {
+		week int
+		day  int
+		date string
+	}{
+		{1, 1, start.Format(dateFormat)},
+		{1, 3, start.AddDate(0, 0, 2).Format(dateFormat)},
+		{2, 2, start.AddDate(0, 0, 8).Format(dateFormat)},
+	}
+	if program.Weeks != 2 || len(program.Sessions) != len(expected) {
+		t.Fatalf("expected 3 sessions over 2 weeks, got %+v", program)
+	}
+	for i, session := range program.Sessions {
+		e := expected[i]
+		if session.Week != e.week || session.Day != e.day || session.Date != e.date || session.Completed {
+			t.Fatalf("expected session %d on week %d day %d, %s, got %+v", i, e.week, e.day, e.date, session)
+		}
+	}
+
+	// a workout on the first day completes its session
+	workout := s.createWorkout(tokens.AccessToken, activityID, start.Add(7*time.Hour))
+	if workout.CompletedSessionID == nil || *workout.CompletedSessionID != program.Sessions[0].SessionID {
+		t.Fatalf("expected the workout to complete the first session, got %+v", workout)
+	}
+
+	rw := s.do("GET", "/v1/programs/"+program.ProgramID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	decodeBody(t, rw, &program)
+	if first := program.Sessions[0]; !first.Completed || first.WorkoutID == nil || *first.WorkoutID != workout.WorkoutID {
+		t.Fatalf("expected the first session to be completed by the workout, got %+v", first)
+	}
+
+	// rescheduling keeps the sessions still planned for the same day
+	rw = s.doJSON("PUT", "/v1/programs/"+program.ProgramID, tokens.AccessToken, map[string]interface{}{
+		"name":       "Base building",
+		"start_date": start.Format(dateFormat),
+		"time_zone":  "UTC",
+		"sessions": []map[string]interface{}{
+			{"template_id": templateID, "week": 1, "day": 1},
+			{"template_id": templateID, "week": 1, "day": 5},
+		},
+	})
+	expectStatus(t, rw, http.StatusNoContent)
+
+	rw = s.do("GET", "/v1/programs/"+program.ProgramID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	var rescheduled GetProgramsResponse
+	decodeBody(t, rw, &rescheduled)
+	if len(rescheduled.Sessions) != 2 || rescheduled.Sessions[0].SessionID != program.Sessions[0].SessionID ||
+		!rescheduled.Sessions[0].Completed || rescheduled.Sessions[1].Completed {
+		t.Fatalf("expected the completed session to be kept, got %+v", rescheduled.Sessions)
+	}
+
+	rw = s.do("GET", "/v1/programs", tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	var programs GetAllProgramsResponse
+	decodeBody(t, rw, &programs)
+	if len(programs) != 1 || programs[0].ProgramID != program.ProgramID {
+		t.Fatalf("expected the program to be listed, got %+v", programs)
+	}
+
+	intruder := s.signup("eve@example.com")
+	rw = s.do("GET", "/v1/programs/"+program.ProgramID, intruder.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusNotFound)
+
+	rw = s.do("DELETE", "/v1/programs/"+program.ProgramID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusNoContent)
+	rw = s.do("GET", "/v1/programs/"+program.ProgramID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusNotFound)
+
+	// the workout outlives the program
+	rw = s.do("GET", "/v1/workouts/"+workout.WorkoutID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+}
+
+func TestProgramsPostInvalid(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	program := s.createProgram(tokens.AccessToken, activityID, time.Now(), 1)
+	templateID := program.Sessions[0].TemplateID
+
+	tests := []struct {
+		name     string
+		start    string
+		timeZone string
+		session  map[string]interface{}
+		errors   map[string]string
+	}{
+		{"without a start date", "", "UTC", map[string]interface{}{"template_id": templateID, "week": 1, "day": 1},
+			map[string]string{"start_date": validationCodeRequired}},
+		{"with a malformed start date", "01/03/2026", "UTC", map[string]interface{}{"template_id": templateID, "week": 1, "day": 1},
+			map[string]string{"start_date": validationCodeInvalidFormat}},
+		{"with an unknown time zone", "2026-03-01", "Mars/Olympus_Mons", map[string]interface{}{"template_id": templateID, "week": 1, "day": 1},
+			map[string]string{"time_zone": validationCodeInvalidFormat}},
+		{"on a day past the week", "2026-03-01", "UTC", map[string]interface{}{"template_id": templateID, "week": 1, "day": 8},
+			map[string]string{"sessions[0].day": validationCodeTooLarge}},
+		{"before the first week", "2026-03-01", "UTC", map[string]interface{}{"template_id": templateID, "week": 0, "day": 1},
+			map[string]string{"sessions[0].week": validationCodeTooSmall}},
+		{"of an unknown template", "2026-03-01", "UTC", map[string]interface{}{"template_id": "00000000-0000-0000-0000-000000000000", "week": 1, "day": 1},
+			map[string]string{"sessions[0].template_id": validationCodeNotFound}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := map[string]interface{}{
+				"name":      "Base building",
+				"time_zone": test.timeZone,
+				"sessions":  []map[string]interface{}{test.session},
+			}
+			if test.start != "" {
+				request["start_date"] = test.start
+			}
+			rw := s.doJSON("POST", "/v1/programs", tokens.AccessToken, request)
+			expectValidationErrors(t, rw, test.errors)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type GetWorkoutStatsResponse struct {
+	GroupBy  string                          `json:"group_by"`
+	TimeZone string                          `json:"time_zone"`
+	Totals   GetWorkoutStatsResponseTotals   `json:"totals"`
+	Buckets  []GetWorkoutStatsResponseBucket `json:"buckets"`
+}
+
+type GetWorkoutStatsResponseTotals struct {
+	Workouts              int64    `json:"workouts"`
+	TotalCaloriesBurned   int64    `json:"total_calories_burned"`
+	AverageCaloriesBurned float64  `json:"average_calories_burned"`
+	TotalDuration         int64    `json:"total_duration"`
+	AverageDuration       int64    `json:"average_duration"`
+	TotalDistanceMeters   float64  `json:"total_distance_meters"`
+	TotalElevationGain    float64  `json:"total_elevation_gain"`
+	AverageHeartRate      *float64 `json:"average_heart_rate,omitempty"`
+}
+
+type GetWorkoutStatsResponseBucket struct {
+	PeriodStart           string   `json:"period_start"`
+	ActivityID            string   `json:"activity_id,omitempty"`
+	Workouts              int64    `json:"workouts"`
+	TotalCaloriesBurned   int64    `json:"total_calories_burned"`
+	AverageCaloriesBurned float64  `json:"average_calories_burned"`
+	TotalDuration         int64    `json:"total_duration"`
+	AverageDuration       int64    `json:"average_duration"`
+	TotalDistanceMeters   float64  `json:"total_distance_meters"`
+	TotalElevationGain    float64  `json:"total_elevation_gain"`
+	AverageHeartRate      *float64 `json:"average_heart_rate,omitempty"`
+}
+
+func getStatsWorkoutsGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/stats/workouts.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		query, err := parseWorkoutStatsQuery(r, userID)
+		if err != nil {
+			errorMessage := "invalid query parameters"
+			errorStatusCode := http.StatusBadRequest
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		// get from db
+		buckets, err := getWorkoutStats(r.Context(), log, appData, query)
+		if err != nil {
+			errorMessage := "error getting workout stats from database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		response := GetWorkoutStatsResponse{
+			GroupBy:  query.groupBy,
+			TimeZone: query.location.String(),
+			Buckets:  []GetWorkoutStatsResponseBucket{},
+		}
+		var totalDuration time.Duration
+		var heartRateWorkouts int64
+		var totalHeartRate float64
+		for _, b := range buckets {
+			response.Totals.Workouts += b.count
+			response.Totals.TotalCaloriesBurned += b.totalCaloriesBurned
+			response.Totals.TotalDistanceMeters += b.totalDistanceMeters
+			response.Totals.TotalElevationGain += b.totalElevationGain
+			totalDuration += b.totalDuration
+			if b.avgHeartRate != nil {
+				heartRateWorkouts += b.heartRateWorkouts
+				totalHeartRate += *b.avgHeartRate * float64(b.heartRateWorkouts)
+			}
+
+			response.Buckets = append(response.Buckets, GetWorkoutStatsResponseBucket{
+				PeriodStart:           b.periodStart.Format(time.RFC3339),
+				ActivityID:            b.activityID,
+				Workouts:              b.count,
+				TotalCaloriesBurned:   b.totalCaloriesBurned,
+				AverageCaloriesBurned: b.avgCaloriesBurned,
+				TotalDuration:         b.totalDuration.Milliseconds(),
+				AverageDuration:       b.avgDuration.Milliseconds(),
+				TotalDistanceMeters:   b.totalDistanceMeters,
+				TotalElevationGain:    b.totalElevationGain,
+				AverageHeartRate:      b.avgHeartRate,
+			})
+		}
+		response.Totals.TotalDuration = totalDuration.Milliseconds()
+		if response.Totals.Workouts > 0 {
+			response.Totals.AverageCaloriesBurned = float64(response.Totals.TotalCaloriesBurned) / float64(response.Totals.Workouts)
+			response.Totals.AverageDuration = (totalDuration / time.Duration(response.Totals.Workouts)).Milliseconds()
+		}
+		if heartRateWorkouts > 0 {
+			averageHeartRate := totalHeartRate / float64(heartRateWorkouts)
+			response.Totals.AverageHeartRate = &averageHeartRate
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// parseWorkoutStatsQuery builds a stats query for the requesting user's
+// workouts from the grouping and filtering query parameters
+func parseWorkoutStatsQuery(r *http.Request, userID string) (*workoutStatsQuery, error) {
+	values := r.URL.Query()
+	query := &workoutStatsQuery{
+		userID:   userID,
+		groupBy:  statsGroupByDay,
+		location: time.UTC,
+	}
+
+	if v := values.Get("group_by"); v != "" {
+		switch v {
+		case statsGroupByDay, statsGroupByWeek, statsGroupByMonth, statsGroupByYear:
+			query.groupBy = v
+		default:
+			return nil, fmt.Errorf("invalid group_by: must be one of %s, %s, %s or %s",
+				statsGroupByDay, statsGroupByWeek, statsGroupByMonth, statsGroupByYear)
+		}
+	}
+	if v := values.Get("by_activity"); v != "" {
+		byActivity, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid by_activity: %w", err)
+		}
+		query.byActivity = byActivity
+	}
+	if v := values.Get("tz"); v != "" {
+		location, err := loadTimeZone(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tz: %w", err)
+		}
+		query.location = location
+	}
+
+	if v := values.Get("activity_id"); v != "" {
+		query.activityID = &v
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"from", &query.from},
+		{"to", &query.to},
+	} {
+		if v := values.Get(param.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", param.name, err)
+			}
+			*param.dest = &t
+		}
+	}
+
+	return query, nil
+}
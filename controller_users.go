@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type GetUsersResponse struct {
+	UserID    string   `json:"user_id"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email"`
+	CreatedAt string   `json:"created_at"`
+	WeightKg  *float64 `json:"weight_kg,omitempty"`
+}
+
+func getUsersGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/users/{id}.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		requestedUserID := mux.Vars(r)["id"]
+		err := controllerCheckSameUser(rw, log, userID, requestedUserID)
+		if err != nil {
+			return
+		}
+
+		user := &user{
+			userID: requestedUserID,
+		}
+
+		// check if row exists
+		err = controllerCheckExists(r.Context(), rw, user, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		err = controllerDatabaseFunc(r.Context(), rw, user, user.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		response := GetUsersResponse{
+			UserID:    user.userID,
+			Name:      user.name,
+			Email:     user.email,
+			CreatedAt: user.createdAt.Format(time.RFC3339),
+			WeightKg:  user.weightKg,
+		}
+		controllerEncodeResponse(rw, log, http.StatusOK, response)
+
+		log.Debug("request completed")
+
+	}
+}
+
+type PutUsersRequest struct {
+	Name     *string  `json:"name" validate:"required,notblank"`
+	Email    *string  `json:"email" validate:"required,notblank"`
+	WeightKg *float64 `json:"weight_kg" validate:"min=20,max=500"`
+}
+
+func getUsersPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/users/{id}.PUT",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		requestedUserID := mux.Vars(r)["id"]
+		err := controllerCheckSameUser(rw, log, userID, requestedUserID)
+		if err != nil {
+			return
+		}
+
+		user := &user{
+			userID: requestedUserID,
+		}
+
+		// check if row exists
+		err = controllerCheckExists(r.Context(), rw, user, log, appData)
+		if err != nil {
+			return
+		}
+
+		var putUserRequest PutUsersRequest
+		err = controllerDecodeRequest(rw, log, r.Body, &putUserRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &putUserRequest)
+		if err != nil {
+			return
+		}
+
+		user.name = *putUserRequest.Name
+		user.email = normalizeEmail(*putUserRequest.Email)
+		user.weightKg = putUserRequest.WeightKg
+
+		// update in db
+		err = controllerDatabaseFunc(r.Context(), rw, user, user.Update, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+func getUsersDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/users/{id}.DELETE",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		requestedUserID := mux.Vars(r)["id"]
+		err := controllerCheckSameUser(rw, log, userID, requestedUserID)
+		if err != nil {
+			return
+		}
+
+		user := &user{
+			userID: requestedUserID,
+		}
+
+		// check if row exists
+		err = controllerCheckExists(r.Context(), rw, user, log, appData)
+		if err != nil {
+			return
+		}
+
+		// delete from db
+		err = controllerDatabaseFunc(r.Context(), rw, user, user.Delete, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+// normalizeEmail lowercases email addresses so that uniqueness
+// is not defeated by differences in case
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
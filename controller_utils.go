@@ -1,47 +1,249 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/sirupsen/logrus"
 )
 
+// formats of error responses
+const (
+	// errorFormatProblem writes RFC 7807 problem details
+	errorFormatProblem = "problem"
+	// errorFormatLegacy writes the ErrorResponse envelope of earlier
+	// versions of the API
+	errorFormatLegacy = "legacy"
+)
+
+// ProblemDetails describes an error in responses, as RFC 7807 problem
+// details. Code is stable for clients to switch on, unlike Detail
+type ProblemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+	// Instance is the id of the request, as in the X-Request-ID header
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Details lists the invalid fields of a request that failed validation
+	Details []ValidationError `json:"details,omitempty"`
+}
+
+// ErrorInfo describes an error in legacy responses. Code is stable for
+// clients to switch on, unlike Message and Error
 type ErrorInfo struct {
+	Code    string `json:"code"`
 	Message string `json:"message"`
 	Error   string `json:"error,omitempty"`
+	// Details lists the invalid fields of a request that failed validation
+	Details []ValidationError `json:"details,omitempty"`
 }
 
 type ErrorResponse struct {
 	ErrorInfo *ErrorInfo `json:"error"`
 }
 
+// writeErrorResponse responds with the error in the configured format. the
+// text of err is only returned if it tells the client what was wrong with
+// their request, so callers are expected to log it
 func writeErrorResponse(response http.ResponseWriter, statusCode int, errorMessage string, err error) {
-	response.Header().Add("Content-Type", "application/json")
-	response.WriteHeader(statusCode)
-	errorString := ""
-	if err != nil {
-		errorString = err.Error()
+	writeError(response, statusCode, errorCode(statusCode, err), errorMessage, clientErrorText(statusCode, err), nil)
+}
+
+// writeValidationErrorResponse responds with every invalid field of a
+// request
+func writeValidationErrorResponse(rw http.ResponseWriter, log *logrus.Entry, errs validationErrors) {
+	errorMessage := "invalid request"
+	errorStatusCode := http.StatusUnprocessableEntity
+
+	log.WithError(errs).Error(errorMessage)
+	writeError(rw, errorStatusCode, errorCodeValidationFailed, errorMessage, "", errs)
+}
+
+// writeError writes an error response in the legacy envelope, or as problem
+// details if so configured. errorText explains errorMessage, if given
+func writeError(rw http.ResponseWriter, statusCode int, code string, errorMessage string, errorText string, details []ValidationError) {
+	var requestID string
+	errorFormat := errorFormatLegacy
+	if w, ok := rw.(*requestResponseWriter); ok {
+		requestID = w.requestID
+		errorFormat = w.errorFormat
 	}
 
-	json.NewEncoder(response).Encode(
-		ErrorResponse{
-			ErrorInfo: &ErrorInfo{
-				Message: errorMessage,
-				Error:   errorString,
+	if errorFormat == errorFormatLegacy {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(statusCode)
+		json.NewEncoder(rw).Encode(
+			ErrorResponse{
+				ErrorInfo: &ErrorInfo{
+					Code:    code,
+					Message: errorMessage,
+					Error:   errorText,
+					Details: details,
+				},
 			},
-		},
-	)
+		)
+		return
+	}
+
+	detail := errorMessage
+	if errorText != "" {
+		detail += ": " + errorText
+	}
+	problem := ProblemDetails{
+		Type:    "about:blank",
+		Title:   http.StatusText(statusCode),
+		Status:  statusCode,
+		Detail:  detail,
+		Code:    code,
+		Details: details,
+	}
+	if requestID != "" {
+		problem.Instance = requestID
+	}
+
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.WriteHeader(statusCode)
+	json.NewEncoder(rw).Encode(problem)
 }
 
-func controllerCheckExists(rw http.ResponseWriter, o persistenceObject, log *logrus.Entry, appData *appData) error {
+// clientErrorText returns the text of err if it is safe to return, that is
+// if it is a client error of the request itself. server errors and errors
+// from persistence carry internals of the server and database, so they are
+// only logged
+func clientErrorText(statusCode int, err error) string {
+	if err == nil || statusCode >= http.StatusInternalServerError {
+		return ""
+	}
+	var persistenceErr *persistenceError
+	if errors.As(err, &persistenceErr) || errors.Is(err, errVersionMismatch) {
+		return ""
+	}
+	return err.Error()
+}
+
+// codes of errors in responses
+const (
+	errorCodeBadRequest           = "bad_request"
+	errorCodeUnauthorized         = "unauthorized"
+	errorCodeForbidden            = "forbidden"
+	errorCodeNotFound             = "not_found"
+	errorCodeMethodNotAllowed     = "method_not_allowed"
+	errorCodeConflict             = "conflict"
+	errorCodeAlreadyExists        = "already_exists"
+	errorCodeVersionMismatch      = "version_mismatch"
+	errorCodePayloadTooLarge      = "payload_too_large"
+	errorCodeUnsupportedMediaType = "unsupported_media_type"
+	errorCodeValidationFailed     = "validation_failed"
+	errorCodeReferenceNotFound    = "reference_not_found"
+	errorCodeInvalidData          = "invalid_data"
+	errorCodeTooManyRequests      = "too_many_requests"
+	errorCodeInternal             = "internal_error"
+	errorCodeUnavailable          = "unavailable"
+	errorCodeTimeout              = "timeout"
+)
+
+// errorCodes are the codes of errors of a known kind
+var errorCodes = []struct {
+	kind error
+	code string
+}{
+	{errVersionMismatch, errorCodeVersionMismatch},
+	{errNotFound, errorCodeNotFound},
+	{errUniqueViolation, errorCodeAlreadyExists},
+	{errForeignKeyViolation, errorCodeReferenceNotFound},
+	{errConflict, errorCodeConflict},
+	{errInvalidData, errorCodeInvalidData},
+	{errTimeout, errorCodeTimeout},
+}
+
+// statusErrorCodes are the codes of any other errors, by status
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:            errorCodeBadRequest,
+	http.StatusUnauthorized:          errorCodeUnauthorized,
+	http.StatusForbidden:             errorCodeForbidden,
+	http.StatusNotFound:              errorCodeNotFound,
+	http.StatusMethodNotAllowed:      errorCodeMethodNotAllowed,
+	http.StatusConflict:              errorCodeConflict,
+	http.StatusPreconditionFailed:    errorCodeVersionMismatch,
+	http.StatusRequestEntityTooLarge: errorCodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  errorCodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   errorCodeValidationFailed,
+	http.StatusTooManyRequests:       errorCodeTooManyRequests,
+	http.StatusServiceUnavailable:    errorCodeUnavailable,
+}
+
+// errorCode returns the code of an error response, by the kind of err if
+// it is of a known one or else by the status
+func errorCode(statusCode int, err error) string {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.kind) {
+			return c.code
+		}
+	}
+	if code, ok := statusErrorCodes[statusCode]; ok {
+		return code
+	}
+	return errorCodeInternal
+}
+
+// databaseErrorStatusCode maps the kinds of persistence errors to statuses,
+// telling apart requests that ran out of time or were abandoned from
+// genuine server errors
+func databaseErrorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, errConflict), errors.Is(err, errUniqueViolation):
+		return http.StatusConflict
+	case errors.Is(err, errForeignKeyViolation), errors.Is(err, errInvalidData):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errTimeout), errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// databaseErrorMessage describes a persistence error of an object of the
+// given type by its kind
+func databaseErrorMessage(objectType string, err error) string {
+	switch {
+	case errors.Is(err, errNotFound):
+		return objectType + " does not exist"
+	case errors.Is(err, errUniqueViolation):
+		return objectType + " already exists"
+	case errors.Is(err, errForeignKeyViolation):
+		return objectType + " references a resource that does not exist"
+	case errors.Is(err, errConflict):
+		return objectType + " conflicts with other resources"
+	case errors.Is(err, errInvalidData):
+		return objectType + " is invalid"
+	case errors.Is(err, errTimeout), errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err):
+		return "database timed out on " + objectType
+	default:
+		return "error accessing " + objectType + " in database"
+	}
+}
+
+func controllerCheckExists(ctx context.Context, rw http.ResponseWriter, o persistenceObject, log *logrus.Entry, appData *appData) error {
 	// check if row exists
-	exists, err := o.Exists(log, appData)
+	exists, err := o.Exists(ctx, log, appData)
 	if err != nil {
 		errorMessage := "error checking " + o.Type() + " existence in database"
-		errorStatusCode := http.StatusInternalServerError
+		errorStatusCode := databaseErrorStatusCode(err)
 
 		log.WithError(err).Error(errorMessage)
 		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
@@ -58,27 +260,78 @@ func controllerCheckExists(rw http.ResponseWriter, o persistenceObject, log *log
 	return nil
 }
 
-func controllerCheckMissingFields(rw http.ResponseWriter, log *logrus.Entry, fields ...interface{}) error {
-	// check for missing fields
-	for _, field := range fields {
-		if field == nil {
-			errorMessage := "missing field from request"
-			errorStatusCode := http.StatusBadRequest
+func controllerCheckSameUser(rw http.ResponseWriter, log *logrus.Entry, userID string, requestedUserID string) error {
+	// users may only access their own account
+	if userID != requestedUserID {
+		errorMessage := "cannot access another user"
+		errorStatusCode := http.StatusForbidden
 
-			log.Error(errorMessage)
-			writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
-			return fmt.Errorf("missing field from request")
-		}
+		log.Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+		return fmt.Errorf("cannot access another user")
 	}
 	return nil
 }
 
-func controllerDatabaseFunc(rw http.ResponseWriter, o persistenceObject, oFunc func(*logrus.Entry, *appData) error, log *logrus.Entry, appData *appData) error {
-	err := oFunc(log, appData)
+// controllerCheckIfMatch fails unless the If-Match header lists the ETag of
+// the given version of o
+func controllerCheckIfMatch(rw http.ResponseWriter, log *logrus.Entry, o persistenceObject, ifMatch string, version int64) error {
+	if !etagListMatches(ifMatch, formatETag(version), false) {
+		errorMessage := o.Type() + " has been modified"
+		errorStatusCode := http.StatusPreconditionFailed
+
+		log.Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+		return fmt.Errorf("precondition failed")
+	}
+	return nil
+}
+
+// controllerValidateRequest checks the request against the rules of its
+// validate tags, responding with every invalid field if any is
+func controllerValidateRequest(rw http.ResponseWriter, log *logrus.Entry, request interface{}) error {
+	err := validateRequest(request)
+	var errs validationErrors
+	if errors.As(err, &errs) {
+		writeValidationErrorResponse(rw, log, errs)
+		return fmt.Errorf("invalid request: %w", err)
+	}
 	if err != nil {
-		errorMessage := "error getting " + o.Type() + " from database"
+		errorMessage := "error validating request"
 		errorStatusCode := http.StatusInternalServerError
 
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return fmt.Errorf("error validating request: %w", err)
+	}
+	return nil
+}
+
+func controllerCheckWorkoutMetrics(rw http.ResponseWriter, log *logrus.Entry, w *workout) error {
+	err := w.validateMetrics()
+	var errs validationErrors
+	if errors.As(err, &errs) {
+		writeValidationErrorResponse(rw, log, errs)
+		return fmt.Errorf("invalid workout metrics: %w", err)
+	}
+	return nil
+}
+
+func controllerDatabaseFunc(ctx context.Context, rw http.ResponseWriter, o persistenceObject, oFunc func(context.Context, *logrus.Entry, *appData) error, log *logrus.Entry, appData *appData) error {
+	err := oFunc(ctx, log, appData)
+	if errors.Is(err, errVersionMismatch) {
+		// lost a race with another write since the If-Match check
+		errorMessage := o.Type() + " has been modified"
+		errorStatusCode := http.StatusPreconditionFailed
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return fmt.Errorf("precondition failed: %w", err)
+	}
+	if err != nil {
+		errorMessage := databaseErrorMessage(o.Type(), err)
+		errorStatusCode := databaseErrorStatusCode(err)
+
 		log.WithError(err).Error(errorMessage)
 		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
 		return fmt.Errorf("error getting from existence: %w", err)
@@ -86,20 +339,24 @@ func controllerDatabaseFunc(rw http.ResponseWriter, o persistenceObject, oFunc f
 	return nil
 }
 
-func controllerDatabaseGetAll(rw http.ResponseWriter, persistenceObjectType string, log *logrus.Entry, appData *appData) ([]persistenceObject, error) {
+func controllerDatabaseGetAll(ctx context.Context, rw http.ResponseWriter, persistenceObjectType string, log *logrus.Entry, appData *appData, userID string) ([]persistenceObject, error) {
 	var persistenceObjects []persistenceObject
 	var err error
 	switch persistenceObjectType {
 	case "activity":
-		persistenceObjects, err = getAllActivities(log, appData)
-	case "workout":
-		persistenceObjects, err = getAllWorkouts(log, appData)
+		persistenceObjects, err = getAllActivities(ctx, log, appData, userID)
+	case "workout template":
+		persistenceObjects, err = getAllWorkoutTemplates(ctx, log, appData, userID)
+	case "program":
+		persistenceObjects, err = getAllPrograms(ctx, log, appData, userID)
+	case "goal":
+		persistenceObjects, err = getAllGoals(ctx, log, appData, userID)
 	default:
 		err = fmt.Errorf("unknown persistence object type: this is a server error and reflects no invalid client action")
 	}
 	if err != nil {
 		errorMessage := "error getting all of type " + persistenceObjectType + " from database"
-		errorStatusCode := http.StatusInternalServerError
+		errorStatusCode := databaseErrorStatusCode(err)
 
 		log.WithError(err).Error(errorMessage)
 		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
@@ -108,6 +365,58 @@ func controllerDatabaseGetAll(rw http.ResponseWriter, persistenceObjectType stri
 	return persistenceObjects, nil
 }
 
+func controllerDatabaseQueryWorkouts(ctx context.Context, rw http.ResponseWriter, query *workoutQuery, log *logrus.Entry, appData *appData) ([]*workout, string, error) {
+	workouts, next, err := queryWorkouts(ctx, log, appData, query)
+	if err != nil {
+		errorMessage := "error querying workouts from database"
+		errorStatusCode := databaseErrorStatusCode(err)
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return nil, "", fmt.Errorf("error querying workouts: %w", err)
+	}
+	return workouts, next, nil
+}
+
+func controllerDatabaseQueryMeasurements(ctx context.Context, rw http.ResponseWriter, query *measurementQuery, log *logrus.Entry, appData *appData) ([]*measurement, error) {
+	measurements, err := queryMeasurements(ctx, log, appData, query)
+	if err != nil {
+		errorMessage := "error querying measurements from database"
+		errorStatusCode := databaseErrorStatusCode(err)
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return nil, fmt.Errorf("error querying measurements: %w", err)
+	}
+	return measurements, nil
+}
+
+// limitRequestBody caps the request body at max bytes with
+// http.MaxBytesReader, which also has the server close the connection
+// instead of reading the rest. reads past the limit fail with errTooLarge
+func limitRequestBody(rw http.ResponseWriter, r *http.Request, max int64, errTooLarge error) {
+	r.Body = &limitedRequestBody{
+		ReadCloser:  http.MaxBytesReader(rw, r.Body, max),
+		remaining:   max,
+		errTooLarge: errTooLarge,
+	}
+}
+
+type limitedRequestBody struct {
+	io.ReadCloser
+	remaining   int64
+	errTooLarge error
+}
+
+func (b *limitedRequestBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if err != nil && err != io.EOF && b.remaining <= 0 {
+		err = b.errTooLarge
+	}
+	return n, err
+}
+
 func controllerDecodeRequest(rw http.ResponseWriter, log *logrus.Entry, rc io.ReadCloser, v interface{}) error {
 	// decode request
 	err := json.NewDecoder(rc).Decode(v)
@@ -122,10 +431,37 @@ func controllerDecodeRequest(rw http.ResponseWriter, log *logrus.Entry, rc io.Re
 	return nil
 }
 
+func controllerDecodePatch(rw http.ResponseWriter, log *logrus.Entry, r *http.Request, current interface{}, v interface{}, nullable ...string) ([]string, error) {
+	// decode patch, returning the fields it sets
+	members, err := decodePatch(r.Header.Get("Content-Type"), r.Body, current)
+	if err == nil {
+		var fields []string
+		fields, err = decodePatchMembers(members, v, nullable...)
+		if err == nil {
+			return fields, nil
+		}
+	}
+
+	errorMessage := "error decoding patch"
+	errorStatusCode := http.StatusBadRequest
+	switch {
+	case errors.Is(err, errUnsupportedPatchType):
+		errorStatusCode = http.StatusUnsupportedMediaType
+	case errors.Is(err, errPatchTestFailed):
+		errorStatusCode = http.StatusConflict
+	}
+
+	log.WithError(err).Error(errorMessage)
+	writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+	return nil, fmt.Errorf("error decoding patch")
+}
+
+// controllerEncodeResponse encodes the whole body before writing the
+// header, so that an error encoding it can still be responded with
 func controllerEncodeResponse(rw http.ResponseWriter, log *logrus.Entry, statusCode int, v interface{}) error {
 	// encode response
-	rw.Header().Add("Content-Type", "application/json")
-	err := json.NewEncoder(rw).Encode(v)
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(v)
 	if err != nil {
 		errorMessage := "error encoding response body"
 		errorStatusCode := http.StatusInternalServerError
@@ -134,5 +470,24 @@ func controllerEncodeResponse(rw http.ResponseWriter, log *logrus.Entry, statusC
 		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
 		return fmt.Errorf("error encoding response body")
 	}
+
+	rw.Header().Add("Content-Type", "application/json")
+	rw.WriteHeader(statusCode)
+	_, err = body.WriteTo(rw)
+	if err != nil {
+		// the status is sent already, so there is nothing left to tell
+		log.WithError(err).Warn("error writing response body")
+		return fmt.Errorf("error writing response body: %w", err)
+	}
 	return nil
 }
+
+// loadTimeZone loads the location of an IANA time zone name. unlike
+// time.LoadLocation it refuses "Local", which depends on the server
+func loadTimeZone(name string) (*time.Location, error) {
+	location, err := time.LoadLocation(name)
+	if err == nil && location == time.Local {
+		err = fmt.Errorf("time zone must be an IANA name")
+	}
+	return location, err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorResponseFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		errorFormat string
+		path        string
+		body        map[string]string
+		statusCode  int
+		code        string
+		details     int
+	}{
+		{"legacy client error", errorFormatLegacy, "/v1/workouts", nil, http.StatusUnauthorized, errorCodeUnauthorized, 0},
+		{"legacy validation error", errorFormatLegacy, "/v1/auth/login", map[string]string{}, http.StatusUnprocessableEntity, errorCodeValidationFailed, 2},
+		{"problem client error", errorFormatProblem, "/v1/workouts", nil, http.StatusUnauthorized, errorCodeUnauthorized, 0},
+		{"problem validation error", errorFormatProblem, "/v1/auth/login", map[string]string{}, http.StatusUnprocessableEntity, errorCodeValidationFailed, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.appData.config.server.errorFormat = test.errorFormat
+
+			var rw *httptest.ResponseRecorder
+			if test.body == nil {
+				rw = s.do("GET", test.path, "", nil, nil)
+			} else {
+				rw = s.doJSON("POST", test.path, "", test.body)
+			}
+			expectStatus(t, rw, test.statusCode)
+			requestID := rw.Header().Get(requestIDHeader)
+			if requestID == "" {
+				t.Fatalf("expected the response to carry its request id")
+			}
+
+			if test.errorFormat == errorFormatLegacy {
+				if contentType := rw.Header().Get("Content-Type"); contentType != "application/json" {
+					t.Fatalf("expected application/json, got %s", contentType)
+				}
+				var response ErrorResponse
+				decodeBody(t, rw, &response)
+				if response.ErrorInfo == nil || response.ErrorInfo.Code != test.code || len(response.ErrorInfo.Details) != test.details {
+					t.Fatalf("expected a %s error with %d details, got %s", test.code, test.details, rw.Body.String())
+				}
+				return
+			}
+
+			if contentType := rw.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Fatalf("expected application/problem+json, got %s", contentType)
+			}
+			var problem ProblemDetails
+			decodeBody(t, rw, &problem)
+			if problem.Status != test.statusCode || problem.Title != http.StatusText(test.statusCode) ||
+				problem.Code != test.code || len(problem.Details) != test.details {
+				t.Fatalf("expected a %d %s problem with %d details, got %s", test.statusCode, test.code, test.details, rw.Body.String())
+			}
+			if problem.Instance != requestID {
+				t.Fatalf("expected the instance to be the request id %s, got %s", requestID, problem.Instance)
+			}
+		})
+	}
+}
+
+func TestWriteErrorResponseUnwrapped(t *testing.T) {
+	// outside of the request id middleware, errors are in the legacy envelope
+	rw := httptest.NewRecorder()
+	writeErrorResponse(rw, http.StatusNotFound, "workout not found", nil)
+
+	expectStatus(t, rw, http.StatusNotFound)
+	var response ErrorResponse
+	decodeBody(t, rw, &response)
+	if response.ErrorInfo == nil || response.ErrorInfo.Message != "workout not found" {
+		t.Fatalf("expected a legacy error, got %s", rw.Body.String())
+	}
+}
+
+func TestClientErrorText(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		expected   string
+	}{
+		{"client errors explain themselves", http.StatusBadRequest, errors.New("invalid limit"), "invalid limit"},
+		{"server errors are hidden", http.StatusInternalServerError, errors.New("connection refused"), ""},
+		{"persistence errors are hidden", http.StatusConflict, newPersistenceError(errConflict, "duplicate key"), ""},
+		{"version mismatches are hidden", http.StatusPreconditionFailed, fmt.Errorf("update: %w", errVersionMismatch), ""},
+		{"no error has no text", http.StatusBadRequest, nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := clientErrorText(test.statusCode, test.err); got != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type GetWorkoutTemplatesResponse struct {
+	TemplateID           string `json:"template_id"`
+	ActivityID           string `json:"activity_id"`
+	Name                 string `json:"name"`
+	TargetDuration       *int64 `json:"target_duration,omitempty"`
+	TargetCaloriesBurned *int   `json:"target_calories_burned,omitempty"`
+	TargetSets           *int   `json:"target_sets,omitempty"`
+}
+
+func newGetWorkoutTemplatesResponse(t *workoutTemplate) GetWorkoutTemplatesResponse {
+	response := GetWorkoutTemplatesResponse{
+		TemplateID:           t.templateID,
+		ActivityID:           t.activityID,
+		Name:                 t.name,
+		TargetCaloriesBurned: t.targetCaloriesBurned,
+		TargetSets:           t.targetSets,
+	}
+	if t.targetDuration != nil {
+		targetDuration := t.targetDuration.Milliseconds()
+		response.TargetDuration = &targetDuration
+	}
+	return response
+}
+
+func getWorkoutTemplatesGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/templates/{id}.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		template := &workoutTemplate{
+			templateID: mux.Vars(r)["id"],
+			userID:     userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, template, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		err = controllerDatabaseFunc(r.Context(), rw, template, template.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, newGetWorkoutTemplatesResponse(template))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type GetAllWorkoutTemplatesResponse []GetWorkoutTemplatesResponse
+
+func getWorkoutTemplatesGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/templates.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		// get from db
+		persistenceObjects, err := controllerDatabaseGetAll(r.Context(), rw, "workout template", log, appData, userID)
+		if err != nil {
+			return
+		}
+
+		response := GetAllWorkoutTemplatesResponse{}
+		for _, object := range persistenceObjects {
+			response = append(response, newGetWorkoutTemplatesResponse(object.(*workoutTemplate)))
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// WorkoutTemplateTargets holds the optional targets of a template in
+// requests, with the duration in milliseconds
+type WorkoutTemplateTargets struct {
+	TargetDuration       *int64 `json:"target_duration" validate:"min=0"`
+	TargetCaloriesBurned *int   `json:"target_calories_burned" validate:"min=0"`
+	TargetSets           *int   `json:"target_sets" validate:"min=0"`
+}
+
+// apply sets every one of the targets on t, clearing the ones left out
+func (targets *WorkoutTemplateTargets) apply(t *workoutTemplate) {
+	t.targetDuration = nil
+	if targets.TargetDuration != nil {
+		targetDuration := time.Duration(*targets.TargetDuration) * time.Millisecond
+		t.targetDuration = &targetDuration
+	}
+	t.targetCaloriesBurned = targets.TargetCaloriesBurned
+	t.targetSets = targets.TargetSets
+}
+
+type PostWorkoutTemplatesRequest struct {
+	ActivityID *string `json:"activity_id" validate:"required,notblank"`
+	Name       *string `json:"name" validate:"required,notblank"`
+	WorkoutTemplateTargets
+}
+
+func getWorkoutTemplatesPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/templates.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		var postTemplateRequest PostWorkoutTemplatesRequest
+		err := controllerDecodeRequest(rw, log, r.Body, &postTemplateRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &postTemplateRequest)
+		if err != nil {
+			return
+		}
+
+		template := &workoutTemplate{
+			templateID: uuid.NewString(),
+			userID:     userID,
+			activityID: *postTemplateRequest.ActivityID,
+			name:       *postTemplateRequest.Name,
+		}
+		postTemplateRequest.WorkoutTemplateTargets.apply(template)
+
+		// check referenced activity exists
+		err = controllerCheckExists(r.Context(), rw, &activity{
+			activityID: template.activityID,
+			userID:     userID,
+		}, log, appData)
+		if err != nil {
+			return
+		}
+
+		// save to db
+		err = controllerDatabaseFunc(r.Context(), rw, template, template.Save, log, appData)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusCreated, newGetWorkoutTemplatesResponse(template))
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+type PutWorkoutTemplatesRequest struct {
+	ActivityID *string `json:"activity_id" validate:"required,notblank"`
+	Name       *string `json:"name" validate:"required,notblank"`
+	WorkoutTemplateTargets
+}
+
+func getWorkoutTemplatesPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/templates/{id}.PUT",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		template := &workoutTemplate{
+			templateID: mux.Vars(r)["id"],
+			userID:     userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, template, log, appData)
+		if err != nil {
+			return
+		}
+
+		var putTemplateRequest PutWorkoutTemplatesRequest
+		err = controllerDecodeRequest(rw, log, r.Body, &putTemplateRequest)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &putTemplateRequest)
+		if err != nil {
+			return
+		}
+
+		template.activityID = *putTemplateRequest.ActivityID
+		template.name = *putTemplateRequest.Name
+		putTemplateRequest.WorkoutTemplateTargets.apply(template)
+
+		// check referenced activity exists
+		err = controllerCheckExists(r.Context(), rw, &activity{
+			activityID: template.activityID,
+			userID:     userID,
+		}, log, appData)
+		if err != nil {
+			return
+		}
+
+		// update in db
+		err = controllerDatabaseFunc(r.Context(), rw, template, template.Update, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+func getWorkoutTemplatesDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/templates/{id}.DELETE",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		template := &workoutTemplate{
+			templateID: mux.Vars(r)["id"],
+			userID:     userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, template, log, appData)
+		if err != nil {
+			return
+		}
+
+		// delete from db
+		err = controllerDatabaseFunc(r.Context(), rw, template, template.Delete, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
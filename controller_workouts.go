@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,40 +19,56 @@ type GetWorkoutsResponse struct {
 	Timestamp      string `json:"timestamp"`
 	CaloriesBurned int    `json:"calories_burned"`
 	Duration       int64  `json:"duration"`
+	// CaloriesEstimated tells calories estimated from the MET of the
+	// activity apart from measured ones
+	CaloriesEstimated bool `json:"calories_estimated"`
+	WorkoutMetricsResponse
 }
 
 func getWorkoutsGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/workouts/{id}.GET",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		workoutID := mux.Vars(r)["id"]
 		workout := &workout{
 			workoutID: workoutID,
+			userID:    userID,
 		}
 
 		// check if row exists
-		err := controllerCheckExists(rw, workout, log, appData)
+		err := controllerCheckExists(r.Context(), rw, workout, log, appData)
 		if err != nil {
 			return
 		}
 
 		// get from db
-		err = controllerDatabaseFunc(rw, workout, workout.Get, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Get, log, appData)
 		if err != nil {
 			return
 		}
 
 		response := GetWorkoutsResponse{
-			WorkoutID:      workout.workoutID,
-			ActivityID:     workout.activityID,
-			Timestamp:      workout.timestamp.Format(time.RFC3339),
-			CaloriesBurned: workout.caloriesBurned,
-			Duration:       workout.duration.Milliseconds(),
+			WorkoutID:         workout.workoutID,
+			ActivityID:        workout.activityID,
+			Timestamp:         workout.timestamp.Format(time.RFC3339),
+			CaloriesBurned:    workout.caloriesBurned,
+			Duration:          workout.duration.Milliseconds(),
+			CaloriesEstimated: workout.caloriesEstimated,
+
+			WorkoutMetricsResponse: newWorkoutMetricsResponse(workout),
+		}
+		rw.Header().Set("ETag", formatETag(workout.version))
+		if etagListMatches(r.Header.Get("If-None-Match"), formatETag(workout.version), true) {
+			rw.WriteHeader(http.StatusNotModified)
+			log.Debug("request completed")
+			return
 		}
 		controllerEncodeResponse(rw, log, http.StatusOK, response)
 
@@ -59,37 +79,63 @@ func getWorkoutsGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.Ha
 
 type GetAllWorkoutsResponse []GetAllWorkoutsResponseItem
 type GetAllWorkoutsResponseItem struct {
-	WorkoutID      string `json:"workout_id"`
-	ActivityID     string `json:"activity_id"`
-	Timestamp      string `json:"timestamp"`
-	CaloriesBurned int    `json:"calories_burned"`
-	Duration       int64  `json:"duration"`
+	WorkoutID         string `json:"workout_id"`
+	ActivityID        string `json:"activity_id"`
+	Timestamp         string `json:"timestamp"`
+	CaloriesBurned    int    `json:"calories_burned"`
+	Duration          int64  `json:"duration"`
+	CaloriesEstimated bool   `json:"calories_estimated"`
+	WorkoutMetricsResponse
 }
 
 func getWorkoutsGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/workouts.GET",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
+		query, err := parseWorkoutQuery(r, userID)
+		if err != nil {
+			errorMessage := "invalid query parameters"
+			errorStatusCode := http.StatusBadRequest
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
 		// get from db
-		persistenceObjects, err := controllerDatabaseGetAll(rw, "workout", log, appData)
+		workouts, next, err := controllerDatabaseQueryWorkouts(r.Context(), rw, query, log, appData)
 		if err != nil {
 			return
 		}
 
+		if next != "" {
+			nextURL := *r.URL
+			values := nextURL.Query()
+			values.Set("cursor", next)
+			nextURL.RawQuery = values.Encode()
+
+			rw.Header().Set("X-Next-Cursor", next)
+			rw.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextURL.RequestURI()))
+		}
+
 		response := GetAllWorkoutsResponse{}
-		for _, object := range persistenceObjects {
-			workout := object.(*workout)
+		for _, workout := range workouts {
 			response = append(response, GetAllWorkoutsResponseItem{
-				WorkoutID:      workout.workoutID,
-				ActivityID:     workout.activityID,
-				Timestamp:      workout.timestamp.Format(time.RFC3339),
-				CaloriesBurned: workout.caloriesBurned,
-				Duration:       workout.duration.Milliseconds(),
+				WorkoutID:         workout.workoutID,
+				ActivityID:        workout.activityID,
+				Timestamp:         workout.timestamp.Format(time.RFC3339),
+				CaloriesBurned:    workout.caloriesBurned,
+				Duration:          workout.duration.Milliseconds(),
+				CaloriesEstimated: workout.caloriesEstimated,
+
+				WorkoutMetricsResponse: newWorkoutMetricsResponse(workout),
 			})
 		}
 
@@ -103,41 +149,272 @@ func getWorkoutsGetAllHandlerFunc(baseLog *logrus.Logger, appData *appData) http
 	}
 }
 
+const (
+	defaultWorkoutsPageSize = 50
+	maxWorkoutsPageSize     = 500
+)
+
+// parseWorkoutQuery builds a query for the requesting user's workouts from
+// the pagination, sorting and filtering query parameters
+func parseWorkoutQuery(r *http.Request, userID string) (*workoutQuery, error) {
+	values := r.URL.Query()
+	query := &workoutQuery{
+		userID: userID,
+		sort:   workoutSortTimestamp,
+		// newest first unless asked otherwise
+		descending: true,
+		limit:      defaultWorkoutsPageSize,
+	}
+
+	if v := values.Get("activity_id"); v != "" {
+		query.activityID = &v
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"from", &query.from},
+		{"to", &query.to},
+	} {
+		if v := values.Get(param.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", param.name, err)
+			}
+			*param.dest = &t
+		}
+	}
+	for _, param := range []struct {
+		name string
+		dest **time.Duration
+	}{
+		{"min_duration", &query.minDuration},
+		{"max_duration", &query.maxDuration},
+	} {
+		if v := values.Get(param.name); v != "" {
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || ms < 0 {
+				return nil, fmt.Errorf("invalid %s: must be a non-negative number of milliseconds", param.name)
+			}
+			d := time.Duration(ms) * time.Millisecond
+			*param.dest = &d
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **float64
+	}{
+		{"min_distance", &query.minDistance},
+		{"max_distance", &query.maxDistance},
+	} {
+		if v := values.Get(param.name); v != "" {
+			meters, err := strconv.ParseFloat(v, 64)
+			if err != nil || meters < 0 {
+				return nil, fmt.Errorf("invalid %s: must be a non-negative number of meters", param.name)
+			}
+			*param.dest = &meters
+		}
+	}
+	for _, param := range []struct {
+		name string
+		dest **int
+	}{
+		{"min_avg_heart_rate", &query.minAvgHeartRate},
+		{"max_avg_heart_rate", &query.maxAvgHeartRate},
+	} {
+		if v := values.Get(param.name); v != "" {
+			heartRate, err := strconv.Atoi(v)
+			if err != nil || heartRate < 0 {
+				return nil, fmt.Errorf("invalid %s: must be a non-negative number of beats per minute", param.name)
+			}
+			*param.dest = &heartRate
+		}
+	}
+
+	if v := values.Get("sort"); v != "" {
+		query.descending = strings.HasPrefix(v, "-")
+		query.sort = strings.TrimPrefix(v, "-")
+		switch query.sort {
+		case workoutSortTimestamp, workoutSortCaloriesBurned, workoutSortDuration:
+		default:
+			return nil, fmt.Errorf("invalid sort: must be one of %s, %s or %s, optionally prefixed with -",
+				workoutSortTimestamp, workoutSortCaloriesBurned, workoutSortDuration)
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxWorkoutsPageSize {
+			return nil, fmt.Errorf("invalid limit: must be between 1 and %d", maxWorkoutsPageSize)
+		}
+		query.limit = limit
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		cursor, err := decodeWorkoutCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != query.sort || cursor.Descending != query.descending {
+			return nil, fmt.Errorf("cursor does not match the requested sort")
+		}
+		query.after = cursor
+	}
+
+	return query, nil
+}
+
+// workoutMetricsFields are the optional measurements of a workout, which
+// unlike its other fields can be removed by a patch
+var workoutMetricsFields = []string{
+	"distance_meters",
+	"avg_heart_rate",
+	"max_heart_rate",
+	"elevation_gain",
+}
+
+// WorkoutMetrics holds the optional measurements of a workout in requests
+type WorkoutMetrics struct {
+	DistanceMeters *float64 `json:"distance_meters" validate:"min=0"`
+	AvgHeartRate   *int     `json:"avg_heart_rate" validate:"min=20,max=250,ltefield=MaxHeartRate"`
+	MaxHeartRate   *int     `json:"max_heart_rate" validate:"min=20,max=250"`
+	ElevationGain  *float64 `json:"elevation_gain" validate:"min=0"`
+}
+
+// apply sets every measurement of w, clearing the ones left out
+func (m *WorkoutMetrics) apply(w *workout) {
+	w.distanceMeters = m.DistanceMeters
+	w.avgHeartRate = m.AvgHeartRate
+	w.maxHeartRate = m.MaxHeartRate
+	w.elevationGain = m.ElevationGain
+}
+
+// applyPatch sets only the measurements of w named by fields
+func (m *WorkoutMetrics) applyPatch(w *workout, fields []string) {
+	if containsString(fields, "distance_meters") {
+		w.distanceMeters = m.DistanceMeters
+	}
+	if containsString(fields, "avg_heart_rate") {
+		w.avgHeartRate = m.AvgHeartRate
+	}
+	if containsString(fields, "max_heart_rate") {
+		w.maxHeartRate = m.MaxHeartRate
+	}
+	if containsString(fields, "elevation_gain") {
+		w.elevationGain = m.ElevationGain
+	}
+}
+
+// WorkoutMetricsResponse holds the measurements of a workout in responses,
+// along with the pace in seconds per kilometer and the speed in meters per
+// second derived from them
+type WorkoutMetricsResponse struct {
+	DistanceMeters *float64 `json:"distance_meters,omitempty"`
+	AvgHeartRate   *int     `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate   *int     `json:"max_heart_rate,omitempty"`
+	ElevationGain  *float64 `json:"elevation_gain,omitempty"`
+	Pace           *float64 `json:"pace,omitempty"`
+	Speed          *float64 `json:"speed,omitempty"`
+}
+
+func newWorkoutMetricsResponse(w *workout) WorkoutMetricsResponse {
+	return WorkoutMetricsResponse{
+		DistanceMeters: w.distanceMeters,
+		AvgHeartRate:   w.avgHeartRate,
+		MaxHeartRate:   w.maxHeartRate,
+		ElevationGain:  w.elevationGain,
+		Pace:           w.pace(),
+		Speed:          w.speed(),
+	}
+}
+
+// errCaloriesNotEstimable fails a workout without calories_burned that
+// they can't be estimated for either
+var errCaloriesNotEstimable = validationErrors{{
+	Field:   "calories_burned",
+	Code:    validationCodeRequired,
+	Message: "is required unless the activity has a met and the user a weight measurement or weight_kg",
+}}
+
+// controllerEstimateCalories sets the calories burned of w, when they were
+// not given, from the MET of its activity and the body weight of its user
+// at the time
+func controllerEstimateCalories(ctx context.Context, rw http.ResponseWriter, log *logrus.Entry, appData *appData, w *workout) error {
+	activity := &activity{
+		activityID: w.activityID,
+		userID:     w.userID,
+	}
+	err := controllerDatabaseFunc(ctx, rw, activity, activity.Get, log, appData)
+	if err != nil {
+		return err
+	}
+	user := &user{
+		userID: w.userID,
+	}
+	err = controllerDatabaseFunc(ctx, rw, user, user.Get, log, appData)
+	if err != nil {
+		return err
+	}
+
+	weightKg, err := bodyWeightAt(ctx, log, appData, user, w.timestamp)
+	if err != nil {
+		errorMessage := "error getting body weight from database"
+		errorStatusCode := databaseErrorStatusCode(err)
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return fmt.Errorf("error getting body weight: %w", err)
+	}
+
+	met := activity.met
+	if met == nil || weightKg == nil {
+		writeValidationErrorResponse(rw, log, errCaloriesNotEstimable)
+		return fmt.Errorf("cannot estimate calories burned: %w", errCaloriesNotEstimable)
+	}
+	w.caloriesBurned = estimateCaloriesBurned(*met, *weightKg, w.duration)
+	w.caloriesEstimated = true
+	return nil
+}
+
 type PostWorkoutsRequest struct {
-	ActivityID     *string `json:"activity_id"`
-	Timestamp      *string `json:"timestamp"`
-	CaloriesBurned *int    `json:"calories_burned"`
-	Duration       *int64  `json:"duration"`
+	ActivityID     *string `json:"activity_id" validate:"required,notblank"`
+	Timestamp      *string `json:"timestamp" validate:"required,rfc3339,past"`
+	CaloriesBurned *int    `json:"calories_burned" validate:"min=0"`
+	Duration       *int64  `json:"duration" validate:"required,gt=0"`
+	WorkoutMetrics
 }
 
 type PostWorkoutsResponse struct {
-	WorkoutID      string `json:"workout_id"`
-	ActivityID     string `json:"activity_id"`
-	Timestamp      string `json:"timestamp"`
-	CaloriesBurned int    `json:"calories_burned"`
-	Duration       int64  `json:"duration"`
+	WorkoutID         string `json:"workout_id"`
+	ActivityID        string `json:"activity_id"`
+	Timestamp         string `json:"timestamp"`
+	CaloriesBurned    int    `json:"calories_burned"`
+	Duration          int64  `json:"duration"`
+	CaloriesEstimated bool   `json:"calories_estimated"`
+	WorkoutMetricsResponse
+	// CompletedSessionID is the planned program session the workout completed
+	CompletedSessionID *string `json:"completed_session_id,omitempty"`
 }
 
 func getWorkoutsPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/workouts.POST",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		var postWorkoutRequest PostWorkoutsRequest
 		err := controllerDecodeRequest(rw, log, r.Body, &postWorkoutRequest)
 		if err != nil {
 			return
 		}
 
-		err = controllerCheckMissingFields(rw, log,
-			postWorkoutRequest.ActivityID,
-			postWorkoutRequest.CaloriesBurned,
-			postWorkoutRequest.Duration,
-			postWorkoutRequest.Timestamp)
+		err = controllerValidateRequest(rw, log, &postWorkoutRequest)
 		if err != nil {
 			return
 		}
@@ -145,35 +422,60 @@ func getWorkoutsPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.H
 		parsedTime, err := time.Parse(time.RFC3339, *postWorkoutRequest.Timestamp)
 		if err != nil {
 			writeErrorResponse(rw, http.StatusBadRequest, "invalid timestamp format", err)
+			return
+		}
+
+		// check referenced activity exists
+		err = controllerCheckExists(r.Context(), rw, &activity{
+			activityID: *postWorkoutRequest.ActivityID,
+			userID:     userID,
+		}, log, appData)
+		if err != nil {
+			return
 		}
 
 		workout := &workout{
-			workoutID:      uuid.NewString(),
-			activityID:     *postWorkoutRequest.ActivityID,
-			timestamp:      parsedTime,
-			caloriesBurned: *postWorkoutRequest.CaloriesBurned,
-			duration:       time.Duration(*postWorkoutRequest.Duration) * time.Millisecond,
+			workoutID:  uuid.NewString(),
+			userID:     userID,
+			activityID: *postWorkoutRequest.ActivityID,
+			timestamp:  parsedTime,
+			duration:   time.Duration(*postWorkoutRequest.Duration) * time.Millisecond,
+		}
+		if postWorkoutRequest.CaloriesBurned != nil {
+			workout.caloriesBurned = *postWorkoutRequest.CaloriesBurned
+		} else {
+			err = controllerEstimateCalories(r.Context(), rw, log, appData, workout)
+			if err != nil {
+				return
+			}
 		}
+		postWorkoutRequest.WorkoutMetrics.apply(workout)
 
 		// check if row exists
-		// err = controllerCheckExists(rw, workout, log, appData)
+		// err = controllerCheckExists(r.Context(), rw, workout, log, appData)
 		// if err != nil {
 		// 	return
 		// }
 
 		// save from db
-		err = controllerDatabaseFunc(rw, workout, workout.Save, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Save, log, appData)
 		if err != nil {
 			return
 		}
+		completedSessionID := controllerCompleteProgramSession(r.Context(), log, appData, workout)
 
 		response := PostWorkoutsResponse{
-			WorkoutID:      workout.workoutID,
-			ActivityID:     workout.activityID,
-			Timestamp:      workout.timestamp.Format(time.RFC3339),
-			CaloriesBurned: workout.caloriesBurned,
-			Duration:       workout.duration.Milliseconds(),
+			WorkoutID:         workout.workoutID,
+			ActivityID:        workout.activityID,
+			Timestamp:         workout.timestamp.Format(time.RFC3339),
+			CaloriesBurned:    workout.caloriesBurned,
+			Duration:          workout.duration.Milliseconds(),
+			CaloriesEstimated: workout.caloriesEstimated,
+
+			WorkoutMetricsResponse: newWorkoutMetricsResponse(workout),
+			CompletedSessionID:     completedSessionID,
 		}
+		rw.Header().Set("ETag", formatETag(workout.version))
 		err = controllerEncodeResponse(rw, log, http.StatusCreated, response)
 		if err != nil {
 			return
@@ -184,43 +486,55 @@ func getWorkoutsPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.H
 }
 
 type PutWorkoutsRequest struct {
-	ActivityID     *string `json:"activity_id"`
-	Timestamp      *string `json:"timestamp"`
-	CaloriesBurned *int    `json:"calories_burned"`
-	Duration       *int64  `json:"duration"`
+	ActivityID     *string `json:"activity_id" validate:"required,notblank"`
+	Timestamp      *string `json:"timestamp" validate:"required,rfc3339,past"`
+	CaloriesBurned *int    `json:"calories_burned" validate:"min=0"`
+	Duration       *int64  `json:"duration" validate:"required,gt=0"`
+	WorkoutMetrics
 }
 
 func getWorkoutsPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
 			"endpoint":   "/workouts/{id}.PUT",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		workoutID := mux.Vars(r)["id"]
 		workout := &workout{
 			workoutID: workoutID,
+			userID:    userID,
 		}
 
 		// check if row exists
-		err := controllerCheckExists(rw, workout, log, appData)
+		err := controllerCheckExists(r.Context(), rw, workout, log, appData)
 		if err != nil {
 			return
 		}
 
+		// check the client has seen the current version, the write checks it again atomically
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Get, log, appData)
+			if err != nil {
+				return
+			}
+			err = controllerCheckIfMatch(rw, log, workout, ifMatch, workout.version)
+			if err != nil {
+				return
+			}
+		}
+
 		var putWorkoutRequest PutWorkoutsRequest
 		err = controllerDecodeRequest(rw, log, r.Body, &putWorkoutRequest)
 		if err != nil {
 			return
 		}
 
-		err = controllerCheckMissingFields(rw, log,
-			putWorkoutRequest.ActivityID,
-			putWorkoutRequest.Timestamp,
-			putWorkoutRequest.CaloriesBurned,
-			putWorkoutRequest.Duration)
+		err = controllerValidateRequest(rw, log, &putWorkoutRequest)
 		if err != nil {
 			return
 		}
@@ -228,19 +542,171 @@ func getWorkoutsPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.Ha
 		parsedTime, err := time.Parse(time.RFC3339, *putWorkoutRequest.Timestamp)
 		if err != nil {
 			writeErrorResponse(rw, http.StatusBadRequest, "invalid timestamp format", err)
+			return
+		}
+
+		// check referenced activity exists
+		err = controllerCheckExists(r.Context(), rw, &activity{
+			activityID: *putWorkoutRequest.ActivityID,
+			userID:     userID,
+		}, log, appData)
+		if err != nil {
+			return
 		}
 
 		workout.activityID = *putWorkoutRequest.ActivityID
 		workout.timestamp = parsedTime
-		workout.caloriesBurned = *putWorkoutRequest.CaloriesBurned
 		workout.duration = time.Duration(*putWorkoutRequest.Duration) * time.Millisecond
+		if putWorkoutRequest.CaloriesBurned != nil {
+			workout.caloriesBurned = *putWorkoutRequest.CaloriesBurned
+			workout.caloriesEstimated = false
+		} else {
+			err = controllerEstimateCalories(r.Context(), rw, log, appData, workout)
+			if err != nil {
+				return
+			}
+		}
+		putWorkoutRequest.WorkoutMetrics.apply(workout)
 
 		// update in db
-		err = controllerDatabaseFunc(rw, workout, workout.Update, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Update, log, appData)
 		if err != nil {
 			return
 		}
 
+		rw.Header().Set("ETag", formatETag(workout.version))
+		rw.WriteHeader(http.StatusNoContent)
+
+		log.Debug("request completed")
+	}
+}
+
+type PatchWorkoutsRequest struct {
+	ActivityID     *string `json:"activity_id" validate:"notblank"`
+	Timestamp      *string `json:"timestamp" validate:"rfc3339,past"`
+	CaloriesBurned *int    `json:"calories_burned" validate:"min=0"`
+	Duration       *int64  `json:"duration" validate:"gt=0"`
+	WorkoutMetrics
+}
+
+func getWorkoutsPatchHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/{id}.PATCH",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		workoutID := mux.Vars(r)["id"]
+		workout := &workout{
+			workoutID: workoutID,
+			userID:    userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, workout, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db, for JSON patch test operations to compare against
+		err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Get, log, appData)
+		if err != nil {
+			return
+		}
+
+		// check the client has seen the current version, the write checks it again atomically
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			err = controllerCheckIfMatch(rw, log, workout, ifMatch, workout.version)
+			if err != nil {
+				return
+			}
+		}
+
+		var patchWorkoutRequest PatchWorkoutsRequest
+		fields, err := controllerDecodePatch(rw, log, r, GetWorkoutsResponse{
+			WorkoutID:         workout.workoutID,
+			ActivityID:        workout.activityID,
+			Timestamp:         workout.timestamp.Format(time.RFC3339),
+			CaloriesBurned:    workout.caloriesBurned,
+			Duration:          workout.duration.Milliseconds(),
+			CaloriesEstimated: workout.caloriesEstimated,
+
+			WorkoutMetricsResponse: newWorkoutMetricsResponse(workout),
+		}, &patchWorkoutRequest, append([]string{"calories_burned"}, workoutMetricsFields...)...)
+		if err != nil {
+			return
+		}
+
+		err = controllerValidateRequest(rw, log, &patchWorkoutRequest)
+		if err != nil {
+			return
+		}
+
+		if patchWorkoutRequest.Timestamp != nil {
+			parsedTime, err := time.Parse(time.RFC3339, *patchWorkoutRequest.Timestamp)
+			if err != nil {
+				writeErrorResponse(rw, http.StatusBadRequest, "invalid timestamp format", err)
+				return
+			}
+			workout.timestamp = parsedTime
+		}
+		if patchWorkoutRequest.ActivityID != nil {
+			// check referenced activity exists
+			err = controllerCheckExists(r.Context(), rw, &activity{
+				activityID: *patchWorkoutRequest.ActivityID,
+				userID:     userID,
+			}, log, appData)
+			if err != nil {
+				return
+			}
+			workout.activityID = *patchWorkoutRequest.ActivityID
+		}
+		if patchWorkoutRequest.Duration != nil {
+			workout.duration = time.Duration(*patchWorkoutRequest.Duration) * time.Millisecond
+		}
+		// removing the calories estimates them, as does changing what an
+		// estimate was based on: the activity, the duration, or the
+		// timestamp the body weight is taken at
+		switch {
+		case patchWorkoutRequest.CaloriesBurned != nil:
+			workout.caloriesBurned = *patchWorkoutRequest.CaloriesBurned
+			workout.caloriesEstimated = false
+			fields = append(fields, "calories_estimated")
+		case containsString(fields, "calories_burned") || workout.caloriesEstimated &&
+			(containsString(fields, "activity_id") || containsString(fields, "duration") ||
+				containsString(fields, "timestamp")):
+			err = controllerEstimateCalories(r.Context(), rw, log, appData, workout)
+			if err != nil {
+				return
+			}
+			if !containsString(fields, "calories_burned") {
+				fields = append(fields, "calories_burned")
+			}
+			fields = append(fields, "calories_estimated")
+		}
+		patchWorkoutRequest.WorkoutMetrics.applyPatch(workout, fields)
+		// checked against the stored measurements the patch leaves in place
+		err = controllerCheckWorkoutMetrics(rw, log, workout)
+		if err != nil {
+			return
+		}
+
+		// update supplied fields in db, whatever the stored version unless If-Match was given
+		if len(fields) > 0 {
+			if r.Header.Get("If-Match") == "" {
+				workout.version = 0
+			}
+			err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Patch(fields...), log, appData)
+			if err != nil {
+				return
+			}
+		}
+
+		rw.Header().Set("ETag", formatETag(workout.version))
 		rw.WriteHeader(http.StatusNoContent)
 
 		log.Debug("request completed")
@@ -249,26 +715,41 @@ func getWorkoutsPutHandlerFunc(baseLog *logrus.Logger, appData *appData) http.Ha
 
 func getWorkoutsDeleteHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
-		requestID := uuid.NewString()
+		requestID := requestIDFromContext(r.Context())
 		log := baseLog.WithFields(logrus.Fields{
-			"endpoint":   "/activities/{id}.DELETE",
+			"endpoint":   "/workouts/{id}.DELETE",
 			"request_id": requestID,
 		})
 		log.Debug("request received")
 
+		userID := userIDFromContext(r.Context())
+
 		workoutID := mux.Vars(r)["id"]
 		workout := &workout{
 			workoutID: workoutID,
+			userID:    userID,
 		}
 
 		// check if row exists
-		err := controllerCheckExists(rw, workout, log, appData)
+		err := controllerCheckExists(r.Context(), rw, workout, log, appData)
 		if err != nil {
 			return
 		}
 
+		// check the client has seen the current version, the write checks it again atomically
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+			err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Get, log, appData)
+			if err != nil {
+				return
+			}
+			err = controllerCheckIfMatch(rw, log, workout, ifMatch, workout.version)
+			if err != nil {
+				return
+			}
+		}
+
 		// delete from db
-		err = controllerDatabaseFunc(rw, workout, workout.Delete, log, appData)
+		err = controllerDatabaseFunc(r.Context(), rw, workout, workout.Delete, log, appData)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// maxWorkoutsBatchSize bounds the number of workouts a single batch may import
+const maxWorkoutsBatchSize = 5000
+
+var errBatchTooLarge = fmt.Errorf("batch must not contain more than %d items", maxWorkoutsBatchSize)
+
+// maxWorkoutsImportBodySize bounds the size of a batch or CSV import body,
+// which is read before any item is checked against maxWorkoutsBatchSize
+const maxWorkoutsImportBodySize = 8 << 20
+
+var errImportBodyTooLarge = fmt.Errorf("body must not be larger than %d bytes", maxWorkoutsImportBodySize)
+
+type PostWorkoutsBatchResponse struct {
+	Created int                             `json:"created"`
+	Failed  int                             `json:"failed"`
+	Results []PostWorkoutsBatchResponseItem `json:"results"`
+}
+
+type PostWorkoutsBatchResponseItem struct {
+	Index     int    `json:"index"`
+	WorkoutID string `json:"workout_id,omitempty"`
+	// CaloriesEstimated tells an item was created with estimated calories
+	CaloriesEstimated bool `json:"calories_estimated,omitempty"`
+	// CompletedSessionID is the planned program session the workout completed
+	CompletedSessionID *string `json:"completed_session_id,omitempty"`
+	Error              string  `json:"error,omitempty"`
+	// Details lists the invalid fields of an item that failed validation
+	Details []ValidationError `json:"details,omitempty"`
+}
+
+func getWorkoutsBatchPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts:batch.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		limitRequestBody(rw, r, maxWorkoutsImportBodySize, errImportBodyTooLarge)
+		items, err := decodeBatchItems(r.Header.Get("Content-Type"), r.Body, maxWorkoutsBatchSize)
+		if err != nil {
+			errorMessage := "error decoding request body"
+			errorStatusCode := http.StatusBadRequest
+			if errors.Is(err, errBatchTooLarge) || errors.Is(err, errImportBodyTooLarge) {
+				errorStatusCode = http.StatusRequestEntityTooLarge
+			}
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		if len(items) == 0 {
+			errorMessage := "batch contains no workouts"
+			errorStatusCode := http.StatusBadRequest
+
+			log.Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+			return
+		}
+
+		requests := make([]workoutImportItem, len(items))
+		for i, item := range items {
+			requests[i].err = json.Unmarshal(item, &requests[i].request)
+		}
+
+		response, err := controllerImportWorkouts(r.Context(), rw, log, appData, userID, requests)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// workoutImportItem is a single workout to import, or the reason it
+// could not be read
+type workoutImportItem struct {
+	request PostWorkoutsRequest
+	err     error
+}
+
+// controllerImportWorkouts validates every item, saves the valid ones
+// together and reports on each item by its index. like POST /workouts,
+// calories are estimated for items without them, and the workouts complete
+// the program sessions planned for them
+func controllerImportWorkouts(ctx context.Context, rw http.ResponseWriter, log *logrus.Entry, appData *appData, userID string, items []workoutImportItem) (*PostWorkoutsBatchResponse, error) {
+	// load the user's activities and weights once rather than for each item
+	activities, err := controllerDatabaseGetAll(ctx, rw, "activity", log, appData, userID)
+	if err != nil {
+		return nil, err
+	}
+	activityMETs := make(map[string]*float64)
+	for _, a := range activities {
+		activityMETs[a.(*activity).activityID] = a.(*activity).met
+	}
+	user := &user{
+		userID: userID,
+	}
+	err = controllerDatabaseFunc(ctx, rw, user, user.Get, log, appData)
+	if err != nil {
+		return nil, err
+	}
+	weights, err := loadBodyWeights(ctx, log, appData, user)
+	if err != nil {
+		errorMessage := "error getting body weights from database"
+		errorStatusCode := databaseErrorStatusCode(err)
+
+		log.WithError(err).Error(errorMessage)
+		writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+		return nil, fmt.Errorf("error getting body weights: %w", err)
+	}
+
+	response := &PostWorkoutsBatchResponse{
+		Results: make([]PostWorkoutsBatchResponseItem, len(items)),
+	}
+	var workouts []*workout
+	var workoutIndexes []int
+	for i, item := range items {
+		response.Results[i].Index = i
+
+		err := item.err
+		if err == nil {
+			var w *workout
+			w, err = newWorkoutFromRequest(&item.request, userID, activityMETs, weights)
+			if err == nil {
+				response.Results[i].WorkoutID = w.workoutID
+				response.Results[i].CaloriesEstimated = w.caloriesEstimated
+				workouts = append(workouts, w)
+				workoutIndexes = append(workoutIndexes, i)
+				continue
+			}
+		}
+		response.Results[i].Error = err.Error()
+		var errs validationErrors
+		if errors.As(err, &errs) {
+			response.Results[i].Details = errs
+		}
+		response.Failed++
+	}
+
+	// save to db
+	if len(workouts) > 0 {
+		err = saveWorkouts(ctx, log, appData, workouts)
+		if err != nil {
+			errorMessage := "error saving workouts to database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return nil, fmt.Errorf("error saving workouts: %w", err)
+		}
+	}
+	response.Created = len(workouts)
+
+	for i, w := range workouts {
+		response.Results[workoutIndexes[i]].CompletedSessionID = controllerCompleteProgramSession(ctx, log, appData, w)
+	}
+
+	log.WithFields(logrus.Fields{
+		"created": response.Created,
+		"failed":  response.Failed,
+	}).Info("workouts imported")
+
+	return response, nil
+}
+
+// newWorkoutFromRequest validates a workout creation request the way
+// POST /workouts does, checking the referenced activity against the METs
+// of the user's activities, which along with their weights estimate the
+// calories the request leaves out
+func newWorkoutFromRequest(request *PostWorkoutsRequest, userID string, activityMETs map[string]*float64, weights *bodyWeights) (*workout, error) {
+	err := validateRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTime, err := time.Parse(time.RFC3339, *request.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp format: %w", err)
+	}
+
+	met, ok := activityMETs[*request.ActivityID]
+	if !ok {
+		return nil, fmt.Errorf("activity does not exist")
+	}
+
+	w := &workout{
+		workoutID:  uuid.NewString(),
+		userID:     userID,
+		activityID: *request.ActivityID,
+		timestamp:  parsedTime,
+		duration:   time.Duration(*request.Duration) * time.Millisecond,
+	}
+	if request.CaloriesBurned != nil {
+		w.caloriesBurned = *request.CaloriesBurned
+	} else {
+		weightKg := weights.at(w.timestamp)
+		if met == nil || weightKg == nil {
+			return nil, errCaloriesNotEstimable
+		}
+		w.caloriesBurned = estimateCaloriesBurned(*met, *weightKg, w.duration)
+		w.caloriesEstimated = true
+	}
+	request.WorkoutMetrics.apply(w)
+	return w, nil
+}
+
+// decodeBatchItems splits a request body into its JSON items, without
+// decoding them further. the body is either a JSON array or, for the
+// application/x-ndjson content type, one JSON value per line. an invalid
+// line of NDJSON is returned as is, to fail on its own when decoded
+func decodeBatchItems(contentType string, body io.Reader, max int) ([]json.RawMessage, error) {
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content type: %w", err)
+		}
+	}
+
+	var items []json.RawMessage
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson":
+		reader := bufio.NewReader(body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				if len(items) == max {
+					return nil, errBatchTooLarge
+				}
+				items = append(items, json.RawMessage(line))
+			}
+			if err == io.EOF {
+				return items, nil
+			}
+		}
+	default:
+		decoder := json.NewDecoder(body)
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if token != json.Delim('[') {
+			return nil, fmt.Errorf("batch must be a JSON array")
+		}
+		for decoder.More() {
+			if len(items) == max {
+				return nil, errBatchTooLarge
+			}
+			var item json.RawMessage
+			err = decoder.Decode(&item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkoutsBatchPost(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	timestamp := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	rw := s.doJSON("POST", "/v1/workouts:batch", tokens.AccessToken, []interface{}{
+		map[string]interface{}{
+			"activity_id":     activityID,
+			"timestamp":       timestamp,
+			"calories_burned": 300,
+			"duration":        1800000,
+		},
+		map[string]interface{}{
+			"activity_id": activityID,
+			"timestamp":   timestamp,
+			"duration":    -1,
+		},
+		"not a workout",
+	})
+	expectStatus(t, rw, http.StatusOK)
+
+	var response PostWorkoutsBatchResponse
+	decodeBody(t, rw, &response)
+	if response.Created != 1 || response.Failed != 2 || len(response.Results) != 3 {
+		t.Fatalf("expected 1 workout created and 2 failed, got %+v", response)
+	}
+	if response.Results[0].WorkoutID == "" || response.Results[0].Error != "" {
+		t.Fatalf("expected the first workout to be created, got %+v", response.Results[0])
+	}
+	if len(response.Results[1].Details) != 1 || response.Results[1].Details[0].Field != "duration" {
+		t.Fatalf("expected the second workout to fail validation, got %+v", response.Results[1])
+	}
+	if response.Results[2].Error == "" {
+		t.Fatalf("expected the third workout to fail decoding, got %+v", response.Results[2])
+	}
+
+	rw = s.do("GET", "/v1/workouts/"+response.Results[0].WorkoutID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+
+	// newline delimited JSON is read one workout per line
+	ndjson := fmt.Sprintf(`{"activity_id":%q,"timestamp":%q,"calories_burned":200,"duration":600000}`+"\n\n"+
+		`{"activity_id":%q,"timestamp":%q,"calories_burned":250,"duration":900000}`+"\n",
+		activityID, timestamp, activityID, timestamp)
+	rw = s.do("POST", "/v1/workouts:batch", tokens.AccessToken, map[string]string{
+		"Content-Type": "application/x-ndjson",
+	}, strings.NewReader(ndjson))
+	expectStatus(t, rw, http.StatusOK)
+	decodeBody(t, rw, &response)
+	if response.Created != 2 || response.Failed != 0 {
+		t.Fatalf("expected 2 workouts created, got %+v", response)
+	}
+
+	rw = s.doJSON("POST", "/v1/workouts:batch", tokens.AccessToken, []interface{}{})
+	expectStatus(t, rw, http.StatusBadRequest)
+
+	rw = s.doJSON("POST", "/v1/workouts:batch", tokens.AccessToken, map[string]string{})
+	expectStatus(t, rw, http.StatusBadRequest)
+}
+
+func TestWorkoutsImportPost(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	timestamp := time.Now().Add(-time.Hour).UTC()
+
+	csv := "Activity,Start,Calories,Minutes,distance_meters\n" +
+		fmt.Sprintf("%s,%s,300,30,5000\n", activityID, timestamp.Format("2006-01-02 15:04:05")) +
+		fmt.Sprintf("%s,%s,250,not a number,\n", activityID, timestamp.Format("2006-01-02 15:04:05")) +
+		fmt.Sprintf("%s,%s,250,20,\n", activityID, timestamp.Add(48*time.Hour).Format("2006-01-02 15:04:05"))
+	query := "activity_id_column=Activity&timestamp_column=Start&calories_burned_column=Calories" +
+		"&duration_column=Minutes&duration_unit=min&timestamp_format=" + "2006-01-02+15:04:05"
+
+	rw := s.do("POST", "/v1/workouts/import?"+query, tokens.AccessToken, map[string]string{
+		"Content-Type": "text/csv",
+	}, strings.NewReader(csv))
+	expectStatus(t, rw, http.StatusOK)
+
+	var response PostWorkoutsBatchResponse
+	decodeBody(t, rw, &response)
+	if response.Created != 1 || response.Failed != 2 || len(response.Results) != 3 {
+		t.Fatalf("expected 1 workout created and 2 failed, got %+v", response)
+	}
+	if response.Results[1].Error == "" {
+		t.Fatalf("expected the second row to fail parsing, got %+v", response.Results[1])
+	}
+	if len(response.Results[2].Details) != 1 || response.Results[2].Details[0].Code != "in_future" {
+		t.Fatalf("expected the third row to fail validation, got %+v", response.Results[2])
+	}
+
+	rw = s.do("GET", "/v1/workouts/"+response.Results[0].WorkoutID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+
+	var workout GetWorkoutsResponse
+	decodeBody(t, rw, &workout)
+	if workout.Duration != int64(30*time.Minute/time.Millisecond) || workout.CaloriesBurned != 300 ||
+		workout.DistanceMeters == nil || *workout.DistanceMeters != 5000 {
+		t.Fatalf("expected the first row to be imported, got %+v", workout)
+	}
+
+	// a mapped column must be in the file
+	rw = s.do("POST", "/v1/workouts/import?"+query, tokens.AccessToken, map[string]string{
+		"Content-Type": "text/csv",
+	}, strings.NewReader("Activity,Start,Calories\n"))
+	expectStatus(t, rw, http.StatusBadRequest)
+
+	rw = s.do("POST", "/v1/workouts/import?duration_unit=fortnights", tokens.AccessToken, map[string]string{
+		"Content-Type": "text/csv",
+	}, strings.NewReader(csv))
+	expectStatus(t, rw, http.StatusBadRequest)
+}
+
+func TestWorkoutsImportBodyTooLarge(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	huge := strings.Repeat("x", maxWorkoutsImportBodySize)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+	}{
+		{"JSON array item", "/v1/workouts:batch", "application/json", `[{"activity_id":"` + huge + `"}]`},
+		{"NDJSON line", "/v1/workouts:batch", "application/x-ndjson", `{"activity_id":"` + huge + `"}` + "\n"},
+		{"CSV row", "/v1/workouts/import", "text/csv", "activity_id,timestamp,calories_burned,duration\n" + huge + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rw := s.do("POST", test.path, tokens.AccessToken, map[string]string{
+				"Content-Type": test.contentType,
+			}, strings.NewReader(test.body))
+			expectStatus(t, rw, http.StatusRequestEntityTooLarge)
+		})
+	}
+}
+
+func TestWorkoutsBatchPostEstimatesAndCompletesSessions(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	rw := s.doJSON("POST", "/v1/activities", tokens.AccessToken, map[string]string{
+		"name": "Underwater basket weaving",
+	})
+	expectStatus(t, rw, http.StatusCreated)
+	var uncommon PostActivitiesResponse
+	decodeBody(t, rw, &uncommon)
+
+	rw = s.doJSON("PUT", "/v1/users/"+tokens.UserID, tokens.AccessToken, map[string]interface{}{
+		"name":      "Ada",
+		"email":     "ada@example.com",
+		"weight_kg": 70,
+	})
+	expectStatus(t, rw, http.StatusNoContent)
+
+	yesterday := time.Now().UTC().Add(-24 * time.Hour)
+	program := s.createProgram(tokens.AccessToken, activityID, yesterday, 1)
+	timestamp := time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), 7, 0, 0, 0, time.UTC).Format(time.RFC3339)
+
+	rw = s.doJSON("POST", "/v1/workouts:batch", tokens.AccessToken, []interface{}{
+		map[string]interface{}{
+			"activity_id": activityID,
+			"timestamp":   timestamp,
+			"duration":    int64(time.Hour / time.Millisecond),
+		},
+		map[string]interface{}{
+			"activity_id": uncommon.ActivityID,
+			"timestamp":   timestamp,
+			"duration":    int64(time.Hour / time.Millisecond),
+		},
+	})
+	expectStatus(t, rw, http.StatusOK)
+
+	var response PostWorkoutsBatchResponse
+	decodeBody(t, rw, &response)
+	if response.Created != 1 || response.Failed != 1 {
+		t.Fatalf("expected 1 workout created and 1 failed, got %+v", response)
+	}
+	created := response.Results[0]
+	if !created.CaloriesEstimated || created.CompletedSessionID == nil ||
+		*created.CompletedSessionID != program.Sessions[0].SessionID {
+		t.Fatalf("expected estimated calories and a completed session, got %+v", created)
+	}
+	if details := response.Results[1].Details; len(details) != 1 || details[0].Field != "calories_burned" {
+		t.Fatalf("expected calories that can't be estimated to be required, got %+v", response.Results[1])
+	}
+
+	rw = s.do("GET", "/v1/workouts/"+created.WorkoutID, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	var workout GetWorkoutsResponse
+	decodeBody(t, rw, &workout)
+	if workout.CaloriesBurned != 686 {
+		t.Fatalf("expected 686 estimated kcal, got %d", workout.CaloriesBurned)
+	}
+}
+
+func TestBodyWeightsAt(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC)
+	}
+	measured := &bodyWeights{
+		measurements: []*measurement{
+			{value: 80, measuredAt: day(10)},
+			{value: 75, measuredAt: day(20)},
+		},
+		profile: floatPointer(90),
+	}
+
+	tests := []struct {
+		name     string
+		weights  *bodyWeights
+		at       time.Time
+		expected *float64
+	}{
+		{"before any measurement is the earliest", measured, day(1), floatPointer(80)},
+		{"at a measurement is that one", measured, day(20), floatPointer(75)},
+		{"between measurements is the latest by then", measured, day(15), floatPointer(80)},
+		{"after every measurement is the last", measured, day(31), floatPointer(75)},
+		{"without measurements is the profile's", &bodyWeights{profile: floatPointer(90)}, day(1), floatPointer(90)},
+		{"without either is unknown", &bodyWeights{}, day(1), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.weights.at(test.at)
+			if (got == nil) != (test.expected == nil) || got != nil && *got != *test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
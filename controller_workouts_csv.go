@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// workoutsExportPageSize is how many workouts an export reads from the
+// database at a time while streaming
+const workoutsExportPageSize = 500
+
+var workoutsCSVHeader = []string{
+	"workout_id",
+	"activity_id",
+	"activity_name",
+	"timestamp",
+	"calories_burned",
+	"duration",
+	"distance_meters",
+	"avg_heart_rate",
+	"max_heart_rate",
+	"elevation_gain",
+}
+
+func getWorkoutsExportGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/export.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		if format := r.URL.Query().Get("format"); format != "" && format != "csv" {
+			errorMessage := "unsupported export format"
+			errorStatusCode := http.StatusBadRequest
+
+			log.Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, fmt.Errorf("format must be csv"))
+			return
+		}
+
+		// an export is the whole of the filtered history, not a page of it
+		for _, param := range []string{"limit", "cursor"} {
+			if r.URL.Query().Get(param) != "" {
+				errorMessage := "invalid query parameter"
+				errorStatusCode := http.StatusBadRequest
+
+				log.Error(errorMessage)
+				writeErrorResponse(rw, errorStatusCode, errorMessage, fmt.Errorf("%s is not supported by exports", param))
+				return
+			}
+		}
+
+		// the same filters and ordering as listing workouts
+		query, err := parseWorkoutQuery(r, userID)
+		if err != nil {
+			errorMessage := "invalid query parameter"
+			errorStatusCode := http.StatusBadRequest
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		query.limit = workoutsExportPageSize
+
+		activities, err := controllerDatabaseGetAll(r.Context(), rw, "activity", log, appData, userID)
+		if err != nil {
+			return
+		}
+		activityNames := make(map[string]string)
+		for _, a := range activities {
+			activityNames[a.(*activity).activityID] = a.(*activity).name
+		}
+
+		// read the first page before committing to a successful response
+		workouts, next, err := controllerDatabaseQueryWorkouts(r.Context(), rw, query, log, appData)
+		if err != nil {
+			return
+		}
+
+		rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		rw.Header().Set("Content-Disposition", `attachment; filename="workouts.csv"`)
+		rw.WriteHeader(http.StatusOK)
+
+		writer := csv.NewWriter(rw)
+		writer.Write(workoutsCSVHeader)
+		for {
+			for _, w := range workouts {
+				writer.Write([]string{
+					w.workoutID,
+					w.activityID,
+					activityNames[w.activityID],
+					w.timestamp.Format(time.RFC3339),
+					strconv.Itoa(w.caloriesBurned),
+					strconv.FormatInt(w.duration.Milliseconds(), 10),
+					formatOptionalFloat(w.distanceMeters),
+					formatOptionalInt(w.avgHeartRate),
+					formatOptionalInt(w.maxHeartRate),
+					formatOptionalFloat(w.elevationGain),
+				})
+			}
+			writer.Flush()
+			if f, ok := rw.(http.Flusher); ok {
+				f.Flush()
+			}
+			if err = writer.Error(); err != nil || next == "" {
+				break
+			}
+
+			query.after, err = decodeWorkoutCursor(next)
+			if err != nil {
+				break
+			}
+			workouts, next, err = queryWorkouts(r.Context(), log, appData, query)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			// the status is already sent, all that is left is to cut the export short
+			log.WithError(err).Error("error streaming workouts export")
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// formatOptionalFloat leaves the cell of a measurement that was not recorded empty
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func formatOptionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+// workoutsCSVImportOptions says how to read the rows of an imported CSV file.
+// columns must be in the file, while optionalColumns are read when present
+type workoutsCSVImportOptions struct {
+	columns         map[string]string
+	optionalColumns map[string]string
+	timestampFormat string
+	// location is the time zone of timestamps that don't give their own
+	location     *time.Location
+	durationUnit time.Duration
+}
+
+func parseWorkoutsCSVImportOptions(params url.Values) (*workoutsCSVImportOptions, error) {
+	options := &workoutsCSVImportOptions{
+		columns:         make(map[string]string),
+		optionalColumns: make(map[string]string),
+		timestampFormat: time.RFC3339,
+		location:        time.UTC,
+		durationUnit:    time.Millisecond,
+	}
+
+	// each field is read from the column of the same name, unless mapped by <field>_column
+	for _, field := range []string{"activity_id", "timestamp", "calories_burned", "duration"} {
+		options.columns[field] = field
+		if column := params.Get(field + "_column"); column != "" {
+			options.columns[field] = column
+		}
+	}
+	// the measurements are optional, unless explicitly mapped
+	for _, field := range workoutMetricsFields {
+		options.optionalColumns[field] = field
+		if column := params.Get(field + "_column"); column != "" {
+			options.columns[field] = column
+			delete(options.optionalColumns, field)
+		}
+	}
+
+	// unix, unix_ms or a go reference time layout, such as 2006-01-02 15:04:05
+	if format := params.Get("timestamp_format"); format != "" && format != "rfc3339" {
+		options.timestampFormat = format
+	}
+	// the time zone of layouts without one, UTC unless given
+	if tz := params.Get("tz"); tz != "" {
+		location, err := loadTimeZone(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tz: %w", err)
+		}
+		options.location = location
+	}
+
+	durationUnits := map[string]time.Duration{
+		"ms":  time.Millisecond,
+		"s":   time.Second,
+		"min": time.Minute,
+		"h":   time.Hour,
+	}
+	if unit := params.Get("duration_unit"); unit != "" {
+		d, ok := durationUnits[unit]
+		if !ok {
+			return nil, fmt.Errorf("duration_unit must be one of ms, s, min or h")
+		}
+		options.durationUnit = d
+	}
+
+	return options, nil
+}
+
+// parseTimestamp reads a timestamp in the configured format, as RFC 3339
+func (o *workoutsCSVImportOptions) parseTimestamp(value string) (string, error) {
+	var t time.Time
+	switch o.timestampFormat {
+	case "unix", "unix_ms":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid timestamp format: %w", err)
+		}
+		if o.timestampFormat == "unix" {
+			t = time.Unix(n, 0)
+		} else {
+			t = time.Unix(0, n*int64(time.Millisecond))
+		}
+	default:
+		var err error
+		t, err = time.ParseInLocation(o.timestampFormat, value, o.location)
+		if err != nil {
+			return "", fmt.Errorf("invalid timestamp format: %w", err)
+		}
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
+// parseDuration reads a duration in the configured unit, as milliseconds
+func (o *workoutsCSVImportOptions) parseDuration(value string) (int64, error) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %w", err)
+	}
+	return time.Duration(n * float64(o.durationUnit)).Milliseconds(), nil
+}
+
+// readRow turns a CSV row into the request creating a workout
+func (o *workoutsCSVImportOptions) readRow(columnIndexes map[string]int, row []string) (PostWorkoutsRequest, error) {
+	var request PostWorkoutsRequest
+
+	value := func(field string) (string, bool) {
+		column, ok := o.columns[field]
+		if !ok {
+			column = o.optionalColumns[field]
+		}
+		i, ok := columnIndexes[column]
+		if !ok || i >= len(row) || strings.TrimSpace(row[i]) == "" {
+			return "", false
+		}
+		return strings.TrimSpace(row[i]), true
+	}
+
+	if v, ok := value("activity_id"); ok {
+		request.ActivityID = &v
+	}
+	if v, ok := value("timestamp"); ok {
+		timestamp, err := o.parseTimestamp(v)
+		if err != nil {
+			return request, err
+		}
+		request.Timestamp = &timestamp
+	}
+	if v, ok := value("calories_burned"); ok {
+		caloriesBurned, err := strconv.Atoi(v)
+		if err != nil {
+			return request, fmt.Errorf("invalid calories_burned: %w", err)
+		}
+		request.CaloriesBurned = &caloriesBurned
+	}
+	if v, ok := value("duration"); ok {
+		duration, err := o.parseDuration(v)
+		if err != nil {
+			return request, err
+		}
+		request.Duration = &duration
+	}
+	for _, field := range []struct {
+		name string
+		dest **float64
+	}{
+		{"distance_meters", &request.DistanceMeters},
+		{"elevation_gain", &request.ElevationGain},
+	} {
+		if v, ok := value(field.name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return request, fmt.Errorf("invalid %s: %w", field.name, err)
+			}
+			*field.dest = &f
+		}
+	}
+	for _, field := range []struct {
+		name string
+		dest **int
+	}{
+		{"avg_heart_rate", &request.AvgHeartRate},
+		{"max_heart_rate", &request.MaxHeartRate},
+	} {
+		if v, ok := value(field.name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return request, fmt.Errorf("invalid %s: %w", field.name, err)
+			}
+			*field.dest = &n
+		}
+	}
+	return request, nil
+}
+
+func getWorkoutsImportPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/import.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		options, err := parseWorkoutsCSVImportOptions(r.URL.Query())
+		if err != nil {
+			errorMessage := "invalid query parameter"
+			errorStatusCode := http.StatusBadRequest
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		limitRequestBody(rw, r, maxWorkoutsImportBodySize, errImportBodyTooLarge)
+		items, err := readWorkoutsCSV(r.Body, options)
+		if err != nil {
+			errorMessage := "error decoding request body"
+			errorStatusCode := http.StatusBadRequest
+			if errors.Is(err, errBatchTooLarge) || errors.Is(err, errImportBodyTooLarge) {
+				errorStatusCode = http.StatusRequestEntityTooLarge
+			}
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		if len(items) == 0 {
+			errorMessage := "file contains no workouts"
+			errorStatusCode := http.StatusBadRequest
+
+			log.Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+			return
+		}
+
+		// results are indexed by data row, not counting the header
+		response, err := controllerImportWorkouts(r.Context(), rw, log, appData, userID, items)
+		if err != nil {
+			return
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// readWorkoutsCSV reads the header row and then one workout per row.
+// malformed CSV fails as a whole, while rows that can't be turned into a
+// workout are returned with their error
+func readWorkoutsCSV(body io.Reader, options *workoutsCSVImportOptions) ([]workoutImportItem, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columnIndexes := make(map[string]int)
+	for i, column := range header {
+		// spreadsheets tend to start the file with a byte order mark
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		columnIndexes[strings.TrimSpace(column)] = i
+	}
+	for field, column := range options.columns {
+		if _, ok := columnIndexes[column]; !ok {
+			return nil, fmt.Errorf("missing column %q for %s", column, field)
+		}
+	}
+
+	var items []workoutImportItem
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == maxWorkoutsBatchSize {
+			return nil, errBatchTooLarge
+		}
+
+		var item workoutImportItem
+		item.request, item.err = options.readRow(columnIndexes, row)
+		items = append(items, item)
+	}
+}
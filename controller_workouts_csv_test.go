@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkoutsCSVImportParseTimestamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		value    string
+		expected string
+	}{
+		{"RFC 3339 by default", "", "2026-03-01T08:30:00+01:00", "2026-03-01T07:30:00Z"},
+		{"unix seconds", "timestamp_format=unix", "1772353800", "2026-03-01T08:30:00Z"},
+		{"unix milliseconds", "timestamp_format=unix_ms", "1772353800500", "2026-03-01T08:30:00.5Z"},
+		{"layout without a zone is UTC", "timestamp_format=2006-01-02+15:04", "2026-03-01 08:30", "2026-03-01T08:30:00Z"},
+		{"layout without a zone in tz", "timestamp_format=2006-01-02+15:04&tz=Europe/Berlin", "2026-03-01 08:30", "2026-03-01T07:30:00Z"},
+		{"tz across daylight saving time", "timestamp_format=2006-01-02+15:04&tz=Europe/Berlin", "2026-07-01 08:30", "2026-07-01T06:30:00Z"},
+		{"layout with a zone keeps it", "timestamp_format=2006-01-02+15:04+-0700&tz=Europe/Berlin", "2026-03-01 08:30 -0500", "2026-03-01T13:30:00Z"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := url.ParseQuery(test.params)
+			if err != nil {
+				t.Fatalf("invalid params: %v", err)
+			}
+			options, err := parseWorkoutsCSVImportOptions(params)
+			if err != nil {
+				t.Fatalf("cannot parse options: %v", err)
+			}
+
+			got, err := options.parseTimestamp(test.value)
+			if err != nil {
+				t.Fatalf("cannot parse timestamp: %v", err)
+			}
+			if got != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestWorkoutsCSVImportOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{"unknown duration unit", "duration_unit=fortnights"},
+		{"unknown time zone", "tz=Mars/Olympus_Mons"},
+		{"local time zone", "tz=Local"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := url.ParseQuery(test.params)
+			if err != nil {
+				t.Fatalf("invalid params: %v", err)
+			}
+			if _, err := parseWorkoutsCSVImportOptions(params); err == nil {
+				t.Fatalf("expected the options to be rejected")
+			}
+		})
+	}
+}
+
+func TestWorkoutsExportGet(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	start := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	for i := 0; i < 3; i++ {
+		s.createWorkout(tokens.AccessToken, activityID, start.Add(time.Duration(i)*time.Hour))
+	}
+
+	rw := s.do("GET", "/v1/workouts/export", tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	if contentType := rw.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/csv") {
+		t.Fatalf("expected CSV, got %s", contentType)
+	}
+	rows, err := csv.NewReader(rw.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != strings.Join(workoutsCSVHeader, ",") {
+		t.Fatalf("expected a header and 3 workouts, got %v", rows)
+	}
+	if rows[1][2] != "Running" || rows[1][3] != start.Add(2*time.Hour).UTC().Format(time.RFC3339) {
+		t.Fatalf("expected the newest workout first, got %v", rows[1])
+	}
+
+	for _, query := range []string{"limit=2", "cursor=abc", "format=xlsx"} {
+		t.Run(query, func(t *testing.T) {
+			rw := s.do("GET", "/v1/workouts/export?"+query, tokens.AccessToken, nil, nil)
+			expectStatus(t, rw, http.StatusBadRequest)
+		})
+	}
+}
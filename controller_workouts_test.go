@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkoutsPostValidation(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+
+	rw := s.doJSON("POST", "/v1/workouts", tokens.AccessToken, map[string]interface{}{
+		"activity_id":     activityID,
+		"timestamp":       time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+		"calories_burned": -1,
+		"duration":        0,
+	})
+	expectValidationErrors(t, rw, map[string]string{
+		"timestamp":       "in_future",
+		"calories_burned": "too_small",
+		"duration":        "too_small",
+	})
+
+	rw = s.doJSON("POST", "/v1/workouts", tokens.AccessToken, map[string]interface{}{
+		"timestamp": "yesterday",
+	})
+	expectValidationErrors(t, rw, map[string]string{
+		"activity_id": "required",
+		"timestamp":   "invalid_format",
+		"duration":    "required",
+	})
+
+	// the activity is checked once the request is valid
+	rw = s.doJSON("POST", "/v1/workouts", tokens.AccessToken, map[string]interface{}{
+		"activity_id": "00000000-0000-0000-0000-000000000000",
+		"timestamp":   time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		"duration":    1000,
+	})
+	expectStatus(t, rw, http.StatusNotFound)
+}
+
+func TestWorkoutsGetAllPagination(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+
+	start := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	created := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		workout := s.createWorkout(tokens.AccessToken, activityID, start.Add(time.Duration(i)*time.Hour))
+		created[workout.WorkoutID] = true
+	}
+
+	// another user's workouts are never listed
+	other := s.signup("grace@example.com")
+	s.createWorkout(other.AccessToken, s.createActivity(other.AccessToken), start)
+
+	var timestamps []string
+	seen := make(map[string]bool)
+	path := "/v1/workouts?limit=2"
+	for pages := 0; path != ""; pages++ {
+		if pages == 3 {
+			t.Fatalf("expected 3 pages, got more")
+		}
+
+		rw := s.do("GET", path, tokens.AccessToken, nil, nil)
+		expectStatus(t, rw, http.StatusOK)
+
+		var page GetAllWorkoutsResponse
+		decodeBody(t, rw, &page)
+		if len(page) == 0 || len(page) > 2 {
+			t.Fatalf("expected 1 or 2 workouts per page, got %d", len(page))
+		}
+		for _, workout := range page {
+			if !created[workout.WorkoutID] || seen[workout.WorkoutID] {
+				t.Fatalf("unexpected workout %s on page %d", workout.WorkoutID, pages)
+			}
+			seen[workout.WorkoutID] = true
+			timestamps = append(timestamps, workout.Timestamp)
+		}
+
+		path = ""
+		if cursor := rw.Header().Get("X-Next-Cursor"); cursor != "" {
+			if !strings.Contains(rw.Header().Get("Link"), `rel="next"`) {
+				t.Fatalf("expected a next link alongside the cursor, got %q", rw.Header().Get("Link"))
+			}
+			path = "/v1/workouts?limit=2&cursor=" + url.QueryEscape(cursor)
+		}
+	}
+
+	if len(seen) != len(created) {
+		t.Fatalf("expected all %d workouts to be listed, got %d", len(created), len(seen))
+	}
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i-1] <= timestamps[i] {
+			t.Fatalf("expected workouts newest first, got %v", timestamps)
+		}
+	}
+
+	// a cursor only continues the sort it was issued for
+	rw := s.do("GET", "/v1/workouts?limit=2", tokens.AccessToken, nil, nil)
+	cursor := rw.Header().Get("X-Next-Cursor")
+	rw = s.do("GET", "/v1/workouts?limit=2&sort=duration&cursor="+url.QueryEscape(cursor), tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusBadRequest)
+
+	rw = s.do("GET", "/v1/workouts?cursor=garbage", tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusBadRequest)
+}
+
+func TestWorkoutsPatch(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	workout := s.createWorkout(tokens.AccessToken, activityID, time.Now().Add(-time.Hour))
+	path := "/v1/workouts/" + workout.WorkoutID
+
+	rw := s.do("GET", path, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	etag := rw.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag")
+	}
+
+	// a merge patch removes measurements with null
+	rw = s.do("PATCH", path, tokens.AccessToken, map[string]string{
+		"Content-Type": "application/merge-patch+json",
+		"If-Match":     etag,
+	}, strings.NewReader(`{"calories_burned": 450, "distance_meters": 5000}`))
+	expectStatus(t, rw, http.StatusNoContent)
+	mergedETag := rw.Header().Get("ETag")
+	if mergedETag == "" || mergedETag == etag {
+		t.Fatalf("expected the ETag to change from %s, got %q", etag, mergedETag)
+	}
+
+	rw = s.do("PATCH", path, tokens.AccessToken, map[string]string{
+		"Content-Type": "application/merge-patch+json",
+	}, strings.NewReader(`{"distance_meters": null}`))
+	expectStatus(t, rw, http.StatusNoContent)
+	mergedETag = rw.Header().Get("ETag")
+
+	rw = s.do("GET", path, tokens.AccessToken, nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	var patched GetWorkoutsResponse
+	decodeBody(t, rw, &patched)
+	if patched.CaloriesBurned != 450 || patched.DistanceMeters != nil {
+		t.Fatalf("expected the merge patches to be applied, got %+v", patched)
+	}
+
+	// the version the client has seen is stale
+	rw = s.do("PATCH", path, tokens.AccessToken, map[string]string{
+		"Content-Type": "application/merge-patch+json",
+		"If-Match":     etag,
+	}, strings.NewReader(`{"calories_burned": 500}`))
+	expectStatus(t, rw, http.StatusPreconditionFailed)
+
+	// a JSON patch fails as a whole if any test fails
+	rw = s.do("PATCH", path, tokens.AccessToken, map[string]string{
+		"Content-Type": "application/json-patch+json",
+		"If-Match":     mergedETag,
+	}, strings.NewReader(`[
+		{"op": "test", "path": "/calories_burned", "value": 300},
+		{"op": "replace", "path": "/calories_burned", "value": 500}
+	]`))
+	expectStatus(t, rw, http.StatusConflict)
+
+	rw = s.do("PATCH", path, tokens.AccessToken, map[string]string{
+		"Content-Type": "application/json-patch+json",
+		"If-Match":     mergedETag,
+	}, strings.NewReader(`[
+		{"op": "test", "path": "/calories_burned", "value": 450},
+		{"op": "replace", "path": "/calories_burned", "value": 500}
+	]`))
+	expectStatus(t, rw, http.StatusNoContent)
+
+	rw = s.do("PATCH", path, tokens.AccessToken, map[string]string{
+		"Content-Type": "application/json-patch+json",
+	}, strings.NewReader(`[{"op": "replace", "path": "/duration", "value": -5}]`))
+	expectValidationErrors(t, rw, map[string]string{
+		"duration": "too_small",
+	})
+
+	rw = s.do("PATCH", path, tokens.AccessToken, map[string]string{
+		"Content-Type": "text/plain",
+	}, strings.NewReader(`calories_burned=500`))
+	expectStatus(t, rw, http.StatusUnsupportedMediaType)
+
+	rw = s.do("GET", path, tokens.AccessToken, map[string]string{
+		"If-None-Match": mergedETag,
+	}, nil)
+	expectStatus(t, rw, http.StatusOK)
+	decodeBody(t, rw, &patched)
+	if patched.CaloriesBurned != 500 {
+		t.Fatalf("expected the JSON patch to be applied, got %+v", patched)
+	}
+
+	rw = s.do("GET", path, tokens.AccessToken, map[string]string{
+		"If-None-Match": rw.Header().Get("ETag"),
+	}, nil)
+	expectStatus(t, rw, http.StatusNotModified)
+}
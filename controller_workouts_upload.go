@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// maxWorkoutUploadSize bounds the size of an uploaded activity file
+const maxWorkoutUploadSize = 32 << 20
+
+var errUploadTooLarge = fmt.Errorf("file must not be larger than %d bytes", maxWorkoutUploadSize)
+
+type PostWorkoutsUploadResponse struct {
+	WorkoutID         string `json:"workout_id"`
+	ActivityID        string `json:"activity_id"`
+	Timestamp         string `json:"timestamp"`
+	CaloriesBurned    int    `json:"calories_burned"`
+	CaloriesEstimated bool   `json:"calories_estimated"`
+	Duration          int64  `json:"duration"`
+	Trackpoints       int    `json:"trackpoints"`
+	WorkoutMetricsResponse
+	CompletedSessionID *string `json:"completed_session_id,omitempty"`
+}
+
+func getWorkoutsUploadPostHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/upload.POST",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		params := r.URL.Query()
+		activityID := params.Get("activity_id")
+		if activityID == "" {
+			errorMessage := "missing field from request"
+			errorStatusCode := http.StatusBadRequest
+
+			log.Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, fmt.Errorf("activity_id query parameter is required"))
+			return
+		}
+		// only used when the file does not record calories itself
+		var fallbackCalories *int
+		if v := params.Get("calories_burned"); v != "" {
+			caloriesBurned, err := strconv.Atoi(v)
+			if err != nil {
+				errorMessage := "invalid query parameter"
+				errorStatusCode := http.StatusBadRequest
+
+				log.WithError(err).Error(errorMessage)
+				writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+				return
+			}
+			fallbackCalories = &caloriesBurned
+		}
+
+		data, err := readUploadedFile(r)
+		if err != nil {
+			errorMessage := "error reading uploaded file"
+			errorStatusCode := http.StatusBadRequest
+			if errors.Is(err, errUploadTooLarge) {
+				errorStatusCode = http.StatusRequestEntityTooLarge
+			}
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		format := strings.ToLower(params.Get("format"))
+		if format == "" {
+			format, err = detectTrackFormat(data)
+		}
+		var track *parsedTrack
+		if err == nil {
+			track, err = parseTrack(format, data)
+		}
+		if err != nil {
+			errorMessage := "error parsing uploaded file"
+			errorStatusCode := http.StatusUnprocessableEntity
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		// hold the workout of the file to the rules of POST /workouts
+		timestamp := track.start.Format(time.RFC3339)
+		duration := track.duration.Round(time.Millisecond).Milliseconds()
+		caloriesBurned := track.caloriesBurned
+		if caloriesBurned == nil {
+			caloriesBurned = fallbackCalories
+		}
+		workoutRequest := PostWorkoutsRequest{
+			ActivityID:     &activityID,
+			Timestamp:      &timestamp,
+			CaloriesBurned: caloriesBurned,
+			Duration:       &duration,
+			WorkoutMetrics: WorkoutMetrics{
+				DistanceMeters: track.distanceMeters,
+				AvgHeartRate:   track.avgHeartRate,
+				MaxHeartRate:   track.maxHeartRate,
+				ElevationGain:  track.elevationGain,
+			},
+		}
+		err = controllerValidateRequest(rw, log, &workoutRequest)
+		if err != nil {
+			return
+		}
+
+		// check referenced activity exists
+		err = controllerCheckExists(r.Context(), rw, &activity{
+			activityID: activityID,
+			userID:     userID,
+		}, log, appData)
+		if err != nil {
+			return
+		}
+
+		workout := &workout{
+			workoutID:  uuid.NewString(),
+			userID:     userID,
+			activityID: activityID,
+			timestamp:  track.start,
+			duration:   time.Duration(duration) * time.Millisecond,
+		}
+		if caloriesBurned != nil {
+			workout.caloriesBurned = *caloriesBurned
+		} else {
+			err = controllerEstimateCalories(r.Context(), rw, log, appData, workout)
+			if err != nil {
+				return
+			}
+		}
+		workoutRequest.WorkoutMetrics.apply(workout)
+
+		// save to db
+		err = saveWorkoutWithTrackpoints(r.Context(), log, appData, workout, track.trackpoints)
+		if err != nil {
+			errorMessage := "error saving workout to database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+		completedSessionID := controllerCompleteProgramSession(r.Context(), log, appData, workout)
+
+		response := PostWorkoutsUploadResponse{
+			WorkoutID:         workout.workoutID,
+			ActivityID:        workout.activityID,
+			Timestamp:         workout.timestamp.Format(time.RFC3339),
+			CaloriesBurned:    workout.caloriesBurned,
+			CaloriesEstimated: workout.caloriesEstimated,
+			Duration:          workout.duration.Milliseconds(),
+			Trackpoints:       len(track.trackpoints),
+
+			WorkoutMetricsResponse: newWorkoutMetricsResponse(workout),
+			CompletedSessionID:     completedSessionID,
+		}
+		rw.Header().Set("ETag", formatETag(workout.version))
+		err = controllerEncodeResponse(rw, log, http.StatusCreated, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
+
+// readUploadedFile returns the uploaded file, sent either as the whole
+// request body or as the "file" part of a multipart form
+func readUploadedFile(r *http.Request) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return readAllLimited(r.Body)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing file part in form")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" {
+			return readAllLimited(part)
+		}
+	}
+}
+
+func readAllLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxWorkoutUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxWorkoutUploadSize {
+		return nil, errUploadTooLarge
+	}
+	return data, nil
+}
+
+type GetWorkoutsTrackResponse []GetWorkoutsTrackResponseItem
+type GetWorkoutsTrackResponseItem struct {
+	Time            *string  `json:"time,omitempty"`
+	Latitude        *float64 `json:"latitude,omitempty"`
+	Longitude       *float64 `json:"longitude,omitempty"`
+	ElevationMeters *float64 `json:"elevation_meters,omitempty"`
+	HeartRate       *int     `json:"heart_rate,omitempty"`
+}
+
+func getWorkoutsTrackGetHandlerFunc(baseLog *logrus.Logger, appData *appData) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		requestID := requestIDFromContext(r.Context())
+		log := baseLog.WithFields(logrus.Fields{
+			"endpoint":   "/workouts/{id}/track.GET",
+			"request_id": requestID,
+		})
+		log.Debug("request received")
+
+		userID := userIDFromContext(r.Context())
+
+		workoutID := mux.Vars(r)["id"]
+		workout := &workout{
+			workoutID: workoutID,
+			userID:    userID,
+		}
+
+		// check if row exists
+		err := controllerCheckExists(r.Context(), rw, workout, log, appData)
+		if err != nil {
+			return
+		}
+
+		// get from db
+		trackpoints, err := getTrackpoints(r.Context(), log, appData, workout)
+		if err != nil {
+			errorMessage := "error getting trackpoints from database"
+			errorStatusCode := databaseErrorStatusCode(err)
+
+			log.WithError(err).Error(errorMessage)
+			writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+			return
+		}
+
+		response := GetWorkoutsTrackResponse{}
+		for _, t := range trackpoints {
+			item := GetWorkoutsTrackResponseItem{
+				Latitude:        t.latitude,
+				Longitude:       t.longitude,
+				ElevationMeters: t.elevationMeters,
+				HeartRate:       t.heartRate,
+			}
+			if t.time != nil {
+				formatted := t.time.Format(time.RFC3339)
+				item.Time = &formatted
+			}
+			response = append(response, item)
+		}
+
+		err = controllerEncodeResponse(rw, log, http.StatusOK, response)
+		if err != nil {
+			return
+		}
+
+		log.Debug("request completed")
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
@@ -43,22 +42,3 @@ func initDatabase(log *logrus.Logger, config *config) (*pgxpool.Pool, error) {
 	log.Debug("database initialized")
 	return db, nil
 }
-
-func initDatabaseSchema(log *logrus.Logger, db *pgxpool.Pool) error {
-	log.Debug("initializing database schema")
-
-	// get queries from sql file
-	schemaFileBytes, err := ioutil.ReadFile("/app/schema.sql")
-	if err != nil {
-		return err
-	}
-
-	// execute queries in file
-	_, err = db.Exec(context.Background(), string(schemaFileBytes))
-	if err != nil {
-		return err
-	}
-
-	log.Debug("database schema initialized")
-	return nil
-}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// formatETag returns the strong entity tag of a row version
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// etagListMatches reports whether etag is among the comma separated entity
+// tags of an If-Match or If-None-Match header, or the header is *. weak
+// tags are only matched when weak comparison is asked for (RFC 7232)
+func etagListMatches(list string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
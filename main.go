@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
 	log := initLogger()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			err := runMigrateCommand(log, os.Args[2:])
+			if err != nil {
+				log.WithError(err).Fatalln("cannot run migrate command")
+			}
+		default:
+			log.Fatalf("unknown command: %s", os.Args[1])
+		}
+		return
+	}
+
 	log.Info("starting program")
 
 	appData, err := initAppData(log)
@@ -18,18 +34,36 @@ func main() {
 	}
 
 	log.Infoln("starting API server")
-	listenAndServe(log, appData)
+	server, serverErrors := listenAndServe(log, appData)
 
 	log.Infoln("blocking until signalled to shutdown")
-	// make channel for interrupt signal
+	// make channel for interrupt and termination signals
 	c := make(chan os.Signal, 1)
 	// tell os to send to chan when signal received
-	signal.Notify(c, os.Interrupt)
-	// wait for signal
-	<-c
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	// wait for signal, or for the server to fail on its own
+	exitCode := 0
+	select {
+	case sig := <-c:
+		log.WithField("signal", sig.String()).Infoln("shutting down")
+	case err := <-serverErrors:
+		log.WithError(err).Errorln("API server failed, shutting down")
+		exitCode = 1
+	}
+
+	// stop accepting connections and let in-flight requests drain
+	ctx, cancel := context.WithTimeout(context.Background(), appData.config.server.shutdownTimeout)
+	err = server.Shutdown(ctx)
+	cancel()
+	if err != nil {
+		log.WithError(err).Errorln("cannot drain in-flight requests before shutdown deadline")
+	}
+
+	// only close the repository once nothing can use it anymore
+	appData.repository.Close()
 
-	log.Infoln("shutting down")
-	os.Exit(0)
+	log.Infoln("shut down")
+	os.Exit(exitCode)
 }
 
 func initLogger() *logrus.Logger {
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const metricsNamespace = "digital_trainer"
+
+type metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests          *prometheus.CounterVec
+	httpRequestDuration   *prometheus.HistogramVec
+	databaseEventDuration *prometheus.HistogramVec
+}
+
+func initMetrics(log *logrus.Logger, db *pgxpool.Pool) (*metrics, error) {
+	log.Debug("initializing metrics")
+
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests handled, by endpoint and status code.",
+		}, []string{"endpoint", "code"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by endpoint.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint"}),
+		databaseEventDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "database",
+			Name:      "event_duration_seconds",
+			Help:      "Time taken by persistence events, by entity and event.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"entity", "event"}),
+	}
+
+	collectors := []prometheus.Collector{
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpRequestDuration,
+		m.databaseEventDuration,
+	}
+	// the in-memory repository has no pool
+	if db != nil {
+		collectors = append(collectors, newPoolCollector(db))
+	}
+	for _, c := range collectors {
+		err := m.registry.Register(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Debug("metrics initialized")
+	return m, nil
+}
+
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// observeDatabaseEvent records the time since start against the entity and
+// event fields of the persistence event's log entry
+func (m *metrics) observeDatabaseEvent(log *logrus.Entry, start time.Time) {
+	if m == nil {
+		return
+	}
+	entity, _ := log.Data["entity"].(string)
+	event, _ := log.Data["event"].(string)
+	m.databaseEventDuration.WithLabelValues(entity, event).Observe(time.Since(start).Seconds())
+}
+
+// getMetricsMiddleware counts and times requests by the same endpoint names
+// the handlers log, such as /workouts/{id}.GET
+func getMetricsMiddleware(appData *appData) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecordingResponseWriter{
+				ResponseWriter: rw,
+				statusCode:     http.StatusOK,
+			}
+
+			next.ServeHTTP(recorder, r)
+
+			endpoint := metricsEndpointName(r)
+			appData.metrics.httpRequests.WithLabelValues(endpoint, strconv.Itoa(recorder.statusCode)).Inc()
+			appData.metrics.httpRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+func metricsEndpointName(r *http.Request) string {
+	template := "unknown"
+	if route := mux.CurrentRoute(r); route != nil {
+		if t, err := route.GetPathTemplate(); err == nil {
+			template = strings.TrimPrefix(t, "/v1")
+		}
+	}
+	return template + "." + r.Method
+}
+
+type statusRecordingResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (w *statusRecordingResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.statusCode = statusCode
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusRecordingResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush through the recorder
+func (w *statusRecordingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// poolCollector exports the connection pool's statistics at scrape time
+type poolCollector struct {
+	db *pgxpool.Pool
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+}
+
+func newPoolCollector(db *pgxpool.Pool) *poolCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "database_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		db:                   db,
+		acquiredConns:        desc("acquired_connections", "Number of connections currently acquired from the pool."),
+		idleConns:            desc("idle_connections", "Number of idle connections in the pool."),
+		constructingConns:    desc("constructing_connections", "Number of connections being established."),
+		totalConns:           desc("total_connections", "Total number of connections in the pool."),
+		maxConns:             desc("max_connections", "Maximum size of the pool."),
+		acquireCount:         desc("acquires_total", "Number of successful connection acquires."),
+		acquireDuration:      desc("acquire_wait_seconds_total", "Total time spent waiting to acquire connections."),
+		emptyAcquireCount:    desc("empty_acquires_total", "Number of acquires that had to wait for a connection."),
+		canceledAcquireCount: desc("canceled_acquires_total", "Number of acquires cancelled by their context."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.constructingConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquireCount
+	ch <- c.canceledAcquireCount
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.db.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsLabels(t *testing.T) {
+	s := newTestServer(t)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	workout := s.createWorkout(tokens.AccessToken, activityID, time.Now().Add(-time.Hour))
+
+	requests := []struct {
+		method string
+		path   string
+		token  string
+	}{
+		{"GET", "/v1/workouts/" + workout.WorkoutID, tokens.AccessToken},
+		{"GET", "/v1/workouts/" + workout.WorkoutID, tokens.AccessToken},
+		{"GET", "/v1/workouts/00000000-0000-0000-0000-000000000000", tokens.AccessToken},
+		{"GET", "/v1/workouts", ""},
+		{"DELETE", "/v1/activities/" + activityID, tokens.AccessToken},
+		{"GET", "/healthz", ""},
+	}
+	for _, request := range requests {
+		s.do(request.method, request.path, request.token, nil, nil)
+	}
+
+	rw := s.do("GET", "/metrics", "", nil, nil)
+	expectStatus(t, rw, http.StatusOK)
+	exposition := rw.Body.String()
+
+	tests := []struct {
+		name   string
+		series string
+	}{
+		{"routes by path template without the version prefix", `digital_trainer_http_requests_total{code="200",endpoint="/workouts/{id}.GET"} 2`},
+		{"status codes of errors", `digital_trainer_http_requests_total{code="404",endpoint="/workouts/{id}.GET"} 1`},
+		{"status codes of rejected requests", `digital_trainer_http_requests_total{code="401",endpoint="/workouts.GET"} 1`},
+		{"status codes of conflicts", `digital_trainer_http_requests_total{code="409",endpoint="/activities/{id}.DELETE"} 1`},
+		{"routes outside of the version prefix", `digital_trainer_http_requests_total{code="200",endpoint="/healthz.GET"} 1`},
+		{"latencies by endpoint", `digital_trainer_http_request_duration_seconds_count{endpoint="/workouts/{id}.GET"} 3`},
+		{"persistence events by entity and event", `digital_trainer_database_event_duration_seconds_count{entity="workout",event="get"} 2`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !strings.Contains(exposition, test.series+"\n") {
+				t.Fatalf("expected the series %s, got:\n%s", test.series, exposition)
+			}
+		})
+	}
+}
+
+func TestStatusRecordingResponseWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(http.ResponseWriter)
+		expected int
+	}{
+		{"nothing written is a 200", func(rw http.ResponseWriter) {}, http.StatusOK},
+		{"a body alone is a 200", func(rw http.ResponseWriter) { rw.Write([]byte("{}")) }, http.StatusOK},
+		{"the written status code", func(rw http.ResponseWriter) { rw.WriteHeader(http.StatusCreated) }, http.StatusCreated},
+		{"the first status code written", func(rw http.ResponseWriter) {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusNotFound},
+		{"no status code after the body", func(rw http.ResponseWriter) {
+			rw.Write([]byte("{}"))
+			rw.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := &statusRecordingResponseWriter{
+				ResponseWriter: httptest.NewRecorder(),
+				statusCode:     http.StatusOK,
+			}
+			test.write(recorder)
+			if recorder.statusCode != test.expected {
+				t.Fatalf("expected status %d, got %d", test.expected, recorder.statusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey string
+
+const (
+	userIDContextKey    contextKey = "user_id"
+	requestIDContextKey contextKey = "request_id"
+	connContextKey      contextKey = "conn"
+)
+
+// requestIDHeader echoes the id of a request in its response
+const requestIDHeader = "X-Request-ID"
+
+func contextWithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey, userID)
+}
+
+// userIDFromContext returns the id of the user making the request. it is
+// only populated on routes behind the authentication middleware
+func userIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDContextKey).(string)
+	return userID
+}
+
+func contextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// requestIDFromContext returns the id of the request, which its logs and
+// error response refer to. it is only populated on routes behind the
+// request id middleware
+func requestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+func contextWithConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey, conn)
+}
+
+// connFromContext returns the connection the request was read from. it is
+// only populated for requests served by the API server
+func connFromContext(ctx context.Context) net.Conn {
+	conn, _ := ctx.Value(connContextKey).(net.Conn)
+	return conn
+}
+
+// requestResponseWriter carries what error responses need to know about
+// the request they answer
+type requestResponseWriter struct {
+	http.ResponseWriter
+	requestID   string
+	errorFormat string
+}
+
+// Flush lets streaming handlers flush through the writer
+func (w *requestResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// getRequestIDMiddleware identifies every request, for its logs and its
+// response to refer to, and has errors written in the configured format
+func getRequestIDMiddleware(appData *appData) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			requestID := uuid.NewString()
+			rw.Header().Set(requestIDHeader, requestID)
+
+			next.ServeHTTP(&requestResponseWriter{
+				ResponseWriter: rw,
+				requestID:      requestID,
+				errorFormat:    appData.config.server.errorFormat,
+			}, r.WithContext(contextWithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+// getTimeoutMiddleware bounds the time a request may take. once it has
+// passed, the request context is cancelled along with any database queries.
+// streaming routes have a deadline of their own, as they read or write
+// their bodies as they go, which also replaces the server's read and write
+// timeouts on their connection
+func getTimeoutMiddleware(appData *appData) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			timeout := appData.config.server.requestTimeout
+			if isStreamingRoute(r) {
+				timeout = appData.config.server.streamTimeout
+				if conn := connFromContext(r.Context()); conn != nil {
+					var deadline time.Time
+					if timeout > 0 {
+						deadline = time.Now().Add(timeout)
+					}
+					conn.SetDeadline(deadline)
+					// the server only resets the deadlines it has timeouts for
+					// before the next request on the connection
+					defer conn.SetDeadline(time.Time{})
+				}
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
+// getAuthenticationMiddleware rejects requests without a valid access token
+// and identifies the user making the request for downstream handlers
+func getAuthenticationMiddleware(baseLog *logrus.Logger, appData *appData) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			log := baseLog.WithFields(logrus.Fields{
+				"middleware": "authentication",
+				"request_id": requestIDFromContext(r.Context()),
+			})
+
+			authorization := r.Header.Get("Authorization")
+			if !strings.HasPrefix(authorization, "Bearer ") {
+				errorMessage := "missing bearer token"
+				errorStatusCode := http.StatusUnauthorized
+
+				log.Error(errorMessage)
+				rw.Header().Set("WWW-Authenticate", "Bearer")
+				writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+				return
+			}
+
+			userID, err := parseAccessToken(appData.config.auth, strings.TrimPrefix(authorization, "Bearer "))
+			if err != nil {
+				errorMessage := "invalid bearer token"
+				errorStatusCode := http.StatusUnauthorized
+
+				log.WithError(err).Error(errorMessage)
+				rw.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+				return
+			}
+
+			// tokens outlive deleted users, so make sure the user is still around
+			user := &user{
+				userID: userID,
+			}
+			exists, err := user.Exists(r.Context(), log, appData)
+			if err != nil {
+				errorMessage := "error checking user existence in database"
+				errorStatusCode := databaseErrorStatusCode(err)
+
+				log.WithError(err).Error(errorMessage)
+				writeErrorResponse(rw, errorStatusCode, errorMessage, err)
+				return
+			}
+			if !exists {
+				errorMessage := "unknown user"
+				errorStatusCode := http.StatusUnauthorized
+
+				log.Error(errorMessage)
+				writeErrorResponse(rw, errorStatusCode, errorMessage, nil)
+				return
+			}
+
+			next.ServeHTTP(rw, r.WithContext(contextWithUserID(r.Context(), userID)))
+		})
+	}
+}
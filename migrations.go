@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed resources/migrations/*.sql
+var migrationFiles embed.FS
+
+const migrationsDir = "resources/migrations"
+
+// migrationLockKey is the postgres advisory lock held while migrating,
+// so that replicas starting at the same time don't race each other
+const migrationLockKey int64 = 7243911520
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type migration struct {
+	version int64
+	name    string
+	up      string
+	down    string
+}
+
+type migrationStatus struct {
+	version   int64
+	name      string
+	appliedAt *time.Time
+}
+
+func loadMigrations() ([]*migration, error) {
+	return readMigrations(migrationFiles, migrationsDir)
+}
+
+// readMigrations pairs up the up and down files of each migration in dir,
+// in version order
+func readMigrations(files fs.FS, dir string) ([]*migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationsByVersion := make(map[int64]*migration)
+	for _, entry := range entries {
+		matches := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		contents, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := migrationsByVersion[version]
+		if !ok {
+			m = &migration{
+				version: version,
+				name:    matches[2],
+			}
+			migrationsByVersion[version] = m
+		}
+		if m.name != matches[2] {
+			return nil, fmt.Errorf("conflicting names for migration %d: %s and %s", version, m.name, matches[2])
+		}
+		switch matches[3] {
+		case "up":
+			m.up = string(contents)
+		case "down":
+			m.down = string(contents)
+		}
+	}
+
+	var migrations []*migration
+	for _, m := range migrationsByVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both an up and a down file", m.version, m.name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// withMigrationLock runs f on a single connection holding the migration
+// advisory lock, after making sure the schema_migrations table exists
+func withMigrationLock(ctx context.Context, log *logrus.Logger, db *pgxpool.Pool, f func(*pgxpool.Conn) error) error {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	log.Debug("acquiring migration lock")
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey)
+	if err != nil {
+		return fmt.Errorf("cannot acquire migration lock: %w", err)
+	}
+	defer func() {
+		_, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+		if err != nil {
+			log.WithError(err).Error("cannot release migration lock")
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+		)`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations table: %w", err)
+	}
+
+	return f(conn)
+}
+
+func getAppliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, `
+		SELECT
+			version,
+			applied_at
+		FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// migrateUp applies every pending migration in order
+func migrateUp(ctx context.Context, log *logrus.Logger, db *pgxpool.Pool) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, log, db, func(conn *pgxpool.Conn) error {
+		applied, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.version]; ok {
+				continue
+			}
+			mLog := log.WithFields(logrus.Fields{
+				"version": m.version,
+				"name":    m.name,
+			})
+			mLog.Info("applying migration")
+
+			err = conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, m.up)
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(ctx, `
+					INSERT INTO schema_migrations (
+						version,
+						name
+					) VALUES ($1,$2)`,
+					m.version,
+					m.name,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("cannot apply migration %d_%s: %w", m.version, m.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// migrateDown reverts the given number of most recently applied migrations
+func migrateDown(ctx context.Context, log *logrus.Logger, db *pgxpool.Pool, steps int) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	return withMigrationLock(ctx, log, db, func(conn *pgxpool.Conn) error {
+		applied, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.version]; !ok {
+				continue
+			}
+			mLog := log.WithFields(logrus.Fields{
+				"version": m.version,
+				"name":    m.name,
+			})
+			mLog.Info("reverting migration")
+
+			err = conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, m.down)
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(ctx, `
+					DELETE FROM schema_migrations
+					WHERE version = $1`,
+					m.version,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("cannot revert migration %d_%s: %w", m.version, m.name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// getMigrationStatus lists every known migration along with when it was applied, if ever
+func getMigrationStatus(ctx context.Context, log *logrus.Logger, db *pgxpool.Pool) ([]*migrationStatus, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*migrationStatus
+	err = withMigrationLock(ctx, log, db, func(conn *pgxpool.Conn) error {
+		applied, err := getAppliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			status := &migrationStatus{
+				version: m.version,
+				name:    m.name,
+			}
+			if appliedAt, ok := applied[m.version]; ok {
+				status.appliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// getPendingMigrationCount returns how many known migrations have not been
+// applied yet. unlike the other migration functions it does not take the
+// migration lock, so it never waits on a replica that is migrating
+func getPendingMigrationCount(ctx context.Context, db *pgxpool.Pool) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	applied, err := getAppliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; !ok {
+			pending++
+		}
+	}
+	return pending, nil
+}
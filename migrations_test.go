@@ -0,0 +1,99 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("cannot load migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatalf("expected migrations to be embedded")
+	}
+	for i, m := range migrations {
+		if m.version != int64(i+1) {
+			t.Fatalf("expected migration %d to be version %d, got %d_%s", i, i+1, m.version, m.name)
+		}
+	}
+}
+
+var (
+	migrationCreatedRegexp = regexp.MustCompile(`(?i)CREATE TABLE (?:IF NOT EXISTS )?(\w+)|ADD COLUMN (\w+)`)
+	migrationDroppedRegexp = regexp.MustCompile(`(?i)DROP TABLE (?:IF EXISTS )?(\w+)|DROP COLUMN (\w+)`)
+)
+
+// TestMigrationsDownRevertUp checks that every table and column created by
+// a migration is dropped again by its down file
+func TestMigrationsDownRevertUp(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("cannot load migrations: %v", err)
+	}
+	for _, m := range migrations {
+		t.Run(m.name, func(t *testing.T) {
+			dropped := make(map[string]bool)
+			for _, matches := range migrationDroppedRegexp.FindAllStringSubmatch(m.down, -1) {
+				dropped[strings.ToLower(matches[1]+matches[2])] = true
+			}
+			for _, matches := range migrationCreatedRegexp.FindAllStringSubmatch(m.up, -1) {
+				if created := strings.ToLower(matches[1] + matches[2]); !dropped[created] {
+					t.Errorf("expected the down file to drop %s", created)
+				}
+			}
+		})
+	}
+}
+
+func TestReadMigrations(t *testing.T) {
+	file := &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	tests := []struct {
+		name     string
+		files    []string
+		versions []int64
+		valid    bool
+	}{
+		{"ordered by version", []string{
+			"0010_goals.up.sql", "0010_goals.down.sql",
+			"0002_users.up.sql", "0002_users.down.sql",
+			"0001_initial.up.sql", "0001_initial.down.sql",
+		}, []int64{1, 2, 10}, true},
+		{"missing a down file", []string{"0001_initial.up.sql"}, nil, false},
+		{"missing an up file", []string{"0001_initial.down.sql"}, nil, false},
+		{"conflicting names", []string{"0001_initial.up.sql", "0001_first.down.sql"}, nil, false},
+		{"invalid name", []string{"initial.up.sql", "initial.down.sql"}, nil, false},
+		{"neither up nor down", []string{"0001_initial.sql"}, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			files := fstest.MapFS{}
+			for _, name := range test.files {
+				files["migrations/"+name] = file
+			}
+
+			migrations, err := readMigrations(files, "migrations")
+			if !test.valid {
+				if err == nil {
+					t.Fatalf("expected the migrations to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("cannot read migrations: %v", err)
+			}
+			if len(migrations) != len(test.versions) {
+				t.Fatalf("expected %d migrations, got %d", len(test.versions), len(migrations))
+			}
+			for i, m := range migrations {
+				if m.version != test.versions[i] || m.up == "" || m.down == "" {
+					t.Fatalf("expected migration %d with both files, got %+v", test.versions[i], m)
+				}
+			}
+		})
+	}
+}
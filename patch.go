@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	contentTypeMergePatch = "application/merge-patch+json"
+	contentTypeJSONPatch  = "application/json-patch+json"
+)
+
+// patchOperation is a single RFC 6902 JSON patch operation
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// decodePatch reads a JSON merge patch (RFC 7396) or, depending on the
+// content type, a JSON patch (RFC 6902) into the top level members it sets.
+// current is the resource as it is returned by GET, which JSON patch test
+// operations are checked against. only top level members can be patched
+func decodePatch(contentType string, body io.Reader, current interface{}) (map[string]json.RawMessage, error) {
+	mediaType := "application/json"
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content type: %w", err)
+		}
+	}
+
+	switch mediaType {
+	case contentTypeMergePatch, "application/json":
+		members := make(map[string]json.RawMessage)
+		err := json.NewDecoder(body).Decode(&members)
+		if err != nil {
+			return nil, fmt.Errorf("merge patch must be a JSON object: %w", err)
+		}
+		return members, nil
+	case contentTypeJSONPatch:
+		var operations []patchOperation
+		err := json.NewDecoder(body).Decode(&operations)
+		if err != nil {
+			return nil, fmt.Errorf("JSON patch must be an array of operations: %w", err)
+		}
+		return applyPatchOperations(operations, current)
+	default:
+		return nil, errUnsupportedPatchType
+	}
+}
+
+var (
+	errUnsupportedPatchType = fmt.Errorf("content type must be one of %s or %s", contentTypeMergePatch, contentTypeJSONPatch)
+	errPatchTestFailed      = errors.New("test failed")
+)
+
+func applyPatchOperations(operations []patchOperation, current interface{}) (map[string]json.RawMessage, error) {
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	document := make(map[string]json.RawMessage)
+	err = json.Unmarshal(currentJSON, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make(map[string]json.RawMessage)
+	for i, operation := range operations {
+		member := strings.TrimPrefix(operation.Path, "/")
+		if !strings.HasPrefix(operation.Path, "/") || member == "" || strings.Contains(member, "/") {
+			return nil, fmt.Errorf("operation %d: path must refer to a top level member", i)
+		}
+		member = strings.NewReplacer("~1", "/", "~0", "~").Replace(member)
+
+		switch operation.Op {
+		case "add", "replace":
+			if operation.Value == nil {
+				return nil, fmt.Errorf("operation %d: missing value", i)
+			}
+			members[member] = operation.Value
+			document[member] = operation.Value
+		case "remove":
+			members[member] = json.RawMessage("null")
+			delete(document, member)
+		case "test":
+			equal, err := jsonEqual(document[member], operation.Value)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %w", i, err)
+			}
+			if !equal {
+				return nil, fmt.Errorf("operation %d: %w for %s", i, errPatchTestFailed, operation.Path)
+			}
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, operation.Op)
+		}
+	}
+	return members, nil
+}
+
+func jsonEqual(a json.RawMessage, b json.RawMessage) (bool, error) {
+	if a == nil || b == nil {
+		return a == nil && b == nil, nil
+	}
+	var av, bv interface{}
+	err := json.Unmarshal(a, &av)
+	if err != nil {
+		return false, err
+	}
+	err = json.Unmarshal(b, &bv)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(av, bv), nil
+}
+
+// decodePatchMembers decodes the patched members into v, a request struct
+// of pointer fields, and returns the names of the members that were set.
+// none of the members may be unknown to v, nor null unless they are among
+// the nullable ones, which are then left nil in v
+func decodePatchMembers(members map[string]json.RawMessage, v interface{}, nullable ...string) ([]string, error) {
+	var fields []string
+	for member, value := range members {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) && !containsString(nullable, member) {
+			return nil, fmt.Errorf("%s cannot be removed", member)
+		}
+		fields = append(fields, member)
+	}
+	sort.Strings(fields)
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,113 @@
 package main
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"errors"
+	"fmt"
 
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// persistenceObject methods take the context of the request they serve,
+// so that queries are cancelled along with it
 type persistenceObject interface {
-	Save(*logrus.Entry, *appData) error
-	Get(*logrus.Entry, *appData) error
-	Update(*logrus.Entry, *appData) error
-	Delete(*logrus.Entry, *appData) error
-	Exists(*logrus.Entry, *appData) (bool, error)
+	Save(context.Context, *logrus.Entry, *appData) error
+	Get(context.Context, *logrus.Entry, *appData) error
+	Update(context.Context, *logrus.Entry, *appData) error
+	Delete(context.Context, *logrus.Entry, *appData) error
+	Exists(context.Context, *logrus.Entry, *appData) (bool, error)
 
 	Type() string
 }
+
+// errVersionMismatch is returned by conditional writes when the stored
+// version has changed since the object was read
+var errVersionMismatch = errors.New("stored version does not match")
+
+// kinds of persistence errors, told apart with errors.Is
+var (
+	errNotFound            = errors.New("not found")
+	errConflict            = errors.New("conflict")
+	errForeignKeyViolation = errors.New("foreign key violation")
+	errUniqueViolation     = errors.New("unique violation")
+	errInvalidData         = errors.New("invalid data")
+	errTimeout             = errors.New("timeout")
+)
+
+// persistenceError is an error of the storage backend of a known kind. it
+// reads as the underlying error, which it unwraps to
+type persistenceError struct {
+	kind error
+	err  error
+}
+
+// newPersistenceError returns an error of kind with the given message
+func newPersistenceError(kind error, format string, args ...interface{}) error {
+	return &persistenceError{
+		kind: kind,
+		err:  fmt.Errorf(format, args...),
+	}
+}
+
+func (e *persistenceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *persistenceError) Unwrap() error {
+	return e.err
+}
+
+func (e *persistenceError) Is(target error) bool {
+	return target == e.kind
+}
+
+// postgres error codes classified as a kind of persistence error
+var pgErrorKinds = map[string]error{
+	"23503": errForeignKeyViolation, // foreign_key_violation
+	"23505": errUniqueViolation,     // unique_violation
+	"23502": errInvalidData,         // not_null_violation
+	"23514": errInvalidData,         // check_violation
+	"22001": errInvalidData,         // string_data_right_truncation
+	"22003": errInvalidData,         // numeric_value_out_of_range
+	"22P02": errInvalidData,         // invalid_text_representation
+	"40001": errConflict,            // serialization_failure
+	"40P01": errConflict,            // deadlock_detected
+	"55P03": errTimeout,             // lock_not_available
+	"57014": errTimeout,             // query_canceled
+}
+
+// persistenceErrorOf classifies an error of the storage backend by the kind
+// it is, leaving errors of unknown kinds and of the memory backend, which
+// are classified already, as they are
+func persistenceErrorOf(err error) error {
+	var pe *persistenceError
+	if err == nil || errors.As(err, &pe) {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return &persistenceError{kind: errNotFound, err: err}
+	case errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err):
+		return &persistenceError{kind: errTimeout, err: err}
+	case errors.As(err, &pgErr):
+		if kind, ok := pgErrorKinds[pgErr.Code]; ok {
+			return &persistenceError{kind: kind, err: err}
+		}
+	}
+	return err
+}
+
+// persistenceDeleteErrorOf classifies an error of a delete. a delete
+// violating a foreign key conflicts with the rows still referencing the
+// deleted one, while other writes violating one reference a missing row
+func persistenceDeleteErrorOf(err error) error {
+	err = persistenceErrorOf(err)
+	if errors.Is(err, errForeignKeyViolation) {
+		return &persistenceError{kind: errConflict, err: errors.Unwrap(err)}
+	}
+	return err
+}
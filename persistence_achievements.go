@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	achievementFirstWorkout               = "first_workout"
+	achievementStreak7Days                = "streak_7_days"
+	achievementWorkouts100                = "workouts_100"
+	achievementPersonalBestDuration       = "personal_best_duration"
+	achievementPersonalBestCaloriesBurned = "personal_best_calories_burned"
+)
+
+// achievement is earned by a user's workout. personal bests are earned per
+// activity by the best workout so far, with its value in milliseconds or
+// kilocalories
+type achievement struct {
+	userID     string
+	code       string
+	activityID *string
+	workoutID  string
+	value      *int64
+	earnedAt   time.Time
+}
+
+// achievementRule awards achievements out of all of a user's workouts,
+// sorted by timestamp
+type achievementRule func(workouts []*workout) []*achievement
+
+var achievementRules = []achievementRule{
+	workoutCountRule(achievementFirstWorkout, 1),
+	workoutCountRule(achievementWorkouts100, 100),
+	streakRule(achievementStreak7Days, 7),
+	personalBestRule(achievementPersonalBestDuration, func(w *workout) int64 {
+		return w.duration.Milliseconds()
+	}),
+	personalBestRule(achievementPersonalBestCaloriesBurned, func(w *workout) int64 {
+		return int64(w.caloriesBurned)
+	}),
+}
+
+type achievementRepository interface {
+	// UpdateAchievements replaces the user's achievements with the ones
+	// evaluated from their workouts, so that concurrent changes to the
+	// workouts cannot leave stale achievements behind
+	UpdateAchievements(ctx context.Context, userID string, evaluate func([]*workout) []*achievement) error
+	// GetAchievements returns the user's achievements in the order earned
+	GetAchievements(ctx context.Context, userID string) ([]*achievement, error)
+}
+
+// evaluateAchievements applies every rule to the user's workouts, sorted
+// by timestamp
+func evaluateAchievements(workouts []*workout) []*achievement {
+	sort.SliceStable(workouts, func(i, j int) bool {
+		return workouts[i].timestamp.Before(workouts[j].timestamp)
+	})
+	var achievements []*achievement
+	for _, rule := range achievementRules {
+		achievements = append(achievements, rule(workouts)...)
+	}
+	return achievements
+}
+
+// workoutCountRule is earned by the count-th workout
+func workoutCountRule(code string, count int) achievementRule {
+	return func(workouts []*workout) []*achievement {
+		if len(workouts) < count {
+			return nil
+		}
+		w := workouts[count-1]
+		return []*achievement{{
+			userID:    w.userID,
+			code:      code,
+			workoutID: w.workoutID,
+			earnedAt:  w.timestamp,
+		}}
+	}
+}
+
+// streakRule is earned by the first workout on the last of days
+// consecutive days with workouts, in UTC
+func streakRule(code string, days int) achievementRule {
+	return func(workouts []*workout) []*achievement {
+		streak := 0
+		var lastDay time.Time
+		for _, w := range workouts {
+			day := truncateToPeriod(w.timestamp, statsGroupByDay, time.UTC)
+			switch {
+			case streak > 0 && day.Equal(lastDay):
+				continue
+			case streak > 0 && day.Equal(lastDay.AddDate(0, 0, 1)):
+				streak++
+			default:
+				streak = 1
+			}
+			lastDay = day
+			if streak == days {
+				return []*achievement{{
+					userID:    w.userID,
+					code:      code,
+					workoutID: w.workoutID,
+					earnedAt:  w.timestamp,
+				}}
+			}
+		}
+		return nil
+	}
+}
+
+// personalBestRule is earned for every activity by its earliest workout
+// with the highest value
+func personalBestRule(code string, value func(*workout) int64) achievementRule {
+	return func(workouts []*workout) []*achievement {
+		var activityIDs []string
+		bests := make(map[string]*workout)
+		for _, w := range workouts {
+			best, ok := bests[w.activityID]
+			if !ok {
+				activityIDs = append(activityIDs, w.activityID)
+			}
+			if !ok || value(w) > value(best) {
+				bests[w.activityID] = w
+			}
+		}
+
+		var achievements []*achievement
+		for _, activityID := range activityIDs {
+			w := bests[activityID]
+			activityID := activityID
+			best := value(w)
+			achievements = append(achievements, &achievement{
+				userID:     w.userID,
+				code:       code,
+				activityID: &activityID,
+				workoutID:  w.workoutID,
+				value:      &best,
+				earnedAt:   w.timestamp,
+			})
+		}
+		return achievements
+	}
+}
+
+// updateAchievements re-evaluates the user's achievements after a change to
+// their workouts. the change is stored by then, so failing to do so is only
+// logged, and caught up with on the next change
+func updateAchievements(ctx context.Context, baseLog *logrus.Entry, appData *appData, userID string) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "achievement",
+		"event":  "update",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.UpdateAchievements(ctx, userID, evaluateAchievements)
+	if err != nil {
+		log.WithError(err).Warn("error updating achievements")
+		return
+	}
+
+	log.Trace("database event completed")
+}
+
+func getAchievements(ctx context.Context, baseLog *logrus.Entry, appData *appData, userID string) ([]*achievement, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "achievement",
+		"event":  "get all",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	achievements, err := appData.repository.GetAchievements(ctx, userID)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return achievements, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWorkout is a workout of activityID on day of March 2026 at hour UTC
+func testWorkout(workoutID string, activityID string, day int, hour int, minutes int, calories int) *workout {
+	return &workout{
+		workoutID:      workoutID,
+		userID:         "u",
+		activityID:     activityID,
+		timestamp:      time.Date(2026, 3, day, hour, 0, 0, 0, time.UTC),
+		duration:       time.Duration(minutes) * time.Minute,
+		caloriesBurned: calories,
+	}
+}
+
+// testWorkoutsHourly returns count running workouts an hour apart
+func testWorkoutsHourly(count int) []*workout {
+	var workouts []*workout
+	for i := 1; i <= count; i++ {
+		w := testWorkout(fmt.Sprintf("w%d", i), "running", 1, 0, 30, 300)
+		w.timestamp = w.timestamp.Add(time.Duration(i) * time.Hour)
+		workouts = append(workouts, w)
+	}
+	return workouts
+}
+
+// testWorkoutsDaily returns a running workout at noon on each of days
+func testWorkoutsDaily(days ...int) []*workout {
+	var workouts []*workout
+	for _, day := range days {
+		workouts = append(workouts, testWorkout(fmt.Sprintf("d%d", day), "running", day, 12, 30, 300))
+	}
+	return workouts
+}
+
+func TestEvaluateAchievements(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		workouts []*workout
+		expected string
+	}{
+		{"no first workout without workouts", achievementFirstWorkout, nil, ""},
+		{"first workout is the earliest", achievementFirstWorkout, []*workout{
+			testWorkout("w2", "running", 2, 8, 30, 300),
+			testWorkout("w1", "running", 1, 8, 30, 300),
+		}, "w1"},
+		{"no 100 workouts at 99", achievementWorkouts100, testWorkoutsHourly(99), ""},
+		{"100 workouts at the 100th", achievementWorkouts100, testWorkoutsHourly(120), "w100"},
+		{"no streak over 6 days", achievementStreak7Days, testWorkoutsDaily(1, 2, 3, 4, 5, 6), ""},
+		{"streak on the 7th day", achievementStreak7Days, testWorkoutsDaily(1, 2, 3, 4, 5, 6, 7, 8), "d7"},
+		{"streak after a gap", achievementStreak7Days, testWorkoutsDaily(1, 2, 3, 5, 6, 7, 8, 9, 10, 11), "d11"},
+		{"streak by the first workout of the day", achievementStreak7Days, append(testWorkoutsDaily(1, 2, 3, 4, 5, 6),
+			testWorkout("late", "running", 7, 20, 30, 300),
+			testWorkout("early", "running", 7, 6, 30, 300),
+		), "early"},
+		{"no streak from several workouts a day", achievementStreak7Days, append(testWorkoutsDaily(1, 2, 3),
+			testWorkout("w1", "running", 1, 6, 30, 300),
+			testWorkout("w2", "running", 2, 6, 30, 300),
+			testWorkout("w3", "running", 3, 6, 30, 300),
+			testWorkout("w4", "running", 3, 20, 30, 300),
+		), ""},
+		{"streak days in UTC", achievementStreak7Days, []*workout{
+			testWorkout("w1", "running", 1, 23, 30, 300),
+			testWorkout("w2", "running", 2, 0, 30, 300),
+			testWorkout("w3", "running", 3, 23, 30, 300),
+			testWorkout("w4", "running", 4, 0, 30, 300),
+			testWorkout("w5", "running", 5, 23, 30, 300),
+			testWorkout("w6", "running", 6, 0, 30, 300),
+			testWorkout("w7", "running", 7, 23, 30, 300),
+		}, "w7"},
+		{"personal best duration per activity", achievementPersonalBestDuration, []*workout{
+			testWorkout("w1", "running", 1, 8, 30, 300),
+			testWorkout("w2", "cycling", 2, 8, 60, 300),
+			testWorkout("w3", "running", 3, 8, 45, 300),
+			testWorkout("w4", "running", 4, 8, 40, 300),
+		}, "running w3 2700000, cycling w2 3600000"},
+		{"personal best tied by the earliest", achievementPersonalBestDuration, []*workout{
+			testWorkout("w2", "running", 2, 8, 30, 300),
+			testWorkout("w1", "running", 1, 8, 30, 300),
+		}, "running w1 1800000"},
+		{"personal best calories burned", achievementPersonalBestCaloriesBurned, []*workout{
+			testWorkout("w1", "running", 1, 8, 60, 300),
+			testWorkout("w2", "running", 2, 8, 30, 400),
+		}, "running w2 400"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var earned []string
+			for _, a := range evaluateAchievements(test.workouts) {
+				if a.code != test.code {
+					continue
+				}
+				if a.activityID == nil {
+					earned = append(earned, a.workoutID)
+					continue
+				}
+				earned = append(earned, fmt.Sprintf("%s %s %d", *a.activityID, a.workoutID, *a.value))
+			}
+			if got := strings.Join(earned, ", "); got != test.expected {
+				t.Fatalf("expected %s to be earned by %q, got %q", test.code, test.expected, got)
+			}
+		})
+	}
+}
@@ -2,156 +2,160 @@ package main
 
 import (
 	"context"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type activity struct {
 	activityID string
+	userID     string
 	name       string
+	// met is the metabolic equivalent of the activity, which calories are
+	// estimated with. it is seeded from the compendium when created, nil if
+	// calories can't be estimated
+	met *float64
+
+	// version is incremented on every write. Update, Patch and Delete only
+	// apply while the stored version still equals it, unless it is 0
+	version int64
+}
+
+type activityRepository interface {
+	SaveActivity(context.Context, *activity) error
+	GetActivity(context.Context, *activity) error
+	UpdateActivity(context.Context, *activity) error
+	PatchActivity(ctx context.Context, a *activity, fields []string) error
+	DeleteActivity(context.Context, *activity) error
+	ActivityExists(context.Context, *activity) (bool, error)
+	GetAllActivities(ctx context.Context, userID string) ([]*activity, error)
 }
 
 func (a *activity) Type() string {
 	return "activity"
 }
 
-func (a *activity) Save(baseLog *logrus.Entry, appData *appData) error {
+func (a *activity) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "activity",
 		"event":  "save",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	tag, err := appData.db.Exec(context.Background(), `
-		INSERT INTO activities (
-			activity_id,
-			name
-		) VALUES ($1,$2)`,
-		a.activityID,
-		a.name,
-	)
-	if err != nil || tag.RowsAffected() != 1 {
-		return err
+	err := appData.repository.SaveActivity(ctx, a)
+	if err != nil {
+		return persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
 	return nil
 }
 
-func (a *activity) Get(baseLog *logrus.Entry, appData *appData) error {
+func (a *activity) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "activity",
 		"event":  "get",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	err := appData.db.QueryRow(context.Background(), `
-		SELECT 
-			activity_id,
-			name
-		FROM activities
-		WHERE activity_id = $1`, a.activityID).Scan(
-		&a.activityID,
-		&a.name,
-	)
+	err := appData.repository.GetActivity(ctx, a)
 	if err != nil {
-		return err
+		return persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
 	return nil
 }
 
-func (a *activity) Update(baseLog *logrus.Entry, appData *appData) error {
+func (a *activity) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "activity",
 		"event":  "update",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	tag, err := appData.db.Exec(context.Background(), `
-		UPDATE activities SET (
-			activity_id,
-			name
-		) = ($1,$2)
-		WHERE activity_id = $1`,
-		a.activityID,
-		a.name,
-	)
-	if err != nil || tag.RowsAffected() != 1 {
-		return err
+	err := appData.repository.UpdateActivity(ctx, a)
+	if err != nil {
+		return persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
 	return nil
 }
 
-func (a *activity) Delete(baseLog *logrus.Entry, appData *appData) error {
+// Patch returns a persistence func updating only the given fields, named
+// by their columns, leaving the rest of the stored activity untouched
+func (a *activity) Patch(fields ...string) func(context.Context, *logrus.Entry, *appData) error {
+	return func(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+		log := baseLog.WithFields(logrus.Fields{
+			"entity": "activity",
+			"event":  "patch",
+		})
+		log.Trace("database event initiated")
+		defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+		err := appData.repository.PatchActivity(ctx, a, fields)
+		if err != nil {
+			return persistenceErrorOf(err)
+		}
+
+		log.Trace("database event completed")
+		return nil
+	}
+}
+
+func (a *activity) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "activity",
 		"event":  "delete",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	tag, err := appData.db.Exec(context.Background(), `
-		DELETE FROM activities
-		WHERE activity_id = $1`,
-		a.activityID,
-	)
-	if err != nil || tag.RowsAffected() != 1 {
-		return err
+	err := appData.repository.DeleteActivity(ctx, a)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
 	}
 
 	log.Trace("database event completed")
 	return nil
 }
 
-func (a *activity) Exists(baseLog *logrus.Entry, appData *appData) (bool, error) {
+func (a *activity) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "activity",
 		"event":  "exist",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	var count int
-	err := appData.db.QueryRow(context.Background(), `
-		SELECT count(*)
-		FROM activities
-		WHERE activity_id = $1`, a.activityID).Scan(&count)
+	exists, err := appData.repository.ActivityExists(ctx, a)
 	if err != nil {
-		return false, err
+		return false, persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
-	return count == 1, nil
+	return exists, nil
 }
 
-func getAllActivities(baseLog *logrus.Entry, appData *appData) ([]persistenceObject, error) {
+func getAllActivities(ctx context.Context, baseLog *logrus.Entry, appData *appData, userID string) ([]persistenceObject, error) {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "activity",
 		"event":  "get all",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	rows, err := appData.db.Query(context.Background(), `
-		SELECT 
-			activity_id,
-			name
-		FROM activities`)
+	all, err := appData.repository.GetAllActivities(ctx, userID)
 	if err != nil {
-		return nil, err
+		return nil, persistenceErrorOf(err)
 	}
 
 	var activities []persistenceObject
-	for rows.Next() {
-		a := &activity{}
-		err = rows.Scan(
-			&a.activityID,
-			&a.name,
-		)
-		if err != nil {
-			return nil, err
-		}
+	for _, a := range all {
 		activities = append(activities, a)
 	}
 
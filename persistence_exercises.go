@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	weightUnitKilograms = "kg"
+	weightUnitPounds    = "lb"
+)
+
+// exercise is one exercise of a workout, such as a bench press, described
+// by an activity and performed in sets
+type exercise struct {
+	exerciseID string
+	workoutID  string
+	userID     string
+	activityID string
+
+	// position orders the exercises of a workout from 0. saving or updating
+	// at a position shifts the exercises after it, and a negative or out of
+	// range position appends, or keeps the current position on update
+	position int
+	notes    string
+
+	sets []exerciseSet
+}
+
+// exerciseSet is a single set of an exercise. everything but the reps is
+// optional, for bodyweight exercises or when not tracked
+type exerciseSet struct {
+	reps       int
+	weight     *float64
+	weightUnit string
+	// rpe is the rate of perceived exertion, from 1 to 10
+	rpe  *float64
+	rest *time.Duration
+}
+
+type exerciseRepository interface {
+	SaveExercise(context.Context, *exercise) error
+	GetExercise(context.Context, *exercise) error
+	UpdateExercise(context.Context, *exercise) error
+	DeleteExercise(context.Context, *exercise) error
+	ExerciseExists(context.Context, *exercise) (bool, error)
+	GetExercises(context.Context, *workout) ([]*exercise, error)
+}
+
+func (e *exercise) Type() string {
+	return "exercise"
+}
+
+func (e *exercise) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "exercise",
+		"event":  "save",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveExercise(ctx, e)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (e *exercise) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "exercise",
+		"event":  "get",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.GetExercise(ctx, e)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (e *exercise) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "exercise",
+		"event":  "update",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.UpdateExercise(ctx, e)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (e *exercise) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "exercise",
+		"event":  "delete",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.DeleteExercise(ctx, e)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (e *exercise) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "exercise",
+		"event":  "exist",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	exists, err := appData.repository.ExerciseExists(ctx, e)
+	if err != nil {
+		return false, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return exists, nil
+}
+
+// getExercises returns the exercises of a workout in order, with their sets
+func getExercises(ctx context.Context, baseLog *logrus.Entry, appData *appData, w *workout) ([]*exercise, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "exercise",
+		"event":  "get all",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	exercises, err := appData.repository.GetExercises(ctx, w)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return exercises, nil
+}
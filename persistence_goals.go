@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	goalMetricCaloriesBurned = "calories_burned"
+	goalMetricDuration       = "duration"
+
+	goalStatusAchieved = "achieved"
+	goalStatusOnTrack  = "on_track"
+	goalStatusBehind   = "behind"
+)
+
+// goal sets a target for the total of a metric over every calendar period,
+// counting the workouts of activityID, or of every activity if nil. the
+// target is in kilocalories for calories burned and in milliseconds for
+// duration
+type goal struct {
+	goalID     string
+	userID     string
+	activityID *string
+
+	metric string
+	target int64
+	// period is one of the stats groupings, with periods starting at
+	// midnight in location
+	period   string
+	location *time.Location
+
+	// startedAt is the start of the first period tracked
+	startedAt time.Time
+}
+
+// goalPeriod is the progress of a goal over a single period, ending at
+// periodEnd exclusive
+type goalPeriod struct {
+	periodStart time.Time
+	periodEnd   time.Time
+	target      int64
+	value       int64
+}
+
+type goalRepository interface {
+	SaveGoal(context.Context, *goal) error
+	GetGoal(context.Context, *goal) error
+	// UpdateGoal also drops the recorded periods starting before the
+	// goal's startedAt
+	UpdateGoal(context.Context, *goal) error
+	DeleteGoal(context.Context, *goal) error
+	GoalExists(context.Context, *goal) (bool, error)
+	GetAllGoals(ctx context.Context, userID string) ([]*goal, error)
+
+	// RecordGoalPeriods adds finished periods to the goal's history,
+	// skipping those already recorded. the history is read for the targets
+	// the periods had, their values always come from the workouts
+	RecordGoalPeriods(context.Context, *goal, []*goalPeriod) error
+	// GetGoalPeriods returns the goal's history, most recent first
+	GetGoalPeriods(context.Context, *goal) ([]*goalPeriod, error)
+}
+
+// nextPeriod returns the start of the period following the one starting
+// at start
+func (g *goal) nextPeriod(start time.Time) time.Time {
+	switch g.period {
+	case statsGroupByWeek:
+		return start.AddDate(0, 0, 7)
+	case statsGroupByMonth:
+		return start.AddDate(0, 1, 0)
+	case statsGroupByYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// value returns the goal's metric out of a stats bucket
+func (g *goal) value(b *workoutStatsBucket) int64 {
+	if g.metric == goalMetricDuration {
+		return b.totalDuration.Milliseconds()
+	}
+	return b.totalCaloriesBurned
+}
+
+// expected returns how much of the target should be reached by now, at an
+// even pace over the period
+func (p *goalPeriod) expected(now time.Time) int64 {
+	if !now.Before(p.periodEnd) {
+		return p.target
+	}
+	if !now.After(p.periodStart) {
+		return 0
+	}
+	elapsed := now.Sub(p.periodStart).Seconds() / p.periodEnd.Sub(p.periodStart).Seconds()
+	return int64(float64(p.target) * elapsed)
+}
+
+// status tells whether the target is achieved, and if not whether the
+// progress keeps up with the expected pace
+func (p *goalPeriod) status(now time.Time) string {
+	switch {
+	case p.value >= p.target:
+		return goalStatusAchieved
+	case p.value >= p.expected(now):
+		return goalStatusOnTrack
+	default:
+		return goalStatusBehind
+	}
+}
+
+func (g *goal) Type() string {
+	return "goal"
+}
+
+func (g *goal) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal",
+		"event":  "save",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveGoal(ctx, g)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (g *goal) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal",
+		"event":  "get",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.GetGoal(ctx, g)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (g *goal) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal",
+		"event":  "update",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.UpdateGoal(ctx, g)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (g *goal) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal",
+		"event":  "delete",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.DeleteGoal(ctx, g)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (g *goal) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal",
+		"event":  "exist",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	exists, err := appData.repository.GoalExists(ctx, g)
+	if err != nil {
+		return false, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return exists, nil
+}
+
+func getAllGoals(ctx context.Context, baseLog *logrus.Entry, appData *appData, userID string) ([]persistenceObject, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal",
+		"event":  "get all",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	all, err := appData.repository.GetAllGoals(ctx, userID)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	var goals []persistenceObject
+	for _, g := range all {
+		goals = append(goals, g)
+	}
+
+	log.Trace("database event completed")
+	return goals, nil
+}
+
+func recordGoalPeriods(ctx context.Context, baseLog *logrus.Entry, appData *appData, g *goal, periods []*goalPeriod) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal period",
+		"event":  "record",
+		"count":  len(periods),
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.RecordGoalPeriods(ctx, g, periods)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func getGoalPeriods(ctx context.Context, baseLog *logrus.Entry, appData *appData, g *goal) ([]*goalPeriod, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "goal period",
+		"event":  "get all",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	periods, err := appData.repository.GetGoalPeriods(ctx, g)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return periods, nil
+}
+
+// trackGoal computes the goal's progress in the period containing now, and
+// in every period finished since it started, from the workouts, so a
+// workout backdated into a finished period still counts. finished periods
+// keep the target recorded for them, if any. it returns the current period
+// along with the history, most recent first
+func trackGoal(ctx context.Context, log *logrus.Entry, appData *appData, g *goal, now time.Time) (*goalPeriod, []*goalPeriod, error) {
+	recorded, err := getGoalPeriods(ctx, log, appData, g)
+	if err != nil {
+		return nil, nil, err
+	}
+	targets := make(map[int64]int64)
+	for _, p := range recorded {
+		targets[p.periodStart.Unix()] = p.target
+	}
+
+	currentStart := truncateToPeriod(now, g.period, g.location)
+	if currentStart.Before(g.startedAt) {
+		currentStart = g.startedAt
+	}
+	currentEnd := g.nextPeriod(currentStart)
+
+	buckets, err := getWorkoutStats(ctx, log, appData, &workoutStatsQuery{
+		userID:     g.userID,
+		groupBy:    g.period,
+		location:   g.location,
+		activityID: g.activityID,
+		from:       &g.startedAt,
+		to:         &currentEnd,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	values := make(map[int64]int64)
+	for _, b := range buckets {
+		values[b.periodStart.Unix()] = g.value(b)
+	}
+
+	var finished []*goalPeriod
+	for start := g.startedAt; start.Before(currentStart); start = g.nextPeriod(start) {
+		target, ok := targets[start.Unix()]
+		if !ok {
+			target = g.target
+		}
+		finished = append(finished, &goalPeriod{
+			periodStart: start,
+			periodEnd:   g.nextPeriod(start),
+			target:      target,
+			value:       values[start.Unix()],
+		})
+	}
+	history := make([]*goalPeriod, 0, len(finished))
+	for i := len(finished) - 1; i >= 0; i-- {
+		history = append(history, finished[i])
+	}
+
+	current := &goalPeriod{
+		periodStart: currentStart,
+		periodEnd:   currentEnd,
+		target:      g.target,
+		value:       values[currentStart.Unix()],
+	}
+	return current, history, nil
+}
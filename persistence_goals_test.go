@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestGoal saves a weekly calories burned goal of the user's, started
+// on Monday 2 March 2026
+func newTestGoal(t *testing.T, s *testServer, userID string, target int64) *goal {
+	t.Helper()
+
+	g := &goal{
+		goalID:    uuid.NewString(),
+		userID:    userID,
+		metric:    goalMetricCaloriesBurned,
+		target:    target,
+		period:    statsGroupByWeek,
+		location:  time.UTC,
+		startedAt: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+	}
+	if err := s.appData.repository.SaveGoal(context.Background(), g); err != nil {
+		t.Fatalf("cannot save goal: %v", err)
+	}
+	return g
+}
+
+func TestTrackGoalPeriods(t *testing.T) {
+	s := newTestServer(t)
+	log := logrus.NewEntry(s.log)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	for _, timestamp := range []time.Time{
+		time.Date(2026, 3, 3, 7, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 5, 7, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 17, 7, 0, 0, 0, time.UTC),
+		time.Date(2026, 3, 24, 7, 0, 0, 0, time.UTC),
+	} {
+		s.createWorkout(tokens.AccessToken, activityID, timestamp)
+	}
+	g := newTestGoal(t, s, tokens.UserID, 600)
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		currentStart string
+		currentValue int64
+		history      []string
+		values       []int64
+	}{
+		{"at the start of the first period", time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC), "2026-03-02", 600, nil, nil},
+		{"at the end of the first period", time.Date(2026, 3, 8, 23, 59, 0, 0, time.UTC), "2026-03-02", 600, nil, nil},
+		{"rolled over into the second period", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), "2026-03-09", 0, []string{"2026-03-02"}, []int64{600}},
+		{"several periods later", time.Date(2026, 3, 25, 12, 0, 0, 0, time.UTC), "2026-03-23", 300,
+			[]string{"2026-03-16", "2026-03-09", "2026-03-02"}, []int64{300, 0, 600}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			current, history, err := trackGoal(context.Background(), log, s.appData, g, test.now)
+			if err != nil {
+				t.Fatalf("cannot track goal: %v", err)
+			}
+			if got := current.periodStart.Format(dateFormat); got != test.currentStart || current.value != test.currentValue {
+				t.Fatalf("expected %d kcal in the period of %s, got %d in that of %s", test.currentValue, test.currentStart, current.value, got)
+			}
+			if !current.periodEnd.Equal(current.periodStart.AddDate(0, 0, 7)) {
+				t.Fatalf("expected a week long period, got %s to %s", current.periodStart, current.periodEnd)
+			}
+
+			if len(history) != len(test.history) {
+				t.Fatalf("expected %d finished periods, got %d", len(test.history), len(history))
+			}
+			for i, p := range history {
+				if got := p.periodStart.Format(dateFormat); got != test.history[i] || p.value != test.values[i] || p.target != 600 {
+					t.Fatalf("expected %d of 600 kcal in the period of %s, got %d of %d in that of %s",
+						test.values[i], test.history[i], p.value, p.target, got)
+				}
+			}
+		})
+	}
+
+	// tracking only reads the history
+	recorded, err := s.appData.repository.GetGoalPeriods(context.Background(), g)
+	if err != nil {
+		t.Fatalf("cannot get goal periods: %v", err)
+	}
+	if len(recorded) != 0 {
+		t.Fatalf("expected no periods to be recorded, got %d", len(recorded))
+	}
+}
+
+func TestTrackGoalRecordedPeriods(t *testing.T) {
+	s := newTestServer(t)
+	log := logrus.NewEntry(s.log)
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	s.createWorkout(tokens.AccessToken, activityID, time.Date(2026, 3, 3, 7, 0, 0, 0, time.UTC))
+	g := newTestGoal(t, s, tokens.UserID, 600)
+
+	// the finished periods are recorded with the target they had, as a
+	// goal update does
+	now := time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC)
+	_, history, err := trackGoal(context.Background(), log, s.appData, g, now)
+	if err != nil {
+		t.Fatalf("cannot track goal: %v", err)
+	}
+	err = recordGoalPeriods(context.Background(), log, s.appData, g, history)
+	if err != nil {
+		t.Fatalf("cannot record goal periods: %v", err)
+	}
+	g.target = 900
+
+	// and a workout backdated into one of them still counts
+	s.createWorkout(tokens.AccessToken, activityID, time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC))
+
+	now = time.Date(2026, 3, 25, 12, 0, 0, 0, time.UTC)
+	current, history, err := trackGoal(context.Background(), log, s.appData, g, now)
+	if err != nil {
+		t.Fatalf("cannot track goal: %v", err)
+	}
+	if current.target != 900 {
+		t.Fatalf("expected the current period to have the new target, got %d", current.target)
+	}
+
+	expected := []struct {
+		periodStart string
+		target      int64
+		value       int64
+	}{
+		{"2026-03-16", 900, 0},
+		{"2026-03-09", 600, 300},
+		{"2026-03-02", 600, 300},
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("expected %d finished periods, got %d", len(expected), len(history))
+	}
+	for i, p := range history {
+		e := expected[i]
+		if got := p.periodStart.Format(dateFormat); got != e.periodStart || p.target != e.target || p.value != e.value {
+			t.Fatalf("expected %d of %d kcal in the period of %s, got %d of %d in that of %s",
+				e.value, e.target, e.periodStart, p.value, p.target, got)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	measurementKindWeight           = "weight"
+	measurementKindBodyFat          = "body_fat"
+	measurementKindRestingHeartRate = "resting_heart_rate"
+	measurementKindWaist            = "waist"
+	measurementKindHip              = "hip"
+)
+
+// measurementKind describes the values of a kind of measurement. values are
+// stored in unit, and given in any of units, each with the factor that
+// converts it to unit
+type measurementKind struct {
+	unit  string
+	units map[string]float64
+	// bounds of a plausible value, in unit
+	min float64
+	max float64
+}
+
+// bounds of a plausible waist or hip circumference in centimeters
+const (
+	minCircumferenceCm = 20
+	maxCircumferenceCm = 300
+)
+
+var measurementKinds = map[string]measurementKind{
+	measurementKindWeight: {
+		unit:  "kg",
+		units: map[string]float64{"kg": 1, "lb": 0.45359237},
+		min:   minWeightKg,
+		max:   maxWeightKg,
+	},
+	measurementKindBodyFat: {
+		unit:  "percent",
+		units: map[string]float64{"percent": 1},
+		min:   1,
+		max:   75,
+	},
+	measurementKindRestingHeartRate: {
+		unit:  "bpm",
+		units: map[string]float64{"bpm": 1},
+		min:   minHeartRate,
+		max:   maxHeartRate,
+	},
+	measurementKindWaist: {
+		unit:  "cm",
+		units: map[string]float64{"cm": 1, "in": 2.54},
+		min:   minCircumferenceCm,
+		max:   maxCircumferenceCm,
+	},
+	measurementKindHip: {
+		unit:  "cm",
+		units: map[string]float64{"cm": 1, "in": 2.54},
+		min:   minCircumferenceCm,
+		max:   maxCircumferenceCm,
+	},
+}
+
+// toCanonical converts a value given in one of the units of the kind to
+// the unit of the kind
+func (k measurementKind) toCanonical(value float64, unit string) float64 {
+	return value * k.units[unit]
+}
+
+// plausible reports whether a value in the unit of the kind is within its
+// bounds
+func (k measurementKind) plausible(value float64) bool {
+	return value >= k.min && value <= k.max
+}
+
+// fromCanonical converts a value stored in the unit of the kind to unit,
+// rounded to two decimals
+func (k measurementKind) fromCanonical(value float64, unit string) float64 {
+	return math.Round(value/k.units[unit]*100) / 100
+}
+
+// unitNames lists the units of the kind for error messages
+func (k measurementKind) unitNames() string {
+	var names []string
+	for name := range k.units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// measurement is a single value of a user's body metric, in the unit of
+// its kind
+type measurement struct {
+	measurementID string
+	userID        string
+	kind          string
+	value         float64
+	measuredAt    time.Time
+}
+
+// measurementQuery selects a user's measurements, taken in [from, to). nil
+// filters are not applied
+type measurementQuery struct {
+	userID string
+
+	kind *string
+	from *time.Time
+	to   *time.Time
+}
+
+type measurementRepository interface {
+	SaveMeasurement(context.Context, *measurement) error
+	GetMeasurement(context.Context, *measurement) error
+	UpdateMeasurement(context.Context, *measurement) error
+	DeleteMeasurement(context.Context, *measurement) error
+	MeasurementExists(context.Context, *measurement) (bool, error)
+	// QueryMeasurements returns the matching measurements, oldest first
+	QueryMeasurements(context.Context, *measurementQuery) ([]*measurement, error)
+	// GetMeasurementAt returns the user's latest measurement of kind taken
+	// at or before at, or else their earliest one, or nil if there is none
+	GetMeasurementAt(ctx context.Context, userID string, kind string, at time.Time) (*measurement, error)
+}
+
+// rollingAverages returns the average of every measurement with the ones
+// of the same kind taken within window before it. measurements must be
+// sorted oldest first
+func rollingAverages(measurements []*measurement, window time.Duration) []float64 {
+	averages := make([]float64, len(measurements))
+	byKind := make(map[string][]int)
+	for i, m := range measurements {
+		byKind[m.kind] = append(byKind[m.kind], i)
+	}
+	for _, indices := range byKind {
+		start := 0
+		sum := 0.0
+		for end, i := range indices {
+			sum += measurements[i].value
+			cutoff := measurements[i].measuredAt.Add(-window)
+			for !measurements[indices[start]].measuredAt.After(cutoff) {
+				sum -= measurements[indices[start]].value
+				start++
+			}
+			averages[i] = sum / float64(end-start+1)
+		}
+	}
+	return averages
+}
+
+func (m *measurement) Type() string {
+	return "measurement"
+}
+
+func (m *measurement) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "measurement",
+		"event":  "save",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveMeasurement(ctx, m)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (m *measurement) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "measurement",
+		"event":  "get",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.GetMeasurement(ctx, m)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (m *measurement) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "measurement",
+		"event":  "update",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.UpdateMeasurement(ctx, m)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (m *measurement) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "measurement",
+		"event":  "delete",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.DeleteMeasurement(ctx, m)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (m *measurement) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "measurement",
+		"event":  "exist",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	exists, err := appData.repository.MeasurementExists(ctx, m)
+	if err != nil {
+		return false, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return exists, nil
+}
+
+func queryMeasurements(ctx context.Context, baseLog *logrus.Entry, appData *appData, query *measurementQuery) ([]*measurement, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "measurement",
+		"event":  "query",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	measurements, err := appData.repository.QueryMeasurements(ctx, query)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return measurements, nil
+}
+
+// bodyWeightAt returns the user's weight in kilograms at the time, from
+// their weight measurements if they have any, or else from their profile.
+// it is nil if neither is known
+func bodyWeightAt(ctx context.Context, baseLog *logrus.Entry, appData *appData, u *user, at time.Time) (*float64, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "measurement",
+		"event":  "get at",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	m, err := appData.repository.GetMeasurementAt(ctx, u.userID, measurementKindWeight, at)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	if m == nil {
+		return u.weightKg, nil
+	}
+	return &m.value, nil
+}
+
+// bodyWeights answers bodyWeightAt for any time from a user's weight
+// measurements, loaded once for workouts imported together
+type bodyWeights struct {
+	// measurements are the user's weight measurements, oldest first
+	measurements []*measurement
+	// profile is the weight of the user's profile
+	profile *float64
+}
+
+func loadBodyWeights(ctx context.Context, baseLog *logrus.Entry, appData *appData, u *user) (*bodyWeights, error) {
+	kind := measurementKindWeight
+	measurements, err := queryMeasurements(ctx, baseLog, appData, &measurementQuery{
+		userID: u.userID,
+		kind:   &kind,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &bodyWeights{
+		measurements: measurements,
+		profile:      u.weightKg,
+	}, nil
+}
+
+// at returns the weight in kilograms at the time the way bodyWeightAt does,
+// from the latest measurement taken by then, or else the earliest one
+func (b *bodyWeights) at(t time.Time) *float64 {
+	if len(b.measurements) == 0 {
+		return b.profile
+	}
+	i := sort.Search(len(b.measurements), func(i int) bool {
+		return b.measurements[i].measuredAt.After(t)
+	})
+	if i > 0 {
+		i--
+	}
+	return &b.measurements[i].value
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeasurementUnits(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		value     float64
+		unit      string
+		canonical float64
+	}{
+		{"weight in kilograms", measurementKindWeight, 80, "kg", 80},
+		{"weight in pounds", measurementKindWeight, 176.37, "lb", 80},
+		{"body fat in percent", measurementKindBodyFat, 18.5, "percent", 18.5},
+		{"resting heart rate in beats per minute", measurementKindRestingHeartRate, 52, "bpm", 52},
+		{"waist in inches", measurementKindWaist, 32, "in", 81.28},
+		{"hip in centimeters", measurementKindHip, 98.5, "cm", 98.5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind := measurementKinds[test.kind]
+			canonical := kind.toCanonical(test.value, test.unit)
+			expectApproximately(t, "canonical value", &canonical, &test.canonical)
+			if got := kind.fromCanonical(canonical, test.unit); got != test.value {
+				t.Fatalf("expected %g %s back, got %g", test.value, test.unit, got)
+			}
+		})
+	}
+}
+
+func TestMeasurementFromCanonicalRounded(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		value    float64
+		unit     string
+		expected float64
+	}{
+		{"in the unit of the kind", measurementKindWeight, 80.456, "kg", 80.46},
+		{"in pounds", measurementKindWeight, 80, "lb", 176.37},
+		{"in inches", measurementKindWaist, 80, "in", 31.5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := measurementKinds[test.kind].fromCanonical(test.value, test.unit); got != test.expected {
+				t.Fatalf("expected %g %s, got %g", test.expected, test.unit, got)
+			}
+		})
+	}
+}
+
+func TestMeasurementPlausible(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		value    float64
+		expected bool
+	}{
+		{"weight", measurementKindWeight, 80, true},
+		{"weight at the lower bound", measurementKindWeight, minWeightKg, true},
+		{"weight under the lower bound", measurementKindWeight, minWeightKg - 0.1, false},
+		{"weight at the upper bound", measurementKindWeight, maxWeightKg, true},
+		{"weight over the upper bound", measurementKindWeight, maxWeightKg + 0.1, false},
+		{"no body fat", measurementKindBodyFat, 0, false},
+		{"body fat over the upper bound", measurementKindBodyFat, 80, false},
+		{"resting heart rate", measurementKindRestingHeartRate, 52, true},
+		{"waist under the lower bound", measurementKindWaist, 10, false},
+		{"hip over the upper bound", measurementKindHip, 301, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := measurementKinds[test.kind].plausible(test.value); got != test.expected {
+				t.Fatalf("expected %g to be plausible for %s: %t, got %t", test.value, test.kind, test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRollingAverages(t *testing.T) {
+	// measurement of kind taken on day of March 2026 at noon UTC
+	at := func(kind string, day int, value float64) *measurement {
+		return &measurement{
+			kind:       kind,
+			value:      value,
+			measuredAt: time.Date(2026, 3, day, 12, 0, 0, 0, time.UTC),
+		}
+	}
+
+	tests := []struct {
+		name         string
+		measurements []*measurement
+		window       time.Duration
+		expected     string
+	}{
+		{"no measurements", nil, 7 * 24 * time.Hour, ""},
+		{"a single measurement", []*measurement{at(measurementKindWeight, 1, 80)}, 7 * 24 * time.Hour, "80"},
+		{"within the window", []*measurement{
+			at(measurementKindWeight, 1, 80),
+			at(measurementKindWeight, 2, 82),
+			at(measurementKindWeight, 3, 78),
+		}, 7 * 24 * time.Hour, "80 81 80"},
+		{"leaving the window", []*measurement{
+			at(measurementKindWeight, 1, 80),
+			at(measurementKindWeight, 2, 82),
+			at(measurementKindWeight, 3, 84),
+		}, 2 * 24 * time.Hour, "80 81 83"},
+		{"without the one a window before", []*measurement{
+			at(measurementKindWeight, 1, 80),
+			at(measurementKindWeight, 2, 82),
+			at(measurementKindWeight, 3, 78),
+		}, 24 * time.Hour, "80 82 78"},
+		{"after a gap", []*measurement{
+			at(measurementKindWeight, 1, 80),
+			at(measurementKindWeight, 2, 82),
+			at(measurementKindWeight, 20, 76),
+		}, 7 * 24 * time.Hour, "80 81 76"},
+		{"per kind", []*measurement{
+			at(measurementKindWeight, 1, 80),
+			at(measurementKindBodyFat, 1, 20),
+			at(measurementKindWeight, 2, 82),
+			at(measurementKindBodyFat, 2, 18),
+		}, 7 * 24 * time.Hour, "80 20 81 19"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var averages []string
+			for _, average := range rollingAverages(test.measurements, test.window) {
+				averages = append(averages, fmt.Sprint(average))
+			}
+			if got := strings.Join(averages, " "); got != test.expected {
+				t.Fatalf("expected the averages %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// program schedules workout templates across weeks. its sessions fall on
+// dates counted from startDate, in the program's location
+type program struct {
+	programID string
+	userID    string
+	name      string
+	// startDate is midnight UTC of the program's first day
+	startDate time.Time
+	location  *time.Location
+
+	sessions []programSession
+}
+
+// programSession is a workout template planned for day 1 to 7 of a week of
+// its program, counting from week 1
+type programSession struct {
+	sessionID  string
+	templateID string
+	week       int
+	day        int
+
+	// workoutID is the workout that completed the session, if any
+	workoutID *string
+}
+
+// date returns the day a session of the program falls on, as midnight UTC
+func (p *program) date(s *programSession) time.Time {
+	return p.startDate.AddDate(0, 0, (s.week-1)*7+s.day-1)
+}
+
+type programRepository interface {
+	SaveProgram(context.Context, *program) error
+	GetProgram(context.Context, *program) error
+	UpdateProgram(context.Context, *program) error
+	DeleteProgram(context.Context, *program) error
+	ProgramExists(context.Context, *program) (bool, error)
+	GetAllPrograms(ctx context.Context, userID string) ([]*program, error)
+
+	// CompleteProgramSession marks the earliest uncompleted session planned
+	// for the day of the workout, with a template of the workout's activity,
+	// as completed by it. it returns the id of that session, or "" if none
+	CompleteProgramSession(context.Context, *workout) (string, error)
+}
+
+func (p *program) Type() string {
+	return "program"
+}
+
+func (p *program) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "program",
+		"event":  "save",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveProgram(ctx, p)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (p *program) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "program",
+		"event":  "get",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.GetProgram(ctx, p)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (p *program) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "program",
+		"event":  "update",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.UpdateProgram(ctx, p)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (p *program) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "program",
+		"event":  "delete",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.DeleteProgram(ctx, p)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (p *program) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "program",
+		"event":  "exist",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	exists, err := appData.repository.ProgramExists(ctx, p)
+	if err != nil {
+		return false, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return exists, nil
+}
+
+func getAllPrograms(ctx context.Context, baseLog *logrus.Entry, appData *appData, userID string) ([]persistenceObject, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "program",
+		"event":  "get all",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	all, err := appData.repository.GetAllPrograms(ctx, userID)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	var programs []persistenceObject
+	for _, p := range all {
+		programs = append(programs, p)
+	}
+
+	log.Trace("database event completed")
+	return programs, nil
+}
+
+// completeProgramSession marks the planned session the workout fulfils as
+// completed, returning its id or "" if the workout was not planned
+func completeProgramSession(ctx context.Context, baseLog *logrus.Entry, appData *appData, w *workout) (string, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "program session",
+		"event":  "complete",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	sessionID, err := appData.repository.CompleteProgramSession(ctx, w)
+	if err != nil {
+		return "", persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return sessionID, nil
+}
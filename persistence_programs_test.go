@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCompleteProgramSession(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("cannot load location: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		workouts   []time.Time
+		activities []string
+		expected   string
+	}{
+		{"on a planned day", []time.Time{time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)}, []string{"running"}, "early 1.1"},
+		{"on a planned day in the program's time zone", []time.Time{time.Date(2026, 3, 3, 23, 30, 0, 0, time.UTC)}, []string{"running"}, "early 1.3"},
+		{"on a later week", []time.Time{time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)}, []string{"running"}, "early 2.1"},
+		{"on an unplanned day", []time.Time{time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)}, []string{"running"}, "-"},
+		{"of another activity", []time.Time{time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)}, []string{"cycling"}, "-"},
+		{"the earliest program first, one session per workout", []time.Time{
+			time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 3, 17, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 3, 19, 0, 0, 0, time.UTC),
+		}, []string{"running", "running", "running"}, "early 1.2, later 1.1, -"},
+	}
+	for name, repository := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					ctx := context.Background()
+					userID := uuid.NewString()
+					if err := repository.SaveUser(ctx, &user{userID: userID, name: "Ada", email: userID + "@example.com"}); err != nil {
+						t.Fatalf("cannot save user: %v", err)
+					}
+					activityIDs := map[string]string{"running": uuid.NewString(), "cycling": uuid.NewString()}
+					for activityName, activityID := range activityIDs {
+						if err := repository.SaveActivity(ctx, &activity{activityID: activityID, userID: userID, name: activityName}); err != nil {
+							t.Fatalf("cannot save activity: %v", err)
+						}
+					}
+					template := &workoutTemplate{templateID: uuid.NewString(), userID: userID, activityID: activityIDs["running"], name: "Easy run"}
+					if err := repository.SaveWorkoutTemplate(ctx, template); err != nil {
+						t.Fatalf("cannot save workout template: %v", err)
+					}
+
+					// sessions are labelled by program, week and day
+					labels := map[string]string{"": "-"}
+					for _, p := range []struct {
+						name      string
+						startDate time.Time
+						days      [][2]int
+					}{
+						{"early", time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), [][2]int{{1, 1}, {1, 2}, {1, 3}, {2, 1}}},
+						{"later", time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), [][2]int{{1, 1}}},
+					} {
+						saved := &program{programID: uuid.NewString(), userID: userID, name: p.name, startDate: p.startDate, location: berlin}
+						for _, day := range p.days {
+							s := programSession{sessionID: uuid.NewString(), templateID: template.templateID, week: day[0], day: day[1]}
+							saved.sessions = append(saved.sessions, s)
+							labels[s.sessionID] = fmt.Sprintf("%s %d.%d", p.name, day[0], day[1])
+						}
+						if err := repository.SaveProgram(ctx, saved); err != nil {
+							t.Fatalf("cannot save program: %v", err)
+						}
+					}
+
+					var completed []string
+					for i, timestamp := range test.workouts {
+						w := &workout{
+							workoutID:  uuid.NewString(),
+							userID:     userID,
+							activityID: activityIDs[test.activities[i]],
+							timestamp:  timestamp,
+							duration:   30 * time.Minute,
+						}
+						if err := repository.SaveWorkout(ctx, w); err != nil {
+							t.Fatalf("cannot save workout: %v", err)
+						}
+						sessionID, err := repository.CompleteProgramSession(ctx, w)
+						if err != nil {
+							t.Fatalf("cannot complete program session: %v", err)
+						}
+						completed = append(completed, labels[sessionID])
+					}
+					if got := strings.Join(completed, ", "); got != test.expected {
+						t.Fatalf("expected the sessions completed to be %s, got %s", test.expected, got)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// refreshToken is only ever stored by the hash of the token handed to the
+// client, so a leaked table cannot be used to mint access tokens
+type refreshToken struct {
+	tokenHash string
+	userID    string
+	createdAt time.Time
+	expiresAt time.Time
+	revokedAt *time.Time
+}
+
+type refreshTokenRepository interface {
+	SaveRefreshToken(context.Context, *refreshToken) error
+	GetRefreshToken(context.Context, *refreshToken) error
+	UpdateRefreshToken(context.Context, *refreshToken) error
+	// RevokeRefreshToken revokes the token if it is still usable, loading
+	// it, and reports whether it did so. checking and revoking at once keeps
+	// concurrent exchanges of the same token from both succeeding
+	RevokeRefreshToken(context.Context, *refreshToken) (bool, error)
+	DeleteRefreshToken(context.Context, *refreshToken) error
+	RefreshTokenExists(context.Context, *refreshToken) (bool, error)
+}
+
+func (t *refreshToken) Type() string {
+	return "refresh token"
+}
+
+// usable reports whether the token may still be exchanged for an access token
+func (t *refreshToken) usable(now time.Time) bool {
+	return t.revokedAt == nil && now.Before(t.expiresAt)
+}
+
+func (t *refreshToken) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "refresh token",
+		"event":  "save",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveRefreshToken(ctx, t)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *refreshToken) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "refresh token",
+		"event":  "get",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.GetRefreshToken(ctx, t)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *refreshToken) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "refresh token",
+		"event":  "update",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.UpdateRefreshToken(ctx, t)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *refreshToken) Revoke(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "refresh token",
+		"event":  "revoke",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	revoked, err := appData.repository.RevokeRefreshToken(ctx, t)
+	if err != nil {
+		return false, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return revoked, nil
+}
+
+func (t *refreshToken) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "refresh token",
+		"event":  "delete",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.DeleteRefreshToken(ctx, t)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *refreshToken) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "refresh token",
+		"event":  "exist",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	exists, err := appData.repository.RefreshTokenExists(ctx, t)
+	if err != nil {
+		return false, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return exists, nil
+}
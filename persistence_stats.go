@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	statsGroupByDay   = "day"
+	statsGroupByWeek  = "week"
+	statsGroupByMonth = "month"
+	statsGroupByYear  = "year"
+)
+
+// workoutStatsQuery aggregates a user's workouts into periods of groupBy,
+// with period boundaries falling on midnight in location
+type workoutStatsQuery struct {
+	userID string
+
+	groupBy    string
+	byActivity bool
+	location   *time.Location
+
+	activityID *string
+	from       *time.Time
+	to         *time.Time
+}
+
+type workoutStatsBucket struct {
+	periodStart time.Time
+	// activityID is only set when grouping by activity
+	activityID string
+
+	count               int64
+	totalCaloriesBurned int64
+	avgCaloriesBurned   float64
+	totalDuration       time.Duration
+	avgDuration         time.Duration
+
+	// totals of the optional measurements count workouts without them as 0
+	totalDistanceMeters float64
+	totalElevationGain  float64
+	// avgHeartRate averages the heartRateWorkouts recording a heart rate,
+	// and is nil when there are none
+	heartRateWorkouts int64
+	avgHeartRate      *float64
+}
+
+type statsRepository interface {
+	GetWorkoutStats(context.Context, *workoutStatsQuery) ([]*workoutStatsBucket, error)
+}
+
+func getWorkoutStats(ctx context.Context, baseLog *logrus.Entry, appData *appData, query *workoutStatsQuery) ([]*workoutStatsBucket, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout stats",
+		"event":  "get",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	buckets, err := appData.repository.GetWorkoutStats(ctx, query)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return buckets, nil
+}
+
+// truncateToPeriod returns the start of the period containing t, matching
+// postgres' date_trunc in the given location. weeks start on monday
+func truncateToPeriod(t time.Time, groupBy string, location *time.Location) time.Time {
+	t = t.In(location)
+	switch groupBy {
+	case statsGroupByWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+		daysSinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -daysSinceMonday)
+	case statsGroupByMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, location)
+	case statsGroupByYear:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, location)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+	}
+}
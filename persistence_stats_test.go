@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+// testRepositories returns the memory repository, along with the postgres
+// one when DTB_TEST_DATABASE_URL points at a database to migrate and write
+// to, so that both can be held to the same expectations
+func testRepositories(t *testing.T) map[string]repository {
+	t.Helper()
+
+	repositories := map[string]repository{"memory": newMemoryRepository()}
+	url := os.Getenv("DTB_TEST_DATABASE_URL")
+	if url == "" {
+		return repositories
+	}
+
+	db, err := pgxpool.Connect(context.Background(), url)
+	if err != nil {
+		t.Fatalf("cannot connect to test database: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	if err := migrateUp(context.Background(), log, db); err != nil {
+		t.Fatalf("cannot migrate test database: %v", err)
+	}
+	repositories["postgres"] = newPostgresRepository(db)
+	return repositories
+}
+
+func TestTruncateToPeriod(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("cannot load location: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		t        string
+		groupBy  string
+		location *time.Location
+		expected string
+	}{
+		{"day", "2026-03-11T15:04:05Z", statsGroupByDay, time.UTC, "2026-03-11T00:00:00Z"},
+		{"day in the location", "2026-03-11T23:30:00Z", statsGroupByDay, berlin, "2026-03-12T00:00:00+01:00"},
+		{"day of a daylight saving time change", "2026-03-29T12:00:00Z", statsGroupByDay, berlin, "2026-03-29T00:00:00+01:00"},
+		{"week starts on monday", "2026-03-15T10:00:00Z", statsGroupByWeek, time.UTC, "2026-03-09T00:00:00Z"},
+		{"monday is its own week", "2026-03-09T00:00:00Z", statsGroupByWeek, time.UTC, "2026-03-09T00:00:00Z"},
+		{"week across months", "2026-03-01T10:00:00Z", statsGroupByWeek, time.UTC, "2026-02-23T00:00:00Z"},
+		{"week in the location", "2026-03-15T23:30:00Z", statsGroupByWeek, berlin, "2026-03-16T00:00:00+01:00"},
+		{"month", "2026-03-31T23:59:59Z", statsGroupByMonth, time.UTC, "2026-03-01T00:00:00Z"},
+		{"month after daylight saving time", "2026-04-15T12:00:00Z", statsGroupByMonth, berlin, "2026-04-01T00:00:00+02:00"},
+		{"year", "2026-12-31T22:59:59Z", statsGroupByYear, berlin, "2026-01-01T00:00:00+01:00"},
+		{"year in the location", "2026-12-31T23:00:00Z", statsGroupByYear, berlin, "2027-01-01T00:00:00+01:00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, test.t)
+			if err != nil {
+				t.Fatalf("invalid time: %v", err)
+			}
+			got := truncateToPeriod(at, test.groupBy, test.location).Format(time.RFC3339)
+			if got != test.expected {
+				t.Fatalf("expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetWorkoutStats(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("cannot load location: %v", err)
+	}
+	heartRate := func(bpm int) *int {
+		return &bpm
+	}
+
+	for name, repository := range testRepositories(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			userID := uuid.NewString()
+			running := uuid.NewString()
+			cycling := uuid.NewString()
+			if err := repository.SaveUser(ctx, &user{userID: userID, name: "Ada", email: userID + "@example.com"}); err != nil {
+				t.Fatalf("cannot save user: %v", err)
+			}
+			for _, activityID := range []string{running, cycling} {
+				if err := repository.SaveActivity(ctx, &activity{activityID: activityID, userID: userID, name: activityID}); err != nil {
+					t.Fatalf("cannot save activity: %v", err)
+				}
+			}
+			for _, w := range []*workout{
+				// monday 9 March 2026 in Berlin, though still sunday in UTC
+				{activityID: running, timestamp: time.Date(2026, 3, 8, 23, 30, 0, 0, time.UTC), caloriesBurned: 300,
+					duration: 30 * time.Minute, distanceMeters: floatPointer(5000), avgHeartRate: heartRate(150)},
+				{activityID: running, timestamp: time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC), caloriesBurned: 500,
+					duration: time.Hour, distanceMeters: floatPointer(10000)},
+				{activityID: cycling, timestamp: time.Date(2026, 3, 12, 17, 0, 0, 0, time.UTC), caloriesBurned: 400,
+					duration: 90 * time.Minute, elevationGain: floatPointer(350), avgHeartRate: heartRate(131)},
+				{activityID: cycling, timestamp: time.Date(2026, 3, 16, 17, 0, 0, 0, time.UTC), caloriesBurned: 200,
+					duration: 45 * time.Minute},
+			} {
+				w.workoutID = uuid.NewString()
+				w.userID = userID
+				if err := repository.SaveWorkout(ctx, w); err != nil {
+					t.Fatalf("cannot save workout: %v", err)
+				}
+			}
+
+			to := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+			tests := []struct {
+				name     string
+				query    *workoutStatsQuery
+				expected []*workoutStatsBucket
+			}{
+				{"by week in UTC", &workoutStatsQuery{groupBy: statsGroupByWeek, location: time.UTC}, []*workoutStatsBucket{
+					{periodStart: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), count: 1, totalCaloriesBurned: 300, avgCaloriesBurned: 300,
+						totalDuration: 30 * time.Minute, avgDuration: 30 * time.Minute, totalDistanceMeters: 5000,
+						heartRateWorkouts: 1, avgHeartRate: floatPointer(150)},
+					{periodStart: time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), count: 2, totalCaloriesBurned: 900, avgCaloriesBurned: 450,
+						totalDuration: 150 * time.Minute, avgDuration: 75 * time.Minute, totalDistanceMeters: 10000, totalElevationGain: 350,
+						heartRateWorkouts: 1, avgHeartRate: floatPointer(131)},
+					{periodStart: time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC), count: 1, totalCaloriesBurned: 200, avgCaloriesBurned: 200,
+						totalDuration: 45 * time.Minute, avgDuration: 45 * time.Minute},
+				}},
+				{"by week in the location, before to", &workoutStatsQuery{groupBy: statsGroupByWeek, location: berlin, to: &to}, []*workoutStatsBucket{
+					{periodStart: time.Date(2026, 3, 9, 0, 0, 0, 0, berlin), count: 3, totalCaloriesBurned: 1200, avgCaloriesBurned: 400,
+						totalDuration: 180 * time.Minute, avgDuration: 60 * time.Minute, totalDistanceMeters: 15000, totalElevationGain: 350,
+						heartRateWorkouts: 2, avgHeartRate: floatPointer(140.5)},
+				}},
+				{"by month and activity", &workoutStatsQuery{groupBy: statsGroupByMonth, location: time.UTC, byActivity: true}, []*workoutStatsBucket{
+					{periodStart: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), activityID: cycling, count: 2, totalCaloriesBurned: 600,
+						avgCaloriesBurned: 300, totalDuration: 135 * time.Minute, avgDuration: 67*time.Minute + 30*time.Second,
+						totalElevationGain: 350, heartRateWorkouts: 1, avgHeartRate: floatPointer(131)},
+					{periodStart: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), activityID: running, count: 2, totalCaloriesBurned: 800,
+						avgCaloriesBurned: 400, totalDuration: 90 * time.Minute, avgDuration: 45 * time.Minute, totalDistanceMeters: 15000,
+						heartRateWorkouts: 1, avgHeartRate: floatPointer(150)},
+				}},
+				{"of an activity from a day", &workoutStatsQuery{groupBy: statsGroupByDay, location: time.UTC, activityID: &running, from: &to}, nil},
+			}
+			if running < cycling {
+				// buckets of the same period are ordered by activity id
+				month := tests[2].expected
+				month[0], month[1] = month[1], month[0]
+			}
+			for _, test := range tests {
+				t.Run(test.name, func(t *testing.T) {
+					test.query.userID = userID
+					buckets, err := repository.GetWorkoutStats(ctx, test.query)
+					if err != nil {
+						t.Fatalf("cannot get workout stats: %v", err)
+					}
+					if len(buckets) != len(test.expected) {
+						t.Fatalf("expected %d buckets, got %d", len(test.expected), len(buckets))
+					}
+					for i, b := range buckets {
+						e := test.expected[i]
+						if !b.periodStart.Equal(e.periodStart) || b.activityID != e.activityID || b.count != e.count ||
+							b.totalCaloriesBurned != e.totalCaloriesBurned || b.avgCaloriesBurned != e.avgCaloriesBurned ||
+							b.totalDuration != e.totalDuration || b.avgDuration != e.avgDuration ||
+							b.totalDistanceMeters != e.totalDistanceMeters || b.totalElevationGain != e.totalElevationGain ||
+							b.heartRateWorkouts != e.heartRateWorkouts ||
+							(b.avgHeartRate == nil) != (e.avgHeartRate == nil) || b.avgHeartRate != nil && *b.avgHeartRate != *e.avgHeartRate {
+							t.Fatalf("expected bucket %d to be %+v, got %+v", i, e, b)
+						}
+					}
+				})
+			}
+		})
+	}
+}
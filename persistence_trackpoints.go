@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// trackpoint is a single recorded sample of a workout's track. every
+// measurement is optional, as devices and file formats differ in what
+// they record
+type trackpoint struct {
+	workoutID       string
+	sequence        int
+	time            *time.Time
+	latitude        *float64
+	longitude       *float64
+	elevationMeters *float64
+	heartRate       *int
+}
+
+type trackpointRepository interface {
+	SaveWorkoutWithTrackpoints(context.Context, *workout, []*trackpoint) error
+	GetTrackpoints(context.Context, *workout) ([]*trackpoint, error)
+}
+
+// saveWorkoutWithTrackpoints saves a workout along with its track, or
+// neither of them on error
+func saveWorkoutWithTrackpoints(ctx context.Context, baseLog *logrus.Entry, appData *appData, w *workout, trackpoints []*trackpoint) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout",
+		"event":  "save with track",
+		"count":  len(trackpoints),
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveWorkoutWithTrackpoints(ctx, w, trackpoints)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	updateAchievements(ctx, baseLog, appData, w.userID)
+	return nil
+}
+
+// getTrackpoints returns the track of a workout in recorded order
+func getTrackpoints(ctx context.Context, baseLog *logrus.Entry, appData *appData, w *workout) ([]*trackpoint, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "trackpoint",
+		"event":  "get all",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	trackpoints, err := appData.repository.GetTrackpoints(ctx, w)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return trackpoints, nil
+}
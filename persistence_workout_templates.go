@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// workoutTemplate describes a planned workout: an activity along with
+// optional targets to meet
+type workoutTemplate struct {
+	templateID string
+	userID     string
+	activityID string
+	name       string
+
+	targetDuration       *time.Duration
+	targetCaloriesBurned *int
+	targetSets           *int
+}
+
+type workoutTemplateRepository interface {
+	SaveWorkoutTemplate(context.Context, *workoutTemplate) error
+	GetWorkoutTemplate(context.Context, *workoutTemplate) error
+	UpdateWorkoutTemplate(context.Context, *workoutTemplate) error
+	DeleteWorkoutTemplate(context.Context, *workoutTemplate) error
+	WorkoutTemplateExists(context.Context, *workoutTemplate) (bool, error)
+	GetAllWorkoutTemplates(ctx context.Context, userID string) ([]*workoutTemplate, error)
+}
+
+func (t *workoutTemplate) Type() string {
+	return "workout template"
+}
+
+func (t *workoutTemplate) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout template",
+		"event":  "save",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveWorkoutTemplate(ctx, t)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *workoutTemplate) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout template",
+		"event":  "get",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.GetWorkoutTemplate(ctx, t)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *workoutTemplate) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout template",
+		"event":  "update",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.UpdateWorkoutTemplate(ctx, t)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *workoutTemplate) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout template",
+		"event":  "delete",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.DeleteWorkoutTemplate(ctx, t)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return nil
+}
+
+func (t *workoutTemplate) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout template",
+		"event":  "exist",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	exists, err := appData.repository.WorkoutTemplateExists(ctx, t)
+	if err != nil {
+		return false, persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	return exists, nil
+}
+
+func getAllWorkoutTemplates(ctx context.Context, baseLog *logrus.Entry, appData *appData, userID string) ([]persistenceObject, error) {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout template",
+		"event":  "get all",
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	all, err := appData.repository.GetAllWorkoutTemplates(ctx, userID)
+	if err != nil {
+		return nil, persistenceErrorOf(err)
+	}
+
+	var templates []persistenceObject
+	for _, t := range all {
+		templates = append(templates, t)
+	}
+
+	log.Trace("database event completed")
+	return templates, nil
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -9,180 +12,329 @@ import (
 
 type workout struct {
 	workoutID      string
+	userID         string
 	activityID     string
 	timestamp      time.Time
 	caloriesBurned int
 	duration       time.Duration
+	// caloriesEstimated is set when caloriesBurned was estimated from the
+	// MET of the activity rather than measured
+	caloriesEstimated bool
+
+	// the optional measurements of a workout are nil when not recorded
+	distanceMeters *float64
+	avgHeartRate   *int
+	maxHeartRate   *int
+	elevationGain  *float64
+
+	// version is incremented on every write. Update, Patch and Delete only
+	// apply while the stored version still equals it, unless it is 0
+	version int64
+}
+
+type workoutRepository interface {
+	SaveWorkout(context.Context, *workout) error
+	SaveWorkouts(context.Context, []*workout) error
+	GetWorkout(context.Context, *workout) error
+	UpdateWorkout(context.Context, *workout) error
+	PatchWorkout(ctx context.Context, w *workout, fields []string) error
+	DeleteWorkout(context.Context, *workout) error
+	WorkoutExists(context.Context, *workout) (bool, error)
+	QueryWorkouts(context.Context, *workoutQuery) ([]*workout, error)
+}
+
+// bounds of a plausible heart rate in beats per minute
+const (
+	minHeartRate = 20
+	maxHeartRate = 250
+)
+
+const (
+	workoutSortTimestamp      = "timestamp"
+	workoutSortCaloriesBurned = "calories_burned"
+	workoutSortDuration       = "duration"
+)
+
+// workoutQuery selects a user's workouts. nil filters are not applied
+// and a limit of 0 returns every matching workout
+type workoutQuery struct {
+	userID string
+
+	activityID  *string
+	from        *time.Time
+	to          *time.Time
+	minDuration *time.Duration
+	maxDuration *time.Duration
+	minDistance *float64
+	maxDistance *float64
+
+	minAvgHeartRate *int
+	maxAvgHeartRate *int
+
+	sort       string
+	descending bool
+
+	after *workoutCursor
+	limit int
+}
+
+// workoutCursor marks the position of the last workout of a page. it is
+// handed to clients as an opaque string
+type workoutCursor struct {
+	Sort           string    `json:"s"`
+	Descending     bool      `json:"d,omitempty"`
+	Timestamp      time.Time `json:"t"`
+	CaloriesBurned int       `json:"c,omitempty"`
+	Duration       int64     `json:"u,omitempty"`
+	WorkoutID      string    `json:"w"`
+}
+
+func newWorkoutCursor(w *workout, query *workoutQuery) *workoutCursor {
+	return &workoutCursor{
+		Sort:           query.sort,
+		Descending:     query.descending,
+		Timestamp:      w.timestamp,
+		CaloriesBurned: w.caloriesBurned,
+		Duration:       int64(w.duration),
+		WorkoutID:      w.workoutID,
+	}
+}
+
+func (c *workoutCursor) encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeWorkoutCursor(encoded string) (*workoutCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+	c := &workoutCursor{}
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", err)
+	}
+	if c.WorkoutID == "" {
+		return nil, fmt.Errorf("malformed cursor: missing workout id")
+	}
+	return c, nil
+}
+
+// sortValue returns the value the cursor holds for its sort column
+func (c *workoutCursor) sortValue() interface{} {
+	switch c.Sort {
+	case workoutSortCaloriesBurned:
+		return c.CaloriesBurned
+	case workoutSortDuration:
+		return time.Duration(c.Duration)
+	default:
+		return c.Timestamp
+	}
+}
+
+// pace is the time taken per kilometer in seconds, or nil when it cannot
+// be derived from the workout's distance and duration
+func (w *workout) pace() *float64 {
+	if w.distanceMeters == nil || *w.distanceMeters <= 0 || w.duration <= 0 {
+		return nil
+	}
+	pace := w.duration.Seconds() / (*w.distanceMeters / 1000)
+	return &pace
+}
+
+// speed is the average speed in meters per second, or nil when it cannot
+// be derived from the workout's distance and duration
+func (w *workout) speed() *float64 {
+	if w.distanceMeters == nil || w.duration <= 0 {
+		return nil
+	}
+	speed := *w.distanceMeters / w.duration.Seconds()
+	return &speed
+}
+
+// validateMetrics checks the measurements of w agree with each other,
+// once a patch has combined the given ones with the stored ones
+func (w *workout) validateMetrics() error {
+	if w.avgHeartRate != nil && w.maxHeartRate != nil && *w.avgHeartRate > *w.maxHeartRate {
+		return validationErrors{{
+			Field:   "avg_heart_rate",
+			Code:    validationCodeTooLarge,
+			Message: "must not be greater than max_heart_rate",
+		}}
+	}
+	return nil
 }
 
 func (a *workout) Type() string {
 	return "workout"
 }
 
-func (w *workout) Save(baseLog *logrus.Entry, appData *appData) error {
+func (w *workout) Save(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "workout",
 		"event":  "save",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	tag, err := appData.db.Exec(context.Background(), `
-		INSERT INTO workouts (
-			workout_id,
-			activity_id,
-			timestamp,
-			calories_burned,
-			duration
-		) VALUES ($1,$2,$3,$4,$5)`,
-		w.workoutID,
-		w.activityID,
-		w.timestamp,
-		w.caloriesBurned,
-		w.duration,
-	)
-	if err != nil || tag.RowsAffected() != 1 {
-		return err
+	err := appData.repository.SaveWorkout(ctx, w)
+	if err != nil {
+		return persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
+	updateAchievements(ctx, baseLog, appData, w.userID)
 	return nil
 }
 
-func (w *workout) Get(baseLog *logrus.Entry, appData *appData) error {
+func (w *workout) Get(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "workout",
 		"event":  "get",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	err := appData.db.QueryRow(context.Background(), `
-		SELECT 
-			workout_id,
-			activity_id,
-			timestamp,
-			calories_burned,
-			duration
-		FROM workouts
-		WHERE workout_id = $1`, w.workoutID).Scan(
-		&w.workoutID,
-		&w.activityID,
-		&w.timestamp,
-		&w.caloriesBurned,
-		&w.duration,
-	)
+	err := appData.repository.GetWorkout(ctx, w)
 	if err != nil {
-		return err
+		return persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
 	return nil
 }
 
-func (w *workout) Update(baseLog *logrus.Entry, appData *appData) error {
+func (w *workout) Update(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "workout",
 		"event":  "update",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	tag, err := appData.db.Exec(context.Background(), `
-		UPDATE workouts SET (
-			workout_id,
-			activity_id,
-			timestamp,
-			calories_burned,
-			duration
-		) = ($1,$2,$3,$4,$5)
-		WHERE workout_id = $1`,
-		w.workoutID,
-		w.activityID,
-		w.timestamp,
-		w.caloriesBurned,
-		w.duration,
-	)
-	if err != nil || tag.RowsAffected() != 1 {
-		return err
+	err := appData.repository.UpdateWorkout(ctx, w)
+	if err != nil {
+		return persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
+	updateAchievements(ctx, baseLog, appData, w.userID)
 	return nil
 }
 
-func (w *workout) Delete(baseLog *logrus.Entry, appData *appData) error {
+// Patch returns a persistence func updating only the given fields, named
+// by their columns, leaving the rest of the stored workout untouched
+func (w *workout) Patch(fields ...string) func(context.Context, *logrus.Entry, *appData) error {
+	return func(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
+		log := baseLog.WithFields(logrus.Fields{
+			"entity": "workout",
+			"event":  "patch",
+		})
+		log.Trace("database event initiated")
+		defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+		err := appData.repository.PatchWorkout(ctx, w, fields)
+		if err != nil {
+			return persistenceErrorOf(err)
+		}
+
+		log.Trace("database event completed")
+		updateAchievements(ctx, baseLog, appData, w.userID)
+		return nil
+	}
+}
+
+func (w *workout) Delete(ctx context.Context, baseLog *logrus.Entry, appData *appData) error {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "workout",
 		"event":  "delete",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	tag, err := appData.db.Exec(context.Background(), `
-		DELETE FROM workouts
-		WHERE workout_id = $1`,
-		w.workoutID,
-	)
-	if err != nil || tag.RowsAffected() != 1 {
-		return err
+	err := appData.repository.DeleteWorkout(ctx, w)
+	if err != nil {
+		return persistenceDeleteErrorOf(err)
 	}
 
 	log.Trace("database event completed")
+	updateAchievements(ctx, baseLog, appData, w.userID)
 	return nil
 }
 
-func (w *workout) Exists(baseLog *logrus.Entry, appData *appData) (bool, error) {
+func (w *workout) Exists(ctx context.Context, baseLog *logrus.Entry, appData *appData) (bool, error) {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "workout",
 		"event":  "exist",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	var count int
-	err := appData.db.QueryRow(context.Background(), `
-		SELECT count(*)
-		FROM workouts
-		WHERE workout_id = $1`, w.workoutID).Scan(&count)
+	exists, err := appData.repository.WorkoutExists(ctx, w)
 	if err != nil {
-		return false, err
+		return false, persistenceErrorOf(err)
 	}
 
 	log.Trace("database event completed")
-	return count == 1, nil
+	return exists, nil
 }
 
-func getAllWorkouts(baseLog *logrus.Entry, appData *appData) ([]persistenceObject, error) {
+// queryWorkouts returns a page of workouts matching the query, along
+// with the cursor of the next page if there is one
+func queryWorkouts(ctx context.Context, baseLog *logrus.Entry, appData *appData, query *workoutQuery) ([]*workout, string, error) {
 	log := baseLog.WithFields(logrus.Fields{
 		"entity": "workout",
-		"event":  "get all",
+		"event":  "query",
 	})
 	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
 
-	rows, err := appData.db.Query(context.Background(), `
-		SELECT 
-			workout_id,
-			activity_id,
-			timestamp,
-			calories_burned,
-			duration
-		FROM workouts`)
+	// fetch one extra workout to find out whether there is another page
+	pageQuery := *query
+	if pageQuery.limit > 0 {
+		pageQuery.limit++
+	}
+	workouts, err := appData.repository.QueryWorkouts(ctx, &pageQuery)
 	if err != nil {
-		return nil, err
-	}
-
-	var workouts []persistenceObject
-	for rows.Next() {
-		w := &workout{}
-		err = rows.Scan(
-			&w.workoutID,
-			&w.activityID,
-			&w.timestamp,
-			&w.caloriesBurned,
-			&w.duration,
-		)
+		return nil, "", persistenceErrorOf(err)
+	}
+
+	next := ""
+	if query.limit > 0 && len(workouts) > query.limit {
+		workouts = workouts[:query.limit]
+		next, err = newWorkoutCursor(workouts[len(workouts)-1], query).encode()
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
-		workouts = append(workouts, w)
 	}
 
 	log.Trace("database event completed")
-	return workouts, nil
+	return workouts, next, nil
+}
+
+// saveWorkouts saves all of the workouts or, on error, none of them
+func saveWorkouts(ctx context.Context, baseLog *logrus.Entry, appData *appData, workouts []*workout) error {
+	log := baseLog.WithFields(logrus.Fields{
+		"entity": "workout",
+		"event":  "save batch",
+		"count":  len(workouts),
+	})
+	log.Trace("database event initiated")
+	defer appData.metrics.observeDatabaseEvent(log, time.Now())
+
+	err := appData.repository.SaveWorkouts(ctx, workouts)
+	if err != nil {
+		return persistenceErrorOf(err)
+	}
+
+	log.Trace("database event completed")
+	// a batch belongs to a single user
+	if len(workouts) > 0 {
+		updateAchievements(ctx, baseLog, appData, workouts[0].userID)
+	}
+	return nil
 }
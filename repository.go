@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	storagePostgres = "postgres"
+	storageMemory   = "memory"
+)
+
+// repository is the storage backend behind every persistenceObject.
+// it is implemented by postgresRepository and memoryRepository
+type repository interface {
+	userRepository
+	refreshTokenRepository
+	activityRepository
+	workoutRepository
+	trackpointRepository
+	exerciseRepository
+	workoutTemplateRepository
+	programRepository
+	goalRepository
+	achievementRepository
+	measurementRepository
+	statsRepository
+
+	Ping(context.Context) error
+	Close()
+}
+
+func initRepository(log *logrus.Logger, config *config) (repository, *pgxpool.Pool, error) {
+	log.WithField("storage", config.storage).Debug("initializing repository")
+
+	switch config.storage {
+	case storageMemory:
+		log.Warn("using in-memory storage, data will not persist between restarts")
+		return newMemoryRepository(), nil, nil
+	case storagePostgres:
+		db, err := initDatabase(log, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot initialize digital trainer database: %w", err)
+		}
+
+		if config.db.autoMigrate {
+			err = migrateUp(context.Background(), log, db)
+			if err != nil {
+				db.Close()
+				return nil, nil, fmt.Errorf("cannot migrate digital trainer database: %w", err)
+			}
+		}
+
+		return newPostgresRepository(db), db, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage type: %s", config.storage)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryRepository keeps everything in process memory. it mirrors the
+// constraints of the postgres schema so handlers behave the same way
+// regardless of the storage backend
+type memoryRepository struct {
+	mu sync.RWMutex
+
+	users         map[string]user
+	refreshTokens map[string]refreshToken
+	activities    map[string]activity
+	workouts      map[string]workout
+	trackpoints   map[string][]trackpoint
+	exercises     map[string]exercise
+
+	workoutTemplates map[string]workoutTemplate
+	programs         map[string]program
+
+	goals       map[string]goal
+	goalPeriods map[string][]goalPeriod
+
+	// achievements are keyed by user
+	achievements map[string][]achievement
+
+	measurements map[string]measurement
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		users:         make(map[string]user),
+		refreshTokens: make(map[string]refreshToken),
+		activities:    make(map[string]activity),
+		workouts:      make(map[string]workout),
+		trackpoints:   make(map[string][]trackpoint),
+		exercises:     make(map[string]exercise),
+
+		workoutTemplates: make(map[string]workoutTemplate),
+		programs:         make(map[string]program),
+
+		goals:       make(map[string]goal),
+		goalPeriods: make(map[string][]goalPeriod),
+
+		achievements: make(map[string][]achievement),
+
+		measurements: make(map[string]measurement),
+	}
+}
+
+func (m *memoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *memoryRepository) Close() {}
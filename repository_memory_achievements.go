@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+func (m *memoryRepository) UpdateAchievements(ctx context.Context, userID string, evaluate func([]*workout) []*achievement) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var workouts []*workout
+	for _, stored := range m.workouts {
+		if stored.userID != userID {
+			continue
+		}
+		w := stored
+		workouts = append(workouts, &w)
+	}
+	// the map order is random, while postgres breaks ties in timestamp the
+	// same way every time
+	sort.Slice(workouts, func(i, j int) bool {
+		if !workouts[i].timestamp.Equal(workouts[j].timestamp) {
+			return workouts[i].timestamp.Before(workouts[j].timestamp)
+		}
+		return workouts[i].workoutID < workouts[j].workoutID
+	})
+
+	var achievements []achievement
+	for _, a := range evaluate(workouts) {
+		achievements = append(achievements, *a)
+	}
+	if len(achievements) == 0 {
+		delete(m.achievements, userID)
+		return nil
+	}
+	m.achievements[userID] = achievements
+	return nil
+}
+
+func (m *memoryRepository) GetAchievements(ctx context.Context, userID string) ([]*achievement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var achievements []*achievement
+	for _, stored := range m.achievements[userID] {
+		a := stored
+		achievements = append(achievements, &a)
+	}
+	sort.SliceStable(achievements, func(i, j int) bool {
+		if !achievements[i].earnedAt.Equal(achievements[j].earnedAt) {
+			return achievements[i].earnedAt.Before(achievements[j].earnedAt)
+		}
+		return achievements[i].code < achievements[j].code
+	})
+	return achievements, nil
+}
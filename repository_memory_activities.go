@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+func (m *memoryRepository) SaveActivity(ctx context.Context, a *activity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.activities[a.activityID]; ok {
+		return newPersistenceError(errUniqueViolation, "activity %s already exists", a.activityID)
+	}
+	// mirror the activities.user_id foreign key
+	if _, ok := m.users[a.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by activity %s does not exist", a.userID, a.activityID)
+	}
+	a.version = 1
+	m.activities[a.activityID] = *a
+	return nil
+}
+
+func (m *memoryRepository) GetActivity(ctx context.Context, a *activity) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.findActivity(a)
+	if !ok {
+		return newPersistenceError(errNotFound, "activity %s not found", a.activityID)
+	}
+	*a = stored
+	return nil
+}
+
+func (m *memoryRepository) UpdateActivity(ctx context.Context, a *activity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findActivity(a)
+	if !ok {
+		return newPersistenceError(errNotFound, "activity %s not found", a.activityID)
+	}
+	if a.version != 0 && a.version != stored.version {
+		return errVersionMismatch
+	}
+	a.version = stored.version + 1
+	m.activities[a.activityID] = *a
+	return nil
+}
+
+func (m *memoryRepository) PatchActivity(ctx context.Context, a *activity, fields []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findActivity(a)
+	if !ok {
+		return newPersistenceError(errNotFound, "activity %s not found", a.activityID)
+	}
+	if a.version != 0 && a.version != stored.version {
+		return errVersionMismatch
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	for _, field := range fields {
+		switch field {
+		case "name":
+			stored.name = a.name
+		case "met":
+			stored.met = a.met
+		default:
+			return fmt.Errorf("column %s of activities cannot be patched", field)
+		}
+	}
+	stored.version++
+	a.version = stored.version
+	m.activities[a.activityID] = stored
+	return nil
+}
+
+func (m *memoryRepository) DeleteActivity(ctx context.Context, a *activity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findActivity(a)
+	if !ok {
+		return newPersistenceError(errNotFound, "activity %s not found", a.activityID)
+	}
+	if a.version != 0 && a.version != stored.version {
+		return errVersionMismatch
+	}
+	// mirror the workouts.activity_id foreign key
+	for _, w := range m.workouts {
+		if w.activityID == a.activityID {
+			return newPersistenceError(errConflict, "activity %s is still referenced by workout %s", a.activityID, w.workoutID)
+		}
+	}
+	// and the exercises.activity_id foreign key
+	for _, e := range m.exercises {
+		if e.activityID == a.activityID {
+			return newPersistenceError(errConflict, "activity %s is still referenced by exercise %s", a.activityID, e.exerciseID)
+		}
+	}
+	// and the workout_templates.activity_id foreign key
+	for _, t := range m.workoutTemplates {
+		if t.activityID == a.activityID {
+			return newPersistenceError(errConflict, "activity %s is still referenced by workout template %s", a.activityID, t.templateID)
+		}
+	}
+	// and the goals.activity_id foreign key
+	for _, g := range m.goals {
+		if g.activityID != nil && *g.activityID == a.activityID {
+			return newPersistenceError(errConflict, "activity %s is still referenced by goal %s", a.activityID, g.goalID)
+		}
+	}
+	delete(m.activities, a.activityID)
+	return nil
+}
+
+func (m *memoryRepository) ActivityExists(ctx context.Context, a *activity) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.findActivity(a)
+	return ok, nil
+}
+
+func (m *memoryRepository) GetAllActivities(ctx context.Context, userID string) ([]*activity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var activities []*activity
+	for _, stored := range m.activities {
+		if stored.userID != userID {
+			continue
+		}
+		a := stored
+		activities = append(activities, &a)
+	}
+	sort.Slice(activities, func(i, j int) bool {
+		return activities[i].activityID < activities[j].activityID
+	})
+	return activities, nil
+}
+
+// findActivity looks up the activity with a's id, scoped to a's owner.
+// the caller must hold m.mu
+func (m *memoryRepository) findActivity(a *activity) (activity, bool) {
+	stored, ok := m.activities[a.activityID]
+	if !ok || stored.userID != a.userID {
+		return activity{}, false
+	}
+	return stored, true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+func (m *memoryRepository) SaveExercise(ctx context.Context, e *exercise) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.exercises[e.exerciseID]; ok {
+		return newPersistenceError(errUniqueViolation, "exercise %s already exists", e.exerciseID)
+	}
+	if err := m.checkExerciseReferences(e); err != nil {
+		return err
+	}
+	count := m.countExercises(e.workoutID)
+	if e.position < 0 || e.position > count {
+		e.position = count
+	}
+	m.shiftExercises(e.workoutID, e.position, count, 1)
+	m.exercises[e.exerciseID] = copyExercise(e)
+	return nil
+}
+
+func (m *memoryRepository) GetExercise(ctx context.Context, e *exercise) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.findExercise(e)
+	if !ok {
+		return newPersistenceError(errNotFound, "exercise %s not found", e.exerciseID)
+	}
+	*e = copyExercise(&stored)
+	return nil
+}
+
+func (m *memoryRepository) UpdateExercise(ctx context.Context, e *exercise) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findExercise(e)
+	if !ok {
+		return newPersistenceError(errNotFound, "exercise %s not found", e.exerciseID)
+	}
+	if err := m.checkExerciseReferences(e); err != nil {
+		return err
+	}
+	count := m.countExercises(e.workoutID)
+	if e.position < 0 || e.position >= count {
+		e.position = stored.position
+	}
+	switch {
+	case e.position < stored.position:
+		m.shiftExercises(e.workoutID, e.position, stored.position-1, 1)
+	case e.position > stored.position:
+		m.shiftExercises(e.workoutID, stored.position+1, e.position, -1)
+	}
+	m.exercises[e.exerciseID] = copyExercise(e)
+	return nil
+}
+
+func (m *memoryRepository) DeleteExercise(ctx context.Context, e *exercise) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findExercise(e)
+	if !ok {
+		return newPersistenceError(errNotFound, "exercise %s not found", e.exerciseID)
+	}
+	delete(m.exercises, e.exerciseID)
+	m.shiftExercises(e.workoutID, stored.position+1, m.countExercises(e.workoutID), -1)
+	return nil
+}
+
+func (m *memoryRepository) ExerciseExists(ctx context.Context, e *exercise) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.findExercise(e)
+	return ok, nil
+}
+
+func (m *memoryRepository) GetExercises(ctx context.Context, w *workout) ([]*exercise, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var exercises []*exercise
+	for _, stored := range m.exercises {
+		if stored.workoutID != w.workoutID || stored.userID != w.userID {
+			continue
+		}
+		e := copyExercise(&stored)
+		exercises = append(exercises, &e)
+	}
+	sort.Slice(exercises, func(i, j int) bool {
+		return exercises[i].position < exercises[j].position
+	})
+	return exercises, nil
+}
+
+// findExercise returns the stored exercise, if it belongs to the workout
+// and user of e. the caller must hold m.mu
+func (m *memoryRepository) findExercise(e *exercise) (exercise, bool) {
+	stored, ok := m.exercises[e.exerciseID]
+	if !ok || stored.workoutID != e.workoutID || stored.userID != e.userID {
+		return exercise{}, false
+	}
+	return stored, true
+}
+
+// checkExerciseReferences mirrors the foreign keys on the exercises table.
+// the caller must hold m.mu
+func (m *memoryRepository) checkExerciseReferences(e *exercise) error {
+	if _, ok := m.workouts[e.workoutID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "workout %s referenced by exercise %s does not exist", e.workoutID, e.exerciseID)
+	}
+	if _, ok := m.users[e.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by exercise %s does not exist", e.userID, e.exerciseID)
+	}
+	if _, ok := m.activities[e.activityID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "activity %s referenced by exercise %s does not exist", e.activityID, e.exerciseID)
+	}
+	return nil
+}
+
+// countExercises returns the number of exercises of a workout. the caller
+// must hold m.mu
+func (m *memoryRepository) countExercises(workoutID string) int {
+	count := 0
+	for _, e := range m.exercises {
+		if e.workoutID == workoutID {
+			count++
+		}
+	}
+	return count
+}
+
+// shiftExercises moves the exercises of a workout positioned from first to
+// last, inclusive, by delta. the caller must hold m.mu
+func (m *memoryRepository) shiftExercises(workoutID string, first int, last int, delta int) {
+	for id, e := range m.exercises {
+		if e.workoutID == workoutID && e.position >= first && e.position <= last {
+			e.position += delta
+			m.exercises[id] = e
+		}
+	}
+}
+
+// copyExercise copies e along with its sets, so that the stored exercise
+// does not share them with the caller
+func copyExercise(e *exercise) exercise {
+	c := *e
+	c.sets = append([]exerciseSet(nil), e.sets...)
+	return c
+}
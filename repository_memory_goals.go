@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+func (m *memoryRepository) SaveGoal(ctx context.Context, g *goal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.goals[g.goalID]; ok {
+		return newPersistenceError(errUniqueViolation, "goal %s already exists", g.goalID)
+	}
+	if err := m.checkGoalReferences(g); err != nil {
+		return err
+	}
+	m.goals[g.goalID] = *g
+	return nil
+}
+
+func (m *memoryRepository) GetGoal(ctx context.Context, g *goal) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.findGoal(g)
+	if !ok {
+		return newPersistenceError(errNotFound, "goal %s not found", g.goalID)
+	}
+	*g = stored
+	return nil
+}
+
+func (m *memoryRepository) UpdateGoal(ctx context.Context, g *goal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findGoal(g); !ok {
+		return newPersistenceError(errNotFound, "goal %s not found", g.goalID)
+	}
+	if err := m.checkGoalReferences(g); err != nil {
+		return err
+	}
+	m.goals[g.goalID] = *g
+
+	var kept []goalPeriod
+	for _, p := range m.goalPeriods[g.goalID] {
+		if !p.periodStart.Before(g.startedAt) {
+			kept = append(kept, p)
+		}
+	}
+	m.goalPeriods[g.goalID] = kept
+	return nil
+}
+
+func (m *memoryRepository) DeleteGoal(ctx context.Context, g *goal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findGoal(g); !ok {
+		return newPersistenceError(errNotFound, "goal %s not found", g.goalID)
+	}
+	// mirror the ON DELETE CASCADE of the goal's history
+	delete(m.goalPeriods, g.goalID)
+	delete(m.goals, g.goalID)
+	return nil
+}
+
+func (m *memoryRepository) GoalExists(ctx context.Context, g *goal) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.findGoal(g)
+	return ok, nil
+}
+
+func (m *memoryRepository) GetAllGoals(ctx context.Context, userID string) ([]*goal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var goals []*goal
+	for _, stored := range m.goals {
+		if stored.userID != userID {
+			continue
+		}
+		g := stored
+		goals = append(goals, &g)
+	}
+	sort.Slice(goals, func(i, j int) bool {
+		if !goals[i].startedAt.Equal(goals[j].startedAt) {
+			return goals[i].startedAt.Before(goals[j].startedAt)
+		}
+		return goals[i].goalID < goals[j].goalID
+	})
+	return goals, nil
+}
+
+func (m *memoryRepository) RecordGoalPeriods(ctx context.Context, g *goal, periods []*goalPeriod) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// mirror the goal_periods.goal_id foreign key
+	if _, ok := m.goals[g.goalID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "goal %s referenced by goal periods does not exist", g.goalID)
+	}
+	recorded := m.goalPeriods[g.goalID]
+	for _, p := range periods {
+		exists := false
+		for _, r := range recorded {
+			if r.periodStart.Equal(p.periodStart) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			recorded = append(recorded, *p)
+		}
+	}
+	sort.Slice(recorded, func(i, j int) bool {
+		return recorded[i].periodStart.Before(recorded[j].periodStart)
+	})
+	m.goalPeriods[g.goalID] = recorded
+	return nil
+}
+
+func (m *memoryRepository) GetGoalPeriods(ctx context.Context, g *goal) ([]*goalPeriod, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	recorded := m.goalPeriods[g.goalID]
+	periods := make([]*goalPeriod, 0, len(recorded))
+	for i := len(recorded) - 1; i >= 0; i-- {
+		p := recorded[i]
+		periods = append(periods, &p)
+	}
+	return periods, nil
+}
+
+// findGoal returns the stored goal, if it belongs to the user of g. the
+// caller must hold m.mu
+func (m *memoryRepository) findGoal(g *goal) (goal, bool) {
+	stored, ok := m.goals[g.goalID]
+	if !ok || stored.userID != g.userID {
+		return goal{}, false
+	}
+	return stored, true
+}
+
+// checkGoalReferences mirrors the foreign keys on the goals table. the
+// caller must hold m.mu
+func (m *memoryRepository) checkGoalReferences(g *goal) error {
+	if _, ok := m.users[g.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by goal %s does not exist", g.userID, g.goalID)
+	}
+	if g.activityID != nil {
+		if _, ok := m.activities[*g.activityID]; !ok {
+			return newPersistenceError(errForeignKeyViolation, "activity %s referenced by goal %s does not exist", *g.activityID, g.goalID)
+		}
+	}
+	return nil
+}
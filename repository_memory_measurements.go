@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (m *memoryRepository) SaveMeasurement(ctx context.Context, ms *measurement) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.measurements[ms.measurementID]; ok {
+		return newPersistenceError(errUniqueViolation, "measurement %s already exists", ms.measurementID)
+	}
+	// mirror the measurements.user_id foreign key
+	if _, ok := m.users[ms.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by measurement %s does not exist", ms.userID, ms.measurementID)
+	}
+	m.measurements[ms.measurementID] = *ms
+	return nil
+}
+
+func (m *memoryRepository) GetMeasurement(ctx context.Context, ms *measurement) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.findMeasurement(ms)
+	if !ok {
+		return newPersistenceError(errNotFound, "measurement %s not found", ms.measurementID)
+	}
+	*ms = stored
+	return nil
+}
+
+func (m *memoryRepository) UpdateMeasurement(ctx context.Context, ms *measurement) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findMeasurement(ms); !ok {
+		return newPersistenceError(errNotFound, "measurement %s not found", ms.measurementID)
+	}
+	m.measurements[ms.measurementID] = *ms
+	return nil
+}
+
+func (m *memoryRepository) DeleteMeasurement(ctx context.Context, ms *measurement) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findMeasurement(ms); !ok {
+		return newPersistenceError(errNotFound, "measurement %s not found", ms.measurementID)
+	}
+	delete(m.measurements, ms.measurementID)
+	return nil
+}
+
+func (m *memoryRepository) MeasurementExists(ctx context.Context, ms *measurement) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.findMeasurement(ms)
+	return ok, nil
+}
+
+func (m *memoryRepository) QueryMeasurements(ctx context.Context, query *measurementQuery) ([]*measurement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var measurements []*measurement
+	for _, stored := range m.measurements {
+		if stored.userID != query.userID ||
+			query.kind != nil && stored.kind != *query.kind ||
+			query.from != nil && stored.measuredAt.Before(*query.from) ||
+			query.to != nil && !stored.measuredAt.Before(*query.to) {
+			continue
+		}
+		ms := stored
+		measurements = append(measurements, &ms)
+	}
+	sortMeasurements(measurements)
+	return measurements, nil
+}
+
+func (m *memoryRepository) GetMeasurementAt(ctx context.Context, userID string, kind string, at time.Time) (*measurement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var measurements []*measurement
+	for _, stored := range m.measurements {
+		if stored.userID == userID && stored.kind == kind {
+			ms := stored
+			measurements = append(measurements, &ms)
+		}
+	}
+	if len(measurements) == 0 {
+		return nil, nil
+	}
+	sortMeasurements(measurements)
+
+	// the last one taken by then, if any was
+	i := sort.Search(len(measurements), func(i int) bool {
+		return measurements[i].measuredAt.After(at)
+	})
+	if i == 0 {
+		return measurements[0], nil
+	}
+	return measurements[i-1], nil
+}
+
+// findMeasurement returns the stored measurement, if it belongs to the user
+// of ms. the caller must hold m.mu
+func (m *memoryRepository) findMeasurement(ms *measurement) (measurement, bool) {
+	stored, ok := m.measurements[ms.measurementID]
+	if !ok || stored.userID != ms.userID {
+		return measurement{}, false
+	}
+	return stored, true
+}
+
+// sortMeasurements sorts oldest first, like the postgres queries
+func sortMeasurements(measurements []*measurement) {
+	sort.Slice(measurements, func(i, j int) bool {
+		if !measurements[i].measuredAt.Equal(measurements[j].measuredAt) {
+			return measurements[i].measuredAt.Before(measurements[j].measuredAt)
+		}
+		return measurements[i].measurementID < measurements[j].measurementID
+	})
+}
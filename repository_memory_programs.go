@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (m *memoryRepository) SaveProgram(ctx context.Context, p *program) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.programs[p.programID]; ok {
+		return newPersistenceError(errUniqueViolation, "program %s already exists", p.programID)
+	}
+	if err := m.checkProgramReferences(p); err != nil {
+		return err
+	}
+	m.programs[p.programID] = copyProgram(p)
+	return nil
+}
+
+func (m *memoryRepository) GetProgram(ctx context.Context, p *program) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.findProgram(p)
+	if !ok {
+		return newPersistenceError(errNotFound, "program %s not found", p.programID)
+	}
+	*p = copyProgram(&stored)
+	return nil
+}
+
+func (m *memoryRepository) UpdateProgram(ctx context.Context, p *program) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findProgram(p); !ok {
+		return newPersistenceError(errNotFound, "program %s not found", p.programID)
+	}
+	if err := m.checkProgramReferences(p); err != nil {
+		return err
+	}
+	m.programs[p.programID] = copyProgram(p)
+	return nil
+}
+
+func (m *memoryRepository) DeleteProgram(ctx context.Context, p *program) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findProgram(p); !ok {
+		return newPersistenceError(errNotFound, "program %s not found", p.programID)
+	}
+	delete(m.programs, p.programID)
+	return nil
+}
+
+func (m *memoryRepository) ProgramExists(ctx context.Context, p *program) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.findProgram(p)
+	return ok, nil
+}
+
+func (m *memoryRepository) GetAllPrograms(ctx context.Context, userID string) ([]*program, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var programs []*program
+	for _, stored := range m.programs {
+		if stored.userID != userID {
+			continue
+		}
+		p := copyProgram(&stored)
+		programs = append(programs, &p)
+	}
+	sort.Slice(programs, func(i, j int) bool {
+		if !programs[i].startDate.Equal(programs[j].startDate) {
+			return programs[i].startDate.Before(programs[j].startDate)
+		}
+		return programs[i].programID < programs[j].programID
+	})
+	return programs, nil
+}
+
+func (m *memoryRepository) CompleteProgramSession(ctx context.Context, w *workout) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// the earliest program first, like the postgres ordering
+	var match *programSession
+	var matchDate time.Time
+	for _, p := range m.programs {
+		if p.userID != w.userID {
+			continue
+		}
+		t := w.timestamp.In(p.location)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		for i := range p.sessions {
+			// the stored program's sessions, not a copy
+			s := &p.sessions[i]
+			if s.workoutID != nil || !p.date(s).Equal(day) ||
+				m.workoutTemplates[s.templateID].activityID != w.activityID {
+				continue
+			}
+			if match == nil || p.startDate.Before(matchDate) ||
+				p.startDate.Equal(matchDate) && s.sessionID < match.sessionID {
+				match, matchDate = s, p.startDate
+			}
+		}
+	}
+	if match == nil {
+		return "", nil
+	}
+	workoutID := w.workoutID
+	match.workoutID = &workoutID
+	return match.sessionID, nil
+}
+
+// findProgram returns the stored program, if it belongs to the user of p.
+// the caller must hold m.mu
+func (m *memoryRepository) findProgram(p *program) (program, bool) {
+	stored, ok := m.programs[p.programID]
+	if !ok || stored.userID != p.userID {
+		return program{}, false
+	}
+	return stored, true
+}
+
+// checkProgramReferences mirrors the foreign keys on the programs and
+// program_sessions tables. the caller must hold m.mu
+func (m *memoryRepository) checkProgramReferences(p *program) error {
+	if _, ok := m.users[p.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by program %s does not exist", p.userID, p.programID)
+	}
+	for _, s := range p.sessions {
+		if _, ok := m.workoutTemplates[s.templateID]; !ok {
+			return newPersistenceError(errForeignKeyViolation, "workout template %s referenced by program %s does not exist", s.templateID, p.programID)
+		}
+		if s.workoutID != nil {
+			if _, ok := m.workouts[*s.workoutID]; !ok {
+				return newPersistenceError(errForeignKeyViolation, "workout %s referenced by program %s does not exist", *s.workoutID, p.programID)
+			}
+		}
+	}
+	return nil
+}
+
+// copyProgram copies p along with its sessions, so that the stored program
+// does not share them with the caller
+func copyProgram(p *program) program {
+	c := *p
+	c.sessions = append([]programSession(nil), p.sessions...)
+	return c
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func (m *memoryRepository) SaveRefreshToken(ctx context.Context, t *refreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.refreshTokens[t.tokenHash]; ok {
+		return newPersistenceError(errUniqueViolation, "refresh token already exists")
+	}
+	// mirror the refresh_tokens.user_id foreign key
+	if _, ok := m.users[t.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by refresh token does not exist", t.userID)
+	}
+	t.createdAt = time.Now()
+	m.refreshTokens[t.tokenHash] = *t
+	return nil
+}
+
+func (m *memoryRepository) GetRefreshToken(ctx context.Context, t *refreshToken) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.refreshTokens[t.tokenHash]
+	if !ok {
+		return newPersistenceError(errNotFound, "refresh token not found")
+	}
+	*t = stored
+	return nil
+}
+
+func (m *memoryRepository) UpdateRefreshToken(ctx context.Context, t *refreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.refreshTokens[t.tokenHash]
+	if !ok {
+		return newPersistenceError(errNotFound, "refresh token not found")
+	}
+	stored.expiresAt = t.expiresAt
+	stored.revokedAt = t.revokedAt
+	m.refreshTokens[t.tokenHash] = stored
+	return nil
+}
+
+func (m *memoryRepository) RevokeRefreshToken(ctx context.Context, t *refreshToken) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.refreshTokens[t.tokenHash]
+	now := time.Now()
+	if !ok || !stored.usable(now) {
+		return false, nil
+	}
+	stored.revokedAt = &now
+	m.refreshTokens[t.tokenHash] = stored
+	*t = stored
+	return true, nil
+}
+
+func (m *memoryRepository) DeleteRefreshToken(ctx context.Context, t *refreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.refreshTokens[t.tokenHash]; !ok {
+		return newPersistenceError(errNotFound, "refresh token not found")
+	}
+	delete(m.refreshTokens, t.tokenHash)
+	return nil
+}
+
+func (m *memoryRepository) RefreshTokenExists(ctx context.Context, t *refreshToken) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.refreshTokens[t.tokenHash]
+	return ok, nil
+}
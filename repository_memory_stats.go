@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+func (m *memoryRepository) GetWorkoutStats(ctx context.Context, query *workoutStatsQuery) ([]*workoutStatsBucket, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filter := &workoutQuery{
+		activityID: query.activityID,
+		from:       query.from,
+		to:         query.to,
+	}
+
+	type bucketKey struct {
+		periodStart time.Time
+		activityID  string
+	}
+	bucketsByKey := make(map[bucketKey]*workoutStatsBucket)
+	totalHeartRates := make(map[*workoutStatsBucket]int64)
+	for _, stored := range m.workouts {
+		if stored.userID != query.userID || !workoutMatchesQuery(&stored, filter) {
+			continue
+		}
+
+		key := bucketKey{
+			periodStart: truncateToPeriod(stored.timestamp, query.groupBy, query.location),
+		}
+		if query.byActivity {
+			key.activityID = stored.activityID
+		}
+		b, ok := bucketsByKey[key]
+		if !ok {
+			b = &workoutStatsBucket{
+				periodStart: key.periodStart,
+				activityID:  key.activityID,
+			}
+			bucketsByKey[key] = b
+		}
+		b.count++
+		b.totalCaloriesBurned += int64(stored.caloriesBurned)
+		b.totalDuration += stored.duration
+		if stored.distanceMeters != nil {
+			b.totalDistanceMeters += *stored.distanceMeters
+		}
+		if stored.elevationGain != nil {
+			b.totalElevationGain += *stored.elevationGain
+		}
+		if stored.avgHeartRate != nil {
+			b.heartRateWorkouts++
+			totalHeartRates[b] += int64(*stored.avgHeartRate)
+		}
+	}
+
+	var buckets []*workoutStatsBucket
+	for _, b := range bucketsByKey {
+		b.avgCaloriesBurned = float64(b.totalCaloriesBurned) / float64(b.count)
+		b.avgDuration = b.totalDuration / time.Duration(b.count)
+		if b.heartRateWorkouts > 0 {
+			avgHeartRate := float64(totalHeartRates[b]) / float64(b.heartRateWorkouts)
+			b.avgHeartRate = &avgHeartRate
+		}
+		buckets = append(buckets, b)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if !buckets[i].periodStart.Equal(buckets[j].periodStart) {
+			return buckets[i].periodStart.Before(buckets[j].periodStart)
+		}
+		return buckets[i].activityID < buckets[j].activityID
+	})
+	return buckets, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+)
+
+func (m *memoryRepository) SaveWorkoutWithTrackpoints(ctx context.Context, w *workout, trackpoints []*trackpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workouts[w.workoutID]; ok {
+		return newPersistenceError(errUniqueViolation, "workout %s already exists", w.workoutID)
+	}
+	if err := m.checkWorkoutReferences(w); err != nil {
+		return err
+	}
+	stored := make([]trackpoint, len(trackpoints))
+	for i, t := range trackpoints {
+		t.workoutID = w.workoutID
+		stored[i] = *t
+	}
+	w.version = 1
+	m.workouts[w.workoutID] = *w
+	m.trackpoints[w.workoutID] = stored
+	return nil
+}
+
+func (m *memoryRepository) GetTrackpoints(ctx context.Context, w *workout) ([]*trackpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.findWorkout(w); !ok {
+		return nil, nil
+	}
+	var trackpoints []*trackpoint
+	for _, stored := range m.trackpoints[w.workoutID] {
+		t := stored
+		trackpoints = append(trackpoints, &t)
+	}
+	return trackpoints, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+func (m *memoryRepository) SaveUser(ctx context.Context, u *user) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[u.userID]; ok {
+		return newPersistenceError(errUniqueViolation, "user %s already exists", u.userID)
+	}
+	if err := m.checkUserEmailUnique(u); err != nil {
+		return err
+	}
+	u.createdAt = time.Now()
+	m.users[u.userID] = *u
+	return nil
+}
+
+func (m *memoryRepository) GetUser(ctx context.Context, u *user) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.users[u.userID]
+	if !ok {
+		return newPersistenceError(errNotFound, "user %s not found", u.userID)
+	}
+	*u = stored
+	return nil
+}
+
+func (m *memoryRepository) UpdateUser(ctx context.Context, u *user) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[u.userID]
+	if !ok {
+		return newPersistenceError(errNotFound, "user %s not found", u.userID)
+	}
+	if err := m.checkUserEmailUnique(u); err != nil {
+		return err
+	}
+	stored.name = u.name
+	stored.email = u.email
+	stored.weightKg = u.weightKg
+	m.users[u.userID] = stored
+	return nil
+}
+
+func (m *memoryRepository) DeleteUser(ctx context.Context, u *user) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[u.userID]; !ok {
+		return newPersistenceError(errNotFound, "user %s not found", u.userID)
+	}
+	// mirror the ON DELETE CASCADE of everything owned by the user
+	for hash, t := range m.refreshTokens {
+		if t.userID == u.userID {
+			delete(m.refreshTokens, hash)
+		}
+	}
+	delete(m.achievements, u.userID)
+	for id, ms := range m.measurements {
+		if ms.userID == u.userID {
+			delete(m.measurements, id)
+		}
+	}
+	for id, g := range m.goals {
+		if g.userID == u.userID {
+			delete(m.goalPeriods, id)
+			delete(m.goals, id)
+		}
+	}
+	for id, p := range m.programs {
+		if p.userID == u.userID {
+			delete(m.programs, id)
+		}
+	}
+	for id, t := range m.workoutTemplates {
+		if t.userID == u.userID {
+			delete(m.workoutTemplates, id)
+		}
+	}
+	for id, e := range m.exercises {
+		if e.userID == u.userID {
+			delete(m.exercises, id)
+		}
+	}
+	for id, w := range m.workouts {
+		if w.userID == u.userID {
+			delete(m.trackpoints, id)
+			delete(m.workouts, id)
+		}
+	}
+	for id, a := range m.activities {
+		if a.userID == u.userID {
+			delete(m.activities, id)
+		}
+	}
+	delete(m.users, u.userID)
+	return nil
+}
+
+func (m *memoryRepository) UserExists(ctx context.Context, u *user) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.users[u.userID]
+	return ok, nil
+}
+
+func (m *memoryRepository) GetUserByEmail(ctx context.Context, email string) (*user, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, stored := range m.users {
+		if stored.email == email {
+			u := stored
+			return &u, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkUserEmailUnique mirrors the users.email unique constraint.
+// the caller must hold m.mu
+func (m *memoryRepository) checkUserEmailUnique(u *user) error {
+	for _, stored := range m.users {
+		if stored.userID != u.userID && stored.email == u.email {
+			return newPersistenceError(errUniqueViolation, "user with email %s already exists", u.email)
+		}
+	}
+	return nil
+}
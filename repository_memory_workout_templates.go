@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"sort"
+)
+
+func (m *memoryRepository) SaveWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workoutTemplates[t.templateID]; ok {
+		return newPersistenceError(errUniqueViolation, "workout template %s already exists", t.templateID)
+	}
+	if err := m.checkWorkoutTemplateReferences(t); err != nil {
+		return err
+	}
+	m.workoutTemplates[t.templateID] = *t
+	return nil
+}
+
+func (m *memoryRepository) GetWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.findWorkoutTemplate(t)
+	if !ok {
+		return newPersistenceError(errNotFound, "workout template %s not found", t.templateID)
+	}
+	*t = stored
+	return nil
+}
+
+func (m *memoryRepository) UpdateWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findWorkoutTemplate(t); !ok {
+		return newPersistenceError(errNotFound, "workout template %s not found", t.templateID)
+	}
+	if err := m.checkWorkoutTemplateReferences(t); err != nil {
+		return err
+	}
+	m.workoutTemplates[t.templateID] = *t
+	return nil
+}
+
+func (m *memoryRepository) DeleteWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.findWorkoutTemplate(t); !ok {
+		return newPersistenceError(errNotFound, "workout template %s not found", t.templateID)
+	}
+	// mirror the program_sessions.template_id foreign key
+	for _, p := range m.programs {
+		for _, s := range p.sessions {
+			if s.templateID == t.templateID {
+				return newPersistenceError(errConflict, "workout template %s is still referenced by program %s", t.templateID, p.programID)
+			}
+		}
+	}
+	delete(m.workoutTemplates, t.templateID)
+	return nil
+}
+
+func (m *memoryRepository) WorkoutTemplateExists(ctx context.Context, t *workoutTemplate) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.findWorkoutTemplate(t)
+	return ok, nil
+}
+
+func (m *memoryRepository) GetAllWorkoutTemplates(ctx context.Context, userID string) ([]*workoutTemplate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var templates []*workoutTemplate
+	for _, stored := range m.workoutTemplates {
+		if stored.userID != userID {
+			continue
+		}
+		t := stored
+		templates = append(templates, &t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].name != templates[j].name {
+			return templates[i].name < templates[j].name
+		}
+		return templates[i].templateID < templates[j].templateID
+	})
+	return templates, nil
+}
+
+// findWorkoutTemplate returns the stored template, if it belongs to the
+// user of t. the caller must hold m.mu
+func (m *memoryRepository) findWorkoutTemplate(t *workoutTemplate) (workoutTemplate, bool) {
+	stored, ok := m.workoutTemplates[t.templateID]
+	if !ok || stored.userID != t.userID {
+		return workoutTemplate{}, false
+	}
+	return stored, true
+}
+
+// checkWorkoutTemplateReferences mirrors the foreign keys on the
+// workout_templates table. the caller must hold m.mu
+func (m *memoryRepository) checkWorkoutTemplateReferences(t *workoutTemplate) error {
+	if _, ok := m.users[t.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by workout template %s does not exist", t.userID, t.templateID)
+	}
+	if _, ok := m.activities[t.activityID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "activity %s referenced by workout template %s does not exist", t.activityID, t.templateID)
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (m *memoryRepository) SaveWorkout(ctx context.Context, w *workout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.workouts[w.workoutID]; ok {
+		return newPersistenceError(errUniqueViolation, "workout %s already exists", w.workoutID)
+	}
+	if err := m.checkWorkoutReferences(w); err != nil {
+		return err
+	}
+	w.version = 1
+	m.workouts[w.workoutID] = *w
+	return nil
+}
+
+func (m *memoryRepository) SaveWorkouts(ctx context.Context, workouts []*workout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// check everything up front so that nothing is saved on error
+	seen := make(map[string]bool)
+	for _, w := range workouts {
+		if _, ok := m.workouts[w.workoutID]; ok || seen[w.workoutID] {
+			return newPersistenceError(errUniqueViolation, "workout %s already exists", w.workoutID)
+		}
+		seen[w.workoutID] = true
+		if err := m.checkWorkoutReferences(w); err != nil {
+			return err
+		}
+	}
+	for _, w := range workouts {
+		w.version = 1
+		m.workouts[w.workoutID] = *w
+	}
+	return nil
+}
+
+func (m *memoryRepository) GetWorkout(ctx context.Context, w *workout) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.findWorkout(w)
+	if !ok {
+		return newPersistenceError(errNotFound, "workout %s not found", w.workoutID)
+	}
+	*w = stored
+	return nil
+}
+
+func (m *memoryRepository) UpdateWorkout(ctx context.Context, w *workout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findWorkout(w)
+	if !ok {
+		return newPersistenceError(errNotFound, "workout %s not found", w.workoutID)
+	}
+	if w.version != 0 && w.version != stored.version {
+		return errVersionMismatch
+	}
+	if err := m.checkWorkoutReferences(w); err != nil {
+		return err
+	}
+	w.version = stored.version + 1
+	m.workouts[w.workoutID] = *w
+	return nil
+}
+
+func (m *memoryRepository) PatchWorkout(ctx context.Context, w *workout, fields []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findWorkout(w)
+	if !ok {
+		return newPersistenceError(errNotFound, "workout %s not found", w.workoutID)
+	}
+	if w.version != 0 && w.version != stored.version {
+		return errVersionMismatch
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	for _, field := range fields {
+		switch field {
+		case "activity_id":
+			stored.activityID = w.activityID
+		case "timestamp":
+			stored.timestamp = w.timestamp
+		case "calories_burned":
+			stored.caloriesBurned = w.caloriesBurned
+		case "calories_estimated":
+			stored.caloriesEstimated = w.caloriesEstimated
+		case "duration":
+			stored.duration = w.duration
+		case "distance_meters":
+			stored.distanceMeters = w.distanceMeters
+		case "avg_heart_rate":
+			stored.avgHeartRate = w.avgHeartRate
+		case "max_heart_rate":
+			stored.maxHeartRate = w.maxHeartRate
+		case "elevation_gain":
+			stored.elevationGain = w.elevationGain
+		default:
+			return fmt.Errorf("column %s of workouts cannot be patched", field)
+		}
+	}
+	if err := m.checkWorkoutReferences(&stored); err != nil {
+		return err
+	}
+	stored.version++
+	w.version = stored.version
+	m.workouts[w.workoutID] = stored
+	return nil
+}
+
+func (m *memoryRepository) DeleteWorkout(ctx context.Context, w *workout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.findWorkout(w)
+	if !ok {
+		return newPersistenceError(errNotFound, "workout %s not found", w.workoutID)
+	}
+	if w.version != 0 && w.version != stored.version {
+		return errVersionMismatch
+	}
+	// mirror the ON DELETE CASCADE of the workout's track and exercises
+	delete(m.trackpoints, w.workoutID)
+	for id, e := range m.exercises {
+		if e.workoutID == w.workoutID {
+			delete(m.exercises, id)
+		}
+	}
+	// and the ON DELETE SET NULL of the sessions it completed
+	for _, p := range m.programs {
+		for i, s := range p.sessions {
+			if s.workoutID != nil && *s.workoutID == w.workoutID {
+				p.sessions[i].workoutID = nil
+			}
+		}
+	}
+	// and the ON DELETE CASCADE of the achievements it earned
+	var achievements []achievement
+	for _, a := range m.achievements[stored.userID] {
+		if a.workoutID != w.workoutID {
+			achievements = append(achievements, a)
+		}
+	}
+	m.achievements[stored.userID] = achievements
+	delete(m.workouts, w.workoutID)
+	return nil
+}
+
+func (m *memoryRepository) WorkoutExists(ctx context.Context, w *workout) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.findWorkout(w)
+	return ok, nil
+}
+
+func (m *memoryRepository) QueryWorkouts(ctx context.Context, query *workoutQuery) ([]*workout, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var workouts []*workout
+	for _, stored := range m.workouts {
+		if stored.userID != query.userID || !workoutMatchesQuery(&stored, query) {
+			continue
+		}
+		w := stored
+		workouts = append(workouts, &w)
+	}
+
+	sort.Slice(workouts, func(i, j int) bool {
+		c := compareWorkouts(workouts[i], workouts[j], query.sort)
+		if query.descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	if query.limit > 0 && len(workouts) > query.limit {
+		workouts = workouts[:query.limit]
+	}
+	return workouts, nil
+}
+
+func workoutMatchesQuery(w *workout, query *workoutQuery) bool {
+	if query.activityID != nil && w.activityID != *query.activityID {
+		return false
+	}
+	if query.from != nil && w.timestamp.Before(*query.from) {
+		return false
+	}
+	if query.to != nil && !w.timestamp.Before(*query.to) {
+		return false
+	}
+	if query.minDuration != nil && w.duration < *query.minDuration {
+		return false
+	}
+	if query.maxDuration != nil && w.duration > *query.maxDuration {
+		return false
+	}
+	// like in SQL, a workout without the measurement never matches a filter on it
+	if query.minDistance != nil && (w.distanceMeters == nil || *w.distanceMeters < *query.minDistance) {
+		return false
+	}
+	if query.maxDistance != nil && (w.distanceMeters == nil || *w.distanceMeters > *query.maxDistance) {
+		return false
+	}
+	if query.minAvgHeartRate != nil && (w.avgHeartRate == nil || *w.avgHeartRate < *query.minAvgHeartRate) {
+		return false
+	}
+	if query.maxAvgHeartRate != nil && (w.avgHeartRate == nil || *w.avgHeartRate > *query.maxAvgHeartRate) {
+		return false
+	}
+	if query.after != nil {
+		after := &workout{
+			workoutID:      query.after.WorkoutID,
+			timestamp:      query.after.Timestamp,
+			caloriesBurned: query.after.CaloriesBurned,
+			duration:       time.Duration(query.after.Duration),
+		}
+		c := compareWorkouts(w, after, query.sort)
+		if query.descending && c >= 0 || !query.descending && c <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareWorkouts orders workouts by the sort column, then by id,
+// the same way the postgres repository does
+func compareWorkouts(a *workout, b *workout, sort string) int {
+	var less, greater bool
+	switch sort {
+	case workoutSortCaloriesBurned:
+		less, greater = a.caloriesBurned < b.caloriesBurned, a.caloriesBurned > b.caloriesBurned
+	case workoutSortDuration:
+		less, greater = a.duration < b.duration, a.duration > b.duration
+	default:
+		less, greater = a.timestamp.Before(b.timestamp), a.timestamp.After(b.timestamp)
+	}
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return strings.Compare(a.workoutID, b.workoutID)
+	}
+}
+
+// findWorkout looks up the workout with w's id, scoped to w's owner.
+// the caller must hold m.mu
+func (m *memoryRepository) findWorkout(w *workout) (workout, bool) {
+	stored, ok := m.workouts[w.workoutID]
+	if !ok || stored.userID != w.userID {
+		return workout{}, false
+	}
+	return stored, true
+}
+
+// checkWorkoutReferences mirrors the foreign keys on the workouts table.
+// the caller must hold m.mu
+func (m *memoryRepository) checkWorkoutReferences(w *workout) error {
+	if _, ok := m.users[w.userID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "user %s referenced by workout %s does not exist", w.userID, w.workoutID)
+	}
+	if _, ok := m.activities[w.activityID]; !ok {
+		return newPersistenceError(errForeignKeyViolation, "activity %s referenced by workout %s does not exist", w.activityID, w.workoutID)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryRepositoryConditionalWorkoutWrites(t *testing.T) {
+	tests := []struct {
+		name     string
+		missing  bool
+		version  int64
+		expected error
+	}{
+		{"unconditional write of a stored workout", false, 0, nil},
+		{"write at the stored version", false, 1, nil},
+		{"write at a stale version", false, 2, errVersionMismatch},
+		{"unconditional write of a missing workout", true, 0, errNotFound},
+		{"write at a version of a missing workout", true, 1, errNotFound},
+	}
+	writes := []struct {
+		name  string
+		write func(context.Context, *memoryRepository, *workout) error
+	}{
+		{"update", func(ctx context.Context, m *memoryRepository, w *workout) error { return m.UpdateWorkout(ctx, w) }},
+		{"patch", func(ctx context.Context, m *memoryRepository, w *workout) error {
+			return m.PatchWorkout(ctx, w, []string{"duration"})
+		}},
+		{"delete", func(ctx context.Context, m *memoryRepository, w *workout) error { return m.DeleteWorkout(ctx, w) }},
+	}
+	for _, write := range writes {
+		for _, test := range tests {
+			t.Run(write.name+" "+test.name, func(t *testing.T) {
+				ctx := context.Background()
+				m := newMemoryRepository()
+				if err := m.SaveUser(ctx, &user{userID: "u", email: "ada@example.com"}); err != nil {
+					t.Fatalf("cannot save user: %v", err)
+				}
+				if err := m.SaveActivity(ctx, &activity{activityID: "a", userID: "u", name: "Running"}); err != nil {
+					t.Fatalf("cannot save activity: %v", err)
+				}
+				w := &workout{workoutID: "w", userID: "u", activityID: "a", timestamp: time.Now(), duration: time.Hour}
+				if err := m.SaveWorkout(ctx, w); err != nil {
+					t.Fatalf("cannot save workout: %v", err)
+				}
+				if test.missing {
+					if err := m.DeleteWorkout(ctx, &workout{workoutID: "w", userID: "u"}); err != nil {
+						t.Fatalf("cannot delete workout: %v", err)
+					}
+				}
+
+				w.version = test.version
+				err := write.write(ctx, m, w)
+				if test.expected == nil && err != nil || test.expected != nil && !errors.Is(err, test.expected) {
+					t.Fatalf("expected %v, got %v", test.expected, err)
+				}
+			})
+		}
+	}
+}
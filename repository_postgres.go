@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type postgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func newPostgresRepository(db *pgxpool.Pool) *postgresRepository {
+	return &postgresRepository{
+		db: db,
+	}
+}
+
+func (p *postgresRepository) Ping(ctx context.Context) error {
+	return p.db.Ping(ctx)
+}
+
+func (p *postgresRepository) Close() {
+	p.db.Close()
+}
+
+// patch updates only the given fields of a user's row in table. columns
+// holds every patchable column and its new value, so that fields can
+// never name a column the caller did not intend to be written. version
+// is checked like in the other conditional writes and set to the new one
+func (p *postgresRepository) patch(ctx context.Context, table string, idColumn string, id string, userID string, version *int64, columns map[string]interface{}, fields []string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	args := []interface{}{id, userID, *version}
+	var assignments []string
+	for _, field := range fields {
+		value, ok := columns[field]
+		if !ok {
+			return fmt.Errorf("column %s of %s cannot be patched", field, table)
+		}
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", field, len(args)))
+	}
+	assignments = append(assignments, "version = version + 1")
+
+	err := p.db.QueryRow(ctx, fmt.Sprintf(`
+		UPDATE %s SET %s
+		WHERE %s = $1
+			AND user_id = $2
+			AND ($3::bigint = 0 OR version = $3)
+		RETURNING version`, table, strings.Join(assignments, ", "), idColumn),
+		args...,
+	).Scan(version)
+	return p.conditionalWriteError(ctx, err, *version, table, idColumn, id, userID)
+}
+
+// conditionalWriteError interprets the error of a write of a user's row of
+// table guarded by the expected version, such as an UPDATE ... RETURNING.
+// when the write matched no row and a version was expected, the row is
+// looked up to tell whether it was changed in the meantime or no longer
+// exists. otherwise it does not exist, and a not found error is returned
+func (p *postgresRepository) conditionalWriteError(ctx context.Context, err error, expectedVersion int64, table string, idColumn string, id string, userID string) error {
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if expectedVersion != 0 {
+		var exists bool
+		err = p.db.QueryRow(ctx, fmt.Sprintf(`
+			SELECT EXISTS (
+				SELECT 1
+				FROM %s
+				WHERE %s = $1
+					AND user_id = $2
+			)`, table, idColumn), id, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errVersionMismatch
+		}
+	}
+	return newPersistenceError(errNotFound, "row %s of %s not found", id, table)
+}
+
+// singleRowWriteError interprets the result of a write of a single row:
+// when it matched none, the row does not exist, and a not found error is
+// returned with the given message, as the memory repository does
+func singleRowWriteError(tag pgconn.CommandTag, err error, format string, args ...interface{}) error {
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return newPersistenceError(errNotFound, format, args...)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// UpdateAchievements evaluates the achievements in a transaction holding a
+// lock on the user, so that concurrent updates take turns reading the
+// workouts and replacing the achievements
+func (p *postgresRepository) UpdateAchievements(ctx context.Context, userID string, evaluate func([]*workout) []*achievement) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			SELECT user_id
+			FROM users
+			WHERE user_id = $1
+			FOR UPDATE`, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT
+				workout_id,
+				user_id,
+				activity_id,
+				timestamp,
+				calories_burned,
+				duration
+			FROM workouts
+			WHERE user_id = $1
+			ORDER BY timestamp, workout_id`, userID)
+		if err != nil {
+			return err
+		}
+		var workouts []*workout
+		for rows.Next() {
+			w := &workout{}
+			err = rows.Scan(
+				&w.workoutID,
+				&w.userID,
+				&w.activityID,
+				&w.timestamp,
+				&w.caloriesBurned,
+				&w.duration,
+			)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			workouts = append(workouts, w)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		achievements := evaluate(workouts)
+
+		_, err = tx.Exec(ctx, `
+			DELETE FROM achievements
+			WHERE user_id = $1`, userID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CopyFrom(ctx,
+			pgx.Identifier{"achievements"},
+			[]string{
+				"user_id",
+				"code",
+				"activity_id",
+				"workout_id",
+				"value",
+				"earned_at",
+			},
+			pgx.CopyFromSlice(len(achievements), func(i int) ([]interface{}, error) {
+				a := achievements[i]
+				return []interface{}{
+					a.userID,
+					a.code,
+					a.activityID,
+					a.workoutID,
+					a.value,
+					a.earnedAt,
+				}, nil
+			}),
+		)
+		return err
+	})
+}
+
+func (p *postgresRepository) GetAchievements(ctx context.Context, userID string) ([]*achievement, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			user_id,
+			code,
+			activity_id,
+			workout_id,
+			value,
+			earned_at
+		FROM achievements
+		WHERE user_id = $1
+		ORDER BY earned_at, code`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var achievements []*achievement
+	for rows.Next() {
+		a := &achievement{}
+		err = rows.Scan(
+			&a.userID,
+			&a.code,
+			&a.activityID,
+			&a.workoutID,
+			&a.value,
+			&a.earnedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		achievements = append(achievements, a)
+	}
+	return achievements, rows.Err()
+}
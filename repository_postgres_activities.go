@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func (p *postgresRepository) SaveActivity(ctx context.Context, a *activity) error {
+	tag, err := p.db.Exec(ctx, `
+		INSERT INTO activities (
+			activity_id,
+			user_id,
+			name,
+			met
+		) VALUES ($1,$2,$3,$4)`,
+		a.activityID,
+		a.userID,
+		a.name,
+		a.met,
+	)
+	err = singleRowWriteError(tag, err, "activity %s not found", a.activityID)
+	if err != nil {
+		return err
+	}
+	a.version = 1
+	return nil
+}
+
+func (p *postgresRepository) GetActivity(ctx context.Context, a *activity) error {
+	return p.db.QueryRow(ctx, `
+		SELECT 
+			activity_id,
+			user_id,
+			name,
+			met,
+			version
+		FROM activities
+		WHERE activity_id = $1
+			AND user_id = $2`, a.activityID, a.userID).Scan(
+		&a.activityID,
+		&a.userID,
+		&a.name,
+		&a.met,
+		&a.version,
+	)
+}
+
+func (p *postgresRepository) UpdateActivity(ctx context.Context, a *activity) error {
+	err := p.db.QueryRow(ctx, `
+		UPDATE activities SET (
+			activity_id,
+			name,
+			met,
+			version
+		) = ($1,$3,$4,version + 1)
+		WHERE activity_id = $1
+			AND user_id = $2
+			AND ($5::bigint = 0 OR version = $5)
+		RETURNING version`,
+		a.activityID,
+		a.userID,
+		a.name,
+		a.met,
+		a.version,
+	).Scan(&a.version)
+	return p.conditionalWriteError(ctx, err, a.version, "activities", "activity_id", a.activityID, a.userID)
+}
+
+func (p *postgresRepository) PatchActivity(ctx context.Context, a *activity, fields []string) error {
+	columns := map[string]interface{}{
+		"name": a.name,
+		"met":  a.met,
+	}
+	return p.patch(ctx, "activities", "activity_id", a.activityID, a.userID, &a.version, columns, fields)
+}
+
+func (p *postgresRepository) DeleteActivity(ctx context.Context, a *activity) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM activities
+		WHERE activity_id = $1
+			AND user_id = $2
+			AND ($3::bigint = 0 OR version = $3)`,
+		a.activityID,
+		a.userID,
+		a.version,
+	)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+	}
+	return p.conditionalWriteError(ctx, err, a.version, "activities", "activity_id", a.activityID, a.userID)
+}
+
+func (p *postgresRepository) ActivityExists(ctx context.Context, a *activity) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM activities
+		WHERE activity_id = $1
+			AND user_id = $2`, a.activityID, a.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) GetAllActivities(ctx context.Context, userID string) ([]*activity, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT 
+			activity_id,
+			user_id,
+			name,
+			met,
+			version
+		FROM activities
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []*activity
+	for rows.Next() {
+		a := &activity{}
+		err = rows.Scan(
+			&a.activityID,
+			&a.userID,
+			&a.name,
+			&a.met,
+			&a.version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, a)
+	}
+	return activities, rows.Err()
+}
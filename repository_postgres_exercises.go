@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveExercise inserts the exercise at its position, shifting the
+// exercises after it, along with its sets in a single transaction
+func (p *postgresRepository) SaveExercise(ctx context.Context, e *exercise) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := lockWorkoutExercises(ctx, tx, e.workoutID)
+		if err != nil {
+			return err
+		}
+
+		var count int
+		err = tx.QueryRow(ctx, `
+			SELECT count(*)
+			FROM exercises
+			WHERE workout_id = $1`, e.workoutID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if e.position < 0 || e.position > count {
+			e.position = count
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE exercises SET position = position + 1
+			WHERE workout_id = $1
+				AND position >= $2`, e.workoutID, e.position)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO exercises (
+				exercise_id,
+				workout_id,
+				user_id,
+				activity_id,
+				position,
+				notes
+			) VALUES ($1,$2,$3,$4,$5,$6)`,
+			e.exerciseID,
+			e.workoutID,
+			e.userID,
+			e.activityID,
+			e.position,
+			e.notes,
+		)
+		if err != nil {
+			return err
+		}
+
+		return copyExerciseSets(ctx, tx, e)
+	})
+}
+
+func (p *postgresRepository) GetExercise(ctx context.Context, e *exercise) error {
+	err := p.db.QueryRow(ctx, `
+		SELECT
+			exercise_id,
+			workout_id,
+			user_id,
+			activity_id,
+			position,
+			notes
+		FROM exercises
+		WHERE exercise_id = $1
+			AND workout_id = $2
+			AND user_id = $3`, e.exerciseID, e.workoutID, e.userID).Scan(
+		&e.exerciseID,
+		&e.workoutID,
+		&e.userID,
+		&e.activityID,
+		&e.position,
+		&e.notes,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			exercise_id,
+			reps,
+			weight,
+			weight_unit,
+			rpe,
+			rest
+		FROM exercise_sets
+		WHERE exercise_id = $1
+		ORDER BY position`, e.exerciseID)
+	if err != nil {
+		return err
+	}
+	e.sets = nil
+	return scanExerciseSets(rows, map[string]*exercise{e.exerciseID: e})
+}
+
+// UpdateExercise replaces the exercise and its sets, moving it to its new
+// position within the workout in a single transaction
+func (p *postgresRepository) UpdateExercise(ctx context.Context, e *exercise) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := lockWorkoutExercises(ctx, tx, e.workoutID)
+		if err != nil {
+			return err
+		}
+
+		var current, count int
+		err = tx.QueryRow(ctx, `
+			SELECT
+				position,
+				(SELECT count(*) FROM exercises WHERE workout_id = $2)
+			FROM exercises
+			WHERE exercise_id = $1
+				AND workout_id = $2
+				AND user_id = $3`, e.exerciseID, e.workoutID, e.userID).Scan(&current, &count)
+		if err != nil {
+			return err
+		}
+		if e.position < 0 || e.position >= count {
+			e.position = current
+		}
+
+		switch {
+		case e.position < current:
+			_, err = tx.Exec(ctx, `
+				UPDATE exercises SET position = position + 1
+				WHERE workout_id = $1
+					AND position >= $2
+					AND position < $3`, e.workoutID, e.position, current)
+		case e.position > current:
+			_, err = tx.Exec(ctx, `
+				UPDATE exercises SET position = position - 1
+				WHERE workout_id = $1
+					AND position > $2
+					AND position <= $3`, e.workoutID, current, e.position)
+		}
+		if err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE exercises SET (
+				activity_id,
+				position,
+				notes
+			) = ($2,$3,$4)
+			WHERE exercise_id = $1`,
+			e.exerciseID,
+			e.activityID,
+			e.position,
+			e.notes,
+		)
+		err = singleRowWriteError(tag, err, "exercise %s not found", e.exerciseID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			DELETE FROM exercise_sets
+			WHERE exercise_id = $1`, e.exerciseID)
+		if err != nil {
+			return err
+		}
+		return copyExerciseSets(ctx, tx, e)
+	})
+}
+
+// DeleteExercise deletes the exercise, closing the gap it leaves in the
+// positions of the workout's exercises
+func (p *postgresRepository) DeleteExercise(ctx context.Context, e *exercise) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := lockWorkoutExercises(ctx, tx, e.workoutID)
+		if err != nil {
+			return err
+		}
+
+		var position int
+		err = tx.QueryRow(ctx, `
+			DELETE FROM exercises
+			WHERE exercise_id = $1
+				AND workout_id = $2
+				AND user_id = $3
+			RETURNING position`, e.exerciseID, e.workoutID, e.userID).Scan(&position)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newPersistenceError(errNotFound, "exercise %s not found", e.exerciseID)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE exercises SET position = position - 1
+			WHERE workout_id = $1
+				AND position > $2`, e.workoutID, position)
+		return err
+	})
+}
+
+func (p *postgresRepository) ExerciseExists(ctx context.Context, e *exercise) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM exercises
+		WHERE exercise_id = $1
+			AND workout_id = $2
+			AND user_id = $3`, e.exerciseID, e.workoutID, e.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) GetExercises(ctx context.Context, w *workout) ([]*exercise, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			exercise_id,
+			workout_id,
+			user_id,
+			activity_id,
+			position,
+			notes
+		FROM exercises
+		WHERE workout_id = $1
+			AND user_id = $2
+		ORDER BY position`, w.workoutID, w.userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exercises []*exercise
+	exercisesByID := make(map[string]*exercise)
+	for rows.Next() {
+		e := &exercise{}
+		err = rows.Scan(
+			&e.exerciseID,
+			&e.workoutID,
+			&e.userID,
+			&e.activityID,
+			&e.position,
+			&e.notes,
+		)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, e)
+		exercisesByID[e.exerciseID] = e
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	setRows, err := p.db.Query(ctx, `
+		SELECT
+			s.exercise_id,
+			s.reps,
+			s.weight,
+			s.weight_unit,
+			s.rpe,
+			s.rest
+		FROM exercise_sets s
+		JOIN exercises e ON e.exercise_id = s.exercise_id
+		WHERE e.workout_id = $1
+			AND e.user_id = $2
+		ORDER BY s.exercise_id, s.position`, w.workoutID, w.userID)
+	if err != nil {
+		return nil, err
+	}
+	err = scanExerciseSets(setRows, exercisesByID)
+	if err != nil {
+		return nil, err
+	}
+	return exercises, nil
+}
+
+// scanExerciseSets appends the sets in rows to the exercises they belong to,
+// and closes rows
+func scanExerciseSets(rows pgx.Rows, exercisesByID map[string]*exercise) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var exerciseID string
+		var s exerciseSet
+		err := rows.Scan(
+			&exerciseID,
+			&s.reps,
+			&s.weight,
+			&s.weightUnit,
+			&s.rpe,
+			&s.rest,
+		)
+		if err != nil {
+			return err
+		}
+		if e, ok := exercisesByID[exerciseID]; ok {
+			e.sets = append(e.sets, s)
+		}
+	}
+	return rows.Err()
+}
+
+// lockWorkoutExercises serializes the transactions moving around the
+// positions of a workout's exercises by locking the workout's row
+func lockWorkoutExercises(ctx context.Context, tx pgx.Tx, workoutID string) error {
+	_, err := tx.Exec(ctx, `
+		SELECT 1
+		FROM workouts
+		WHERE workout_id = $1
+		FOR UPDATE`, workoutID)
+	return err
+}
+
+func copyExerciseSets(ctx context.Context, tx pgx.Tx, e *exercise) error {
+	_, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"exercise_sets"},
+		[]string{
+			"exercise_id",
+			"position",
+			"reps",
+			"weight",
+			"weight_unit",
+			"rpe",
+			"rest",
+		},
+		pgx.CopyFromSlice(len(e.sets), func(i int) ([]interface{}, error) {
+			s := e.sets[i]
+			return []interface{}{
+				e.exerciseID,
+				i,
+				s.reps,
+				s.weight,
+				s.weightUnit,
+				s.rpe,
+				s.rest,
+			}, nil
+		}),
+	)
+	return err
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func (p *postgresRepository) SaveGoal(ctx context.Context, g *goal) error {
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO goals (
+			goal_id,
+			user_id,
+			activity_id,
+			metric,
+			target,
+			period,
+			time_zone,
+			started_at
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
+		g.goalID,
+		g.userID,
+		g.activityID,
+		g.metric,
+		g.target,
+		g.period,
+		g.location.String(),
+		g.startedAt,
+	)
+	return err
+}
+
+func (p *postgresRepository) GetGoal(ctx context.Context, g *goal) error {
+	var timeZone string
+	err := p.db.QueryRow(ctx, `
+		SELECT
+			goal_id,
+			user_id,
+			activity_id,
+			metric,
+			target,
+			period,
+			time_zone,
+			started_at
+		FROM goals
+		WHERE goal_id = $1
+			AND user_id = $2`, g.goalID, g.userID).Scan(
+		&g.goalID,
+		&g.userID,
+		&g.activityID,
+		&g.metric,
+		&g.target,
+		&g.period,
+		&timeZone,
+		&g.startedAt,
+	)
+	if err != nil {
+		return err
+	}
+	g.location, err = time.LoadLocation(timeZone)
+	return err
+}
+
+// UpdateGoal updates the goal and drops the history from before it started
+// in a single transaction
+func (p *postgresRepository) UpdateGoal(ctx context.Context, g *goal) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE goals SET (
+				activity_id,
+				metric,
+				target,
+				period,
+				time_zone,
+				started_at
+			) = ($3,$4,$5,$6,$7,$8)
+			WHERE goal_id = $1
+				AND user_id = $2`,
+			g.goalID,
+			g.userID,
+			g.activityID,
+			g.metric,
+			g.target,
+			g.period,
+			g.location.String(),
+			g.startedAt,
+		)
+		err = singleRowWriteError(tag, err, "goal %s not found", g.goalID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			DELETE FROM goal_periods
+			WHERE goal_id = $1
+				AND period_start < $2`, g.goalID, g.startedAt)
+		return err
+	})
+}
+
+func (p *postgresRepository) DeleteGoal(ctx context.Context, g *goal) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM goals
+		WHERE goal_id = $1
+			AND user_id = $2`, g.goalID, g.userID)
+	return singleRowWriteError(tag, err, "goal %s not found", g.goalID)
+}
+
+func (p *postgresRepository) GoalExists(ctx context.Context, g *goal) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM goals
+		WHERE goal_id = $1
+			AND user_id = $2`, g.goalID, g.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) GetAllGoals(ctx context.Context, userID string) ([]*goal, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			goal_id,
+			user_id,
+			activity_id,
+			metric,
+			target,
+			period,
+			time_zone,
+			started_at
+		FROM goals
+		WHERE user_id = $1
+		ORDER BY started_at, goal_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var goals []*goal
+	for rows.Next() {
+		g := &goal{}
+		var timeZone string
+		err = rows.Scan(
+			&g.goalID,
+			&g.userID,
+			&g.activityID,
+			&g.metric,
+			&g.target,
+			&g.period,
+			&timeZone,
+			&g.startedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		g.location, err = time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+// RecordGoalPeriods inserts the periods in a single batch. periods recorded
+// concurrently by another request are left as they are
+func (p *postgresRepository) RecordGoalPeriods(ctx context.Context, g *goal, periods []*goalPeriod) error {
+	batch := &pgx.Batch{}
+	for _, period := range periods {
+		batch.Queue(`
+			INSERT INTO goal_periods (
+				goal_id,
+				period_start,
+				period_end,
+				target,
+				value
+			) VALUES ($1,$2,$3,$4,$5)
+			ON CONFLICT (goal_id, period_start) DO NOTHING`,
+			g.goalID,
+			period.periodStart,
+			period.periodEnd,
+			period.target,
+			period.value,
+		)
+	}
+
+	results := p.db.SendBatch(ctx, batch)
+	defer results.Close()
+	for range periods {
+		if _, err := results.Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *postgresRepository) GetGoalPeriods(ctx context.Context, g *goal) ([]*goalPeriod, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			period_start,
+			period_end,
+			target,
+			value
+		FROM goal_periods
+		WHERE goal_id = $1
+		ORDER BY period_start DESC`, g.goalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var periods []*goalPeriod
+	for rows.Next() {
+		period := &goalPeriod{}
+		err = rows.Scan(
+			&period.periodStart,
+			&period.periodEnd,
+			&period.target,
+			&period.value,
+		)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	return periods, rows.Err()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func (p *postgresRepository) SaveMeasurement(ctx context.Context, m *measurement) error {
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO measurements (
+			measurement_id,
+			user_id,
+			kind,
+			value,
+			measured_at
+		) VALUES ($1,$2,$3,$4,$5)`,
+		m.measurementID,
+		m.userID,
+		m.kind,
+		m.value,
+		m.measuredAt,
+	)
+	return err
+}
+
+func (p *postgresRepository) GetMeasurement(ctx context.Context, m *measurement) error {
+	return p.db.QueryRow(ctx, `
+		SELECT
+			measurement_id,
+			user_id,
+			kind,
+			value,
+			measured_at
+		FROM measurements
+		WHERE measurement_id = $1
+			AND user_id = $2`, m.measurementID, m.userID).Scan(
+		&m.measurementID,
+		&m.userID,
+		&m.kind,
+		&m.value,
+		&m.measuredAt,
+	)
+}
+
+func (p *postgresRepository) UpdateMeasurement(ctx context.Context, m *measurement) error {
+	tag, err := p.db.Exec(ctx, `
+		UPDATE measurements SET (
+			kind,
+			value,
+			measured_at
+		) = ($3,$4,$5)
+		WHERE measurement_id = $1
+			AND user_id = $2`,
+		m.measurementID,
+		m.userID,
+		m.kind,
+		m.value,
+		m.measuredAt,
+	)
+	return singleRowWriteError(tag, err, "measurement %s not found", m.measurementID)
+}
+
+func (p *postgresRepository) DeleteMeasurement(ctx context.Context, m *measurement) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM measurements
+		WHERE measurement_id = $1
+			AND user_id = $2`, m.measurementID, m.userID)
+	return singleRowWriteError(tag, err, "measurement %s not found", m.measurementID)
+}
+
+func (p *postgresRepository) MeasurementExists(ctx context.Context, m *measurement) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM measurements
+		WHERE measurement_id = $1
+			AND user_id = $2`, m.measurementID, m.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) QueryMeasurements(ctx context.Context, query *measurementQuery) ([]*measurement, error) {
+	args := []interface{}{query.userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var sql strings.Builder
+	sql.WriteString(`
+		SELECT
+			measurement_id,
+			user_id,
+			kind,
+			value,
+			measured_at
+		FROM measurements
+		WHERE user_id = $1`)
+	if query.kind != nil {
+		sql.WriteString(" AND kind = " + arg(*query.kind))
+	}
+	if query.from != nil {
+		sql.WriteString(" AND measured_at >= " + arg(*query.from))
+	}
+	if query.to != nil {
+		sql.WriteString(" AND measured_at < " + arg(*query.to))
+	}
+	sql.WriteString(" ORDER BY measured_at, measurement_id")
+
+	rows, err := p.db.Query(ctx, sql.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var measurements []*measurement
+	for rows.Next() {
+		m := &measurement{}
+		err = rows.Scan(
+			&m.measurementID,
+			&m.userID,
+			&m.kind,
+			&m.value,
+			&m.measuredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, m)
+	}
+	return measurements, rows.Err()
+}
+
+func (p *postgresRepository) GetMeasurementAt(ctx context.Context, userID string, kind string, at time.Time) (*measurement, error) {
+	m := &measurement{}
+	// the last one taken by then first, then the earliest of the later ones
+	err := p.db.QueryRow(ctx, `
+		SELECT
+			measurement_id,
+			user_id,
+			kind,
+			value,
+			measured_at
+		FROM measurements
+		WHERE user_id = $1
+			AND kind = $2
+		ORDER BY
+			measured_at > $3,
+			CASE WHEN measured_at <= $3 THEN measured_at END DESC,
+			measured_at
+		LIMIT 1`, userID, kind, at).Scan(
+		&m.measurementID,
+		&m.userID,
+		&m.kind,
+		&m.value,
+		&m.measuredAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveProgram inserts the program along with its sessions in a single
+// transaction
+func (p *postgresRepository) SaveProgram(ctx context.Context, pr *program) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO programs (
+				program_id,
+				user_id,
+				name,
+				start_date,
+				time_zone
+			) VALUES ($1,$2,$3,$4,$5)`,
+			pr.programID,
+			pr.userID,
+			pr.name,
+			pr.startDate,
+			pr.location.String(),
+		)
+		if err != nil {
+			return err
+		}
+		return copyProgramSessions(ctx, tx, pr)
+	})
+}
+
+func (p *postgresRepository) GetProgram(ctx context.Context, pr *program) error {
+	var timeZone string
+	err := p.db.QueryRow(ctx, `
+		SELECT
+			program_id,
+			user_id,
+			name,
+			start_date,
+			time_zone
+		FROM programs
+		WHERE program_id = $1
+			AND user_id = $2`, pr.programID, pr.userID).Scan(
+		&pr.programID,
+		&pr.userID,
+		&pr.name,
+		&pr.startDate,
+		&timeZone,
+	)
+	if err != nil {
+		return err
+	}
+	pr.location, err = time.LoadLocation(timeZone)
+	if err != nil {
+		return err
+	}
+
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			program_id,
+			session_id,
+			template_id,
+			week,
+			day,
+			workout_id
+		FROM program_sessions
+		WHERE program_id = $1
+		ORDER BY week, day, session_id`, pr.programID)
+	if err != nil {
+		return err
+	}
+	pr.sessions = nil
+	return scanProgramSessions(rows, map[string]*program{pr.programID: pr})
+}
+
+// UpdateProgram replaces the program and its sessions in a single transaction
+func (p *postgresRepository) UpdateProgram(ctx context.Context, pr *program) error {
+	return p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			UPDATE programs SET (
+				name,
+				start_date,
+				time_zone
+			) = ($3,$4,$5)
+			WHERE program_id = $1
+				AND user_id = $2`,
+			pr.programID,
+			pr.userID,
+			pr.name,
+			pr.startDate,
+			pr.location.String(),
+		)
+		err = singleRowWriteError(tag, err, "program %s not found", pr.programID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			DELETE FROM program_sessions
+			WHERE program_id = $1`, pr.programID)
+		if err != nil {
+			return err
+		}
+		return copyProgramSessions(ctx, tx, pr)
+	})
+}
+
+func (p *postgresRepository) DeleteProgram(ctx context.Context, pr *program) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM programs
+		WHERE program_id = $1
+			AND user_id = $2`, pr.programID, pr.userID)
+	return singleRowWriteError(tag, err, "program %s not found", pr.programID)
+}
+
+func (p *postgresRepository) ProgramExists(ctx context.Context, pr *program) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM programs
+		WHERE program_id = $1
+			AND user_id = $2`, pr.programID, pr.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) GetAllPrograms(ctx context.Context, userID string) ([]*program, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			program_id,
+			user_id,
+			name,
+			start_date,
+			time_zone
+		FROM programs
+		WHERE user_id = $1
+		ORDER BY start_date, program_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var programs []*program
+	programsByID := make(map[string]*program)
+	for rows.Next() {
+		pr := &program{}
+		var timeZone string
+		err = rows.Scan(
+			&pr.programID,
+			&pr.userID,
+			&pr.name,
+			&pr.startDate,
+			&timeZone,
+		)
+		if err != nil {
+			return nil, err
+		}
+		pr.location, err = time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, pr)
+		programsByID[pr.programID] = pr
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessionRows, err := p.db.Query(ctx, `
+		SELECT
+			s.program_id,
+			s.session_id,
+			s.template_id,
+			s.week,
+			s.day,
+			s.workout_id
+		FROM program_sessions s
+		JOIN programs p ON p.program_id = s.program_id
+		WHERE p.user_id = $1
+		ORDER BY s.program_id, s.week, s.day, s.session_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = scanProgramSessions(sessionRows, programsByID)
+	if err != nil {
+		return nil, err
+	}
+	return programs, nil
+}
+
+func (p *postgresRepository) CompleteProgramSession(ctx context.Context, w *workout) (string, error) {
+	var sessionID string
+	err := p.db.QueryRow(ctx, `
+		UPDATE program_sessions SET workout_id = $1
+		WHERE session_id = (
+			SELECT s.session_id
+			FROM program_sessions s
+			JOIN programs p ON p.program_id = s.program_id
+			JOIN workout_templates t ON t.template_id = s.template_id
+			WHERE p.user_id = $2
+				AND t.activity_id = $3
+				AND s.workout_id IS NULL
+				AND p.start_date + (s.week - 1) * 7 + (s.day - 1) = ($4::timestamptz AT TIME ZONE p.time_zone)::date
+			ORDER BY p.start_date, s.week, s.day, s.session_id
+			LIMIT 1
+			FOR UPDATE OF s SKIP LOCKED
+		)
+		RETURNING session_id`,
+		w.workoutID,
+		w.userID,
+		w.activityID,
+		w.timestamp,
+	).Scan(&sessionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return sessionID, err
+}
+
+// scanProgramSessions appends the sessions in rows to the programs they
+// belong to, and closes rows
+func scanProgramSessions(rows pgx.Rows, programsByID map[string]*program) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var programID string
+		var s programSession
+		err := rows.Scan(
+			&programID,
+			&s.sessionID,
+			&s.templateID,
+			&s.week,
+			&s.day,
+			&s.workoutID,
+		)
+		if err != nil {
+			return err
+		}
+		if pr, ok := programsByID[programID]; ok {
+			pr.sessions = append(pr.sessions, s)
+		}
+	}
+	return rows.Err()
+}
+
+func copyProgramSessions(ctx context.Context, tx pgx.Tx, pr *program) error {
+	_, err := tx.CopyFrom(ctx,
+		pgx.Identifier{"program_sessions"},
+		[]string{
+			"session_id",
+			"program_id",
+			"template_id",
+			"week",
+			"day",
+			"workout_id",
+		},
+		pgx.CopyFromSlice(len(pr.sessions), func(i int) ([]interface{}, error) {
+			s := pr.sessions[i]
+			return []interface{}{
+				s.sessionID,
+				pr.programID,
+				s.templateID,
+				s.week,
+				s.day,
+				s.workoutID,
+			}, nil
+		}),
+	)
+	return err
+}
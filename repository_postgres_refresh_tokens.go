@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func (p *postgresRepository) SaveRefreshToken(ctx context.Context, t *refreshToken) error {
+	return p.db.QueryRow(ctx, `
+		INSERT INTO refresh_tokens (
+			token_hash,
+			user_id,
+			expires_at
+		) VALUES ($1,$2,$3)
+		RETURNING created_at`,
+		t.tokenHash,
+		t.userID,
+		t.expiresAt,
+	).Scan(&t.createdAt)
+}
+
+func (p *postgresRepository) GetRefreshToken(ctx context.Context, t *refreshToken) error {
+	return p.db.QueryRow(ctx, `
+		SELECT 
+			token_hash,
+			user_id,
+			created_at,
+			expires_at,
+			revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1`, t.tokenHash).Scan(
+		&t.tokenHash,
+		&t.userID,
+		&t.createdAt,
+		&t.expiresAt,
+		&t.revokedAt,
+	)
+}
+
+func (p *postgresRepository) UpdateRefreshToken(ctx context.Context, t *refreshToken) error {
+	tag, err := p.db.Exec(ctx, `
+		UPDATE refresh_tokens SET (
+			expires_at,
+			revoked_at
+		) = ($2,$3)
+		WHERE token_hash = $1`,
+		t.tokenHash,
+		t.expiresAt,
+		t.revokedAt,
+	)
+	return singleRowWriteError(tag, err, "refresh token not found")
+}
+
+func (p *postgresRepository) RevokeRefreshToken(ctx context.Context, t *refreshToken) (bool, error) {
+	err := p.db.QueryRow(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE token_hash = $1
+			AND revoked_at IS NULL
+			AND expires_at > now()
+		RETURNING
+			user_id,
+			created_at,
+			expires_at,
+			revoked_at`, t.tokenHash).Scan(
+		&t.userID,
+		&t.createdAt,
+		&t.expiresAt,
+		&t.revokedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *postgresRepository) DeleteRefreshToken(ctx context.Context, t *refreshToken) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM refresh_tokens
+		WHERE token_hash = $1`,
+		t.tokenHash,
+	)
+	return singleRowWriteError(tag, err, "refresh token not found")
+}
+
+func (p *postgresRepository) RefreshTokenExists(ctx context.Context, t *refreshToken) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM refresh_tokens
+		WHERE token_hash = $1`, t.tokenHash).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (p *postgresRepository) GetWorkoutStats(ctx context.Context, query *workoutStatsQuery) ([]*workoutStatsBucket, error) {
+	args := []interface{}{query.userID, query.groupBy, query.location.String()}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	groupColumns := "period"
+	activityColumn := "''"
+	if query.byActivity {
+		groupColumns = "period, activity_id"
+		activityColumn = "activity_id"
+	}
+
+	var sql strings.Builder
+	sql.WriteString(`
+		SELECT
+			date_trunc($2, timestamp AT TIME ZONE $3) AS period,
+			` + activityColumn + `,
+			count(*),
+			sum(calories_burned),
+			avg(calories_burned)::float8,
+			sum(EXTRACT(EPOCH FROM duration))::float8,
+			avg(EXTRACT(EPOCH FROM duration))::float8,
+			coalesce(sum(distance_meters), 0)::float8,
+			coalesce(sum(elevation_gain), 0)::float8,
+			count(avg_heart_rate),
+			avg(avg_heart_rate)::float8
+		FROM workouts
+		WHERE user_id = $1`)
+	if query.activityID != nil {
+		sql.WriteString(" AND activity_id = " + arg(*query.activityID))
+	}
+	if query.from != nil {
+		sql.WriteString(" AND timestamp >= " + arg(*query.from))
+	}
+	if query.to != nil {
+		sql.WriteString(" AND timestamp < " + arg(*query.to))
+	}
+	sql.WriteString(" GROUP BY " + groupColumns + " ORDER BY " + groupColumns)
+
+	rows, err := p.db.Query(ctx, sql.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []*workoutStatsBucket
+	for rows.Next() {
+		b := &workoutStatsBucket{}
+		var period time.Time
+		var totalSeconds, avgSeconds float64
+		err = rows.Scan(
+			&period,
+			&b.activityID,
+			&b.count,
+			&b.totalCaloriesBurned,
+			&b.avgCaloriesBurned,
+			&totalSeconds,
+			&avgSeconds,
+			&b.totalDistanceMeters,
+			&b.totalElevationGain,
+			&b.heartRateWorkouts,
+			&b.avgHeartRate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// the period is a wall clock time in the query's location
+		b.periodStart = time.Date(period.Year(), period.Month(), period.Day(),
+			period.Hour(), period.Minute(), period.Second(), 0, query.location)
+		b.totalDuration = time.Duration(totalSeconds * float64(time.Second))
+		b.avgDuration = time.Duration(avgSeconds * float64(time.Second))
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
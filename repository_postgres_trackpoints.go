@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// SaveWorkoutWithTrackpoints inserts the workout and copies in its track
+// within a single transaction
+func (p *postgresRepository) SaveWorkoutWithTrackpoints(ctx context.Context, w *workout, trackpoints []*trackpoint) error {
+	err := p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO workouts (
+				workout_id,
+				user_id,
+				activity_id,
+				timestamp,
+				calories_burned,
+				calories_estimated,
+				duration,
+				distance_meters,
+				avg_heart_rate,
+				max_heart_rate,
+				elevation_gain
+			) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
+			w.workoutID,
+			w.userID,
+			w.activityID,
+			w.timestamp,
+			w.caloriesBurned,
+			w.caloriesEstimated,
+			w.duration,
+			w.distanceMeters,
+			w.avgHeartRate,
+			w.maxHeartRate,
+			w.elevationGain,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CopyFrom(ctx,
+			pgx.Identifier{"trackpoints"},
+			[]string{
+				"workout_id",
+				"sequence",
+				"time",
+				"latitude",
+				"longitude",
+				"elevation_meters",
+				"heart_rate",
+			},
+			pgx.CopyFromSlice(len(trackpoints), func(i int) ([]interface{}, error) {
+				t := trackpoints[i]
+				return []interface{}{
+					w.workoutID,
+					t.sequence,
+					t.time,
+					t.latitude,
+					t.longitude,
+					t.elevationMeters,
+					t.heartRate,
+				}, nil
+			}),
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	w.version = 1
+	for _, t := range trackpoints {
+		t.workoutID = w.workoutID
+	}
+	return nil
+}
+
+func (p *postgresRepository) GetTrackpoints(ctx context.Context, w *workout) ([]*trackpoint, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			t.workout_id,
+			t.sequence,
+			t.time,
+			t.latitude,
+			t.longitude,
+			t.elevation_meters,
+			t.heart_rate
+		FROM trackpoints t
+		JOIN workouts w ON w.workout_id = t.workout_id
+		WHERE t.workout_id = $1
+			AND w.user_id = $2
+		ORDER BY t.sequence`, w.workoutID, w.userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trackpoints []*trackpoint
+	for rows.Next() {
+		t := &trackpoint{}
+		err = rows.Scan(
+			&t.workoutID,
+			&t.sequence,
+			&t.time,
+			&t.latitude,
+			&t.longitude,
+			&t.elevationMeters,
+			&t.heartRate,
+		)
+		if err != nil {
+			return nil, err
+		}
+		trackpoints = append(trackpoints, t)
+	}
+	return trackpoints, rows.Err()
+}
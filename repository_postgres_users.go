@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func (p *postgresRepository) SaveUser(ctx context.Context, u *user) error {
+	return p.db.QueryRow(ctx, `
+		INSERT INTO users (
+			user_id,
+			name,
+			email,
+			password_hash
+		) VALUES ($1,$2,$3,$4)
+		RETURNING created_at`,
+		u.userID,
+		u.name,
+		u.email,
+		u.passwordHash,
+	).Scan(&u.createdAt)
+}
+
+func (p *postgresRepository) GetUser(ctx context.Context, u *user) error {
+	return p.db.QueryRow(ctx, `
+		SELECT 
+			user_id,
+			name,
+			email,
+			created_at,
+			weight_kg,
+			COALESCE(password_hash, '')
+		FROM users
+		WHERE user_id = $1`, u.userID).Scan(
+		&u.userID,
+		&u.name,
+		&u.email,
+		&u.createdAt,
+		&u.weightKg,
+		&u.passwordHash,
+	)
+}
+
+func (p *postgresRepository) UpdateUser(ctx context.Context, u *user) error {
+	tag, err := p.db.Exec(ctx, `
+		UPDATE users SET (
+			name,
+			email,
+			weight_kg
+		) = ($2,$3,$4)
+		WHERE user_id = $1`,
+		u.userID,
+		u.name,
+		u.email,
+		u.weightKg,
+	)
+	return singleRowWriteError(tag, err, "user %s not found", u.userID)
+}
+
+func (p *postgresRepository) DeleteUser(ctx context.Context, u *user) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM users
+		WHERE user_id = $1`,
+		u.userID,
+	)
+	return singleRowWriteError(tag, err, "user %s not found", u.userID)
+}
+
+func (p *postgresRepository) UserExists(ctx context.Context, u *user) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM users
+		WHERE user_id = $1`, u.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) GetUserByEmail(ctx context.Context, email string) (*user, error) {
+	u := &user{}
+	err := p.db.QueryRow(ctx, `
+		SELECT 
+			user_id,
+			name,
+			email,
+			created_at,
+			weight_kg,
+			COALESCE(password_hash, '')
+		FROM users
+		WHERE email = $1`, email).Scan(
+		&u.userID,
+		&u.name,
+		&u.email,
+		&u.createdAt,
+		&u.weightKg,
+		&u.passwordHash,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
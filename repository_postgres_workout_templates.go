@@ -0,0 +1,129 @@
+package main
+
+import "context"
+
+func (p *postgresRepository) SaveWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	_, err := p.db.Exec(ctx, `
+		INSERT INTO workout_templates (
+			template_id,
+			user_id,
+			activity_id,
+			name,
+			target_duration,
+			target_calories_burned,
+			target_sets
+		) VALUES ($1,$2,$3,$4,$5,$6,$7)`,
+		t.templateID,
+		t.userID,
+		t.activityID,
+		t.name,
+		t.targetDuration,
+		t.targetCaloriesBurned,
+		t.targetSets,
+	)
+	return err
+}
+
+func (p *postgresRepository) GetWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	return p.db.QueryRow(ctx, `
+		SELECT
+			template_id,
+			user_id,
+			activity_id,
+			name,
+			target_duration,
+			target_calories_burned,
+			target_sets
+		FROM workout_templates
+		WHERE template_id = $1
+			AND user_id = $2`, t.templateID, t.userID).Scan(
+		&t.templateID,
+		&t.userID,
+		&t.activityID,
+		&t.name,
+		&t.targetDuration,
+		&t.targetCaloriesBurned,
+		&t.targetSets,
+	)
+}
+
+func (p *postgresRepository) UpdateWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	tag, err := p.db.Exec(ctx, `
+		UPDATE workout_templates SET (
+			activity_id,
+			name,
+			target_duration,
+			target_calories_burned,
+			target_sets
+		) = ($3,$4,$5,$6,$7)
+		WHERE template_id = $1
+			AND user_id = $2`,
+		t.templateID,
+		t.userID,
+		t.activityID,
+		t.name,
+		t.targetDuration,
+		t.targetCaloriesBurned,
+		t.targetSets,
+	)
+	return singleRowWriteError(tag, err, "workout template %s not found", t.templateID)
+}
+
+func (p *postgresRepository) DeleteWorkoutTemplate(ctx context.Context, t *workoutTemplate) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM workout_templates
+		WHERE template_id = $1
+			AND user_id = $2`, t.templateID, t.userID)
+	return singleRowWriteError(tag, err, "workout template %s not found", t.templateID)
+}
+
+func (p *postgresRepository) WorkoutTemplateExists(ctx context.Context, t *workoutTemplate) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM workout_templates
+		WHERE template_id = $1
+			AND user_id = $2`, t.templateID, t.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) GetAllWorkoutTemplates(ctx context.Context, userID string) ([]*workoutTemplate, error) {
+	rows, err := p.db.Query(ctx, `
+		SELECT
+			template_id,
+			user_id,
+			activity_id,
+			name,
+			target_duration,
+			target_calories_burned,
+			target_sets
+		FROM workout_templates
+		WHERE user_id = $1
+		ORDER BY name, template_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*workoutTemplate
+	for rows.Next() {
+		t := &workoutTemplate{}
+		err = rows.Scan(
+			&t.templateID,
+			&t.userID,
+			&t.activityID,
+			&t.name,
+			&t.targetDuration,
+			&t.targetCaloriesBurned,
+			&t.targetSets,
+		)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func (p *postgresRepository) SaveWorkout(ctx context.Context, w *workout) error {
+	tag, err := p.db.Exec(ctx, `
+		INSERT INTO workouts (
+			workout_id,
+			user_id,
+			activity_id,
+			timestamp,
+			calories_burned,
+			calories_estimated,
+			duration,
+			distance_meters,
+			avg_heart_rate,
+			max_heart_rate,
+			elevation_gain
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`,
+		w.workoutID,
+		w.userID,
+		w.activityID,
+		w.timestamp,
+		w.caloriesBurned,
+		w.caloriesEstimated,
+		w.duration,
+		w.distanceMeters,
+		w.avgHeartRate,
+		w.maxHeartRate,
+		w.elevationGain,
+	)
+	err = singleRowWriteError(tag, err, "workout %s not found", w.workoutID)
+	if err != nil {
+		return err
+	}
+	w.version = 1
+	return nil
+}
+
+// SaveWorkouts copies the workouts in within a single transaction
+func (p *postgresRepository) SaveWorkouts(ctx context.Context, workouts []*workout) error {
+	err := p.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.CopyFrom(ctx,
+			pgx.Identifier{"workouts"},
+			[]string{
+				"workout_id",
+				"user_id",
+				"activity_id",
+				"timestamp",
+				"calories_burned",
+				"calories_estimated",
+				"duration",
+				"distance_meters",
+				"avg_heart_rate",
+				"max_heart_rate",
+				"elevation_gain",
+			},
+			pgx.CopyFromSlice(len(workouts), func(i int) ([]interface{}, error) {
+				w := workouts[i]
+				return []interface{}{
+					w.workoutID,
+					w.userID,
+					w.activityID,
+					w.timestamp,
+					w.caloriesBurned,
+					w.caloriesEstimated,
+					w.duration,
+					w.distanceMeters,
+					w.avgHeartRate,
+					w.maxHeartRate,
+					w.elevationGain,
+				}, nil
+			}),
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, w := range workouts {
+		w.version = 1
+	}
+	return nil
+}
+
+func (p *postgresRepository) GetWorkout(ctx context.Context, w *workout) error {
+	return p.db.QueryRow(ctx, `
+		SELECT 
+			workout_id,
+			user_id,
+			activity_id,
+			timestamp,
+			calories_burned,
+			calories_estimated,
+			duration,
+			distance_meters,
+			avg_heart_rate,
+			max_heart_rate,
+			elevation_gain,
+			version
+		FROM workouts
+		WHERE workout_id = $1
+			AND user_id = $2`, w.workoutID, w.userID).Scan(
+		&w.workoutID,
+		&w.userID,
+		&w.activityID,
+		&w.timestamp,
+		&w.caloriesBurned,
+		&w.caloriesEstimated,
+		&w.duration,
+		&w.distanceMeters,
+		&w.avgHeartRate,
+		&w.maxHeartRate,
+		&w.elevationGain,
+		&w.version,
+	)
+}
+
+func (p *postgresRepository) UpdateWorkout(ctx context.Context, w *workout) error {
+	err := p.db.QueryRow(ctx, `
+		UPDATE workouts SET (
+			workout_id,
+			activity_id,
+			timestamp,
+			calories_burned,
+			calories_estimated,
+			duration,
+			distance_meters,
+			avg_heart_rate,
+			max_heart_rate,
+			elevation_gain,
+			version
+		) = ($1,$3,$4,$5,$6,$7,$8,$9,$10,$11,version + 1)
+		WHERE workout_id = $1
+			AND user_id = $2
+			AND ($12::bigint = 0 OR version = $12)
+		RETURNING version`,
+		w.workoutID,
+		w.userID,
+		w.activityID,
+		w.timestamp,
+		w.caloriesBurned,
+		w.caloriesEstimated,
+		w.duration,
+		w.distanceMeters,
+		w.avgHeartRate,
+		w.maxHeartRate,
+		w.elevationGain,
+		w.version,
+	).Scan(&w.version)
+	return p.conditionalWriteError(ctx, err, w.version, "workouts", "workout_id", w.workoutID, w.userID)
+}
+
+func (p *postgresRepository) PatchWorkout(ctx context.Context, w *workout, fields []string) error {
+	columns := map[string]interface{}{
+		"activity_id":        w.activityID,
+		"timestamp":          w.timestamp,
+		"calories_burned":    w.caloriesBurned,
+		"calories_estimated": w.caloriesEstimated,
+		"duration":           w.duration,
+		"distance_meters":    w.distanceMeters,
+		"avg_heart_rate":     w.avgHeartRate,
+		"max_heart_rate":     w.maxHeartRate,
+		"elevation_gain":     w.elevationGain,
+	}
+	return p.patch(ctx, "workouts", "workout_id", w.workoutID, w.userID, &w.version, columns, fields)
+}
+
+func (p *postgresRepository) DeleteWorkout(ctx context.Context, w *workout) error {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM workouts
+		WHERE workout_id = $1
+			AND user_id = $2
+			AND ($3::bigint = 0 OR version = $3)`,
+		w.workoutID,
+		w.userID,
+		w.version,
+	)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+	}
+	return p.conditionalWriteError(ctx, err, w.version, "workouts", "workout_id", w.workoutID, w.userID)
+}
+
+func (p *postgresRepository) WorkoutExists(ctx context.Context, w *workout) (bool, error) {
+	var count int
+	err := p.db.QueryRow(ctx, `
+		SELECT count(*)
+		FROM workouts
+		WHERE workout_id = $1
+			AND user_id = $2`, w.workoutID, w.userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+func (p *postgresRepository) QueryWorkouts(ctx context.Context, query *workoutQuery) ([]*workout, error) {
+	// the sort column is only ever one of these, never taken from the client verbatim
+	sortColumns := map[string]string{
+		workoutSortTimestamp:      "timestamp",
+		workoutSortCaloriesBurned: "calories_burned",
+		workoutSortDuration:       "duration",
+	}
+	sortColumn, ok := sortColumns[query.sort]
+	if !ok {
+		sortColumn = sortColumns[workoutSortTimestamp]
+	}
+	direction, comparison := "ASC", ">"
+	if query.descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	args := []interface{}{query.userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var sql strings.Builder
+	sql.WriteString(`
+		SELECT 
+			workout_id,
+			user_id,
+			activity_id,
+			timestamp,
+			calories_burned,
+			calories_estimated,
+			duration,
+			distance_meters,
+			avg_heart_rate,
+			max_heart_rate,
+			elevation_gain,
+			version
+		FROM workouts
+		WHERE user_id = $1`)
+	if query.activityID != nil {
+		sql.WriteString(" AND activity_id = " + arg(*query.activityID))
+	}
+	if query.from != nil {
+		sql.WriteString(" AND timestamp >= " + arg(*query.from))
+	}
+	if query.to != nil {
+		sql.WriteString(" AND timestamp < " + arg(*query.to))
+	}
+	if query.minDuration != nil {
+		sql.WriteString(" AND duration >= " + arg(*query.minDuration))
+	}
+	if query.maxDuration != nil {
+		sql.WriteString(" AND duration <= " + arg(*query.maxDuration))
+	}
+	if query.minDistance != nil {
+		sql.WriteString(" AND distance_meters >= " + arg(*query.minDistance))
+	}
+	if query.maxDistance != nil {
+		sql.WriteString(" AND distance_meters <= " + arg(*query.maxDistance))
+	}
+	if query.minAvgHeartRate != nil {
+		sql.WriteString(" AND avg_heart_rate >= " + arg(*query.minAvgHeartRate))
+	}
+	if query.maxAvgHeartRate != nil {
+		sql.WriteString(" AND avg_heart_rate <= " + arg(*query.maxAvgHeartRate))
+	}
+	if query.after != nil {
+		sql.WriteString(fmt.Sprintf(" AND (%s, workout_id) %s (%s, %s)",
+			sortColumn, comparison, arg(query.after.sortValue()), arg(query.after.WorkoutID)))
+	}
+	sql.WriteString(fmt.Sprintf(" ORDER BY %s %s, workout_id %s", sortColumn, direction, direction))
+	if query.limit > 0 {
+		sql.WriteString(" LIMIT " + arg(query.limit))
+	}
+
+	rows, err := p.db.Query(ctx, sql.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workouts []*workout
+	for rows.Next() {
+		w := &workout{}
+		err = rows.Scan(
+			&w.workoutID,
+			&w.userID,
+			&w.activityID,
+			&w.timestamp,
+			&w.caloriesBurned,
+			&w.caloriesEstimated,
+			&w.duration,
+			&w.distanceMeters,
+			&w.avgHeartRate,
+			&w.maxHeartRate,
+			&w.elevationGain,
+			&w.version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, w)
+	}
+	return workouts, rows.Err()
+}
@@ -1,9 +1,11 @@
-CREATE TABLE activities (
+-- tables are created only if missing so that databases bootstrapped
+-- from the old schema.sql are adopted without error
+CREATE TABLE IF NOT EXISTS activities (
     activity_id TEXT PRIMARY KEY,
     name TEXT NOT NULL
 );
 
-CREATE TABLE workouts (
+CREATE TABLE IF NOT EXISTS workouts (
     workout_id TEXT PRIMARY KEY,
     activity_id TEXT NOT NULL REFERENCES activities(activity_id),
     timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
@@ -0,0 +1,7 @@
+ALTER TABLE workouts
+    DROP COLUMN user_id;
+
+ALTER TABLE activities
+    DROP COLUMN user_id;
+
+DROP TABLE users;
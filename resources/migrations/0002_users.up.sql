@@ -0,0 +1,18 @@
+CREATE TABLE users (
+    user_id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- rows created before users existed have no owner and are
+-- not visible to anyone until they are assigned one
+ALTER TABLE activities
+    ADD COLUMN user_id TEXT REFERENCES users(user_id) ON DELETE CASCADE;
+
+ALTER TABLE workouts
+    ADD COLUMN user_id TEXT REFERENCES users(user_id) ON DELETE CASCADE;
+
+CREATE INDEX activities_user_id_idx ON activities(user_id);
+
+CREATE INDEX workouts_user_id_idx ON workouts(user_id);
@@ -0,0 +1,4 @@
+DROP TABLE refresh_tokens;
+
+ALTER TABLE users
+    DROP COLUMN password_hash;
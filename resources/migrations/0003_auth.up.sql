@@ -0,0 +1,14 @@
+-- users created before authentication existed have no password
+-- and cannot log in until one is set
+ALTER TABLE users
+    ADD COLUMN password_hash TEXT;
+
+CREATE TABLE refresh_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens(user_id);
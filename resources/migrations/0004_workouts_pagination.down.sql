@@ -0,0 +1 @@
+DROP INDEX workouts_user_id_timestamp_idx;
@@ -0,0 +1,2 @@
+-- supports paging through a user's workouts in timestamp order
+CREATE INDEX workouts_user_id_timestamp_idx ON workouts(user_id, timestamp, workout_id);
@@ -0,0 +1,5 @@
+ALTER TABLE workouts
+    DROP COLUMN version;
+
+ALTER TABLE activities
+    DROP COLUMN version;
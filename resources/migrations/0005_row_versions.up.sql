@@ -0,0 +1,6 @@
+-- incremented on every write, handed to clients as the row's ETag
+ALTER TABLE activities
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE workouts
+    ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+DROP TABLE trackpoints;
+
+ALTER TABLE workouts
+    DROP COLUMN distance_meters;
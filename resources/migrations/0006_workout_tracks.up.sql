@@ -0,0 +1,14 @@
+-- only known for workouts created from uploaded activity files
+ALTER TABLE workouts
+    ADD COLUMN distance_meters DOUBLE PRECISION;
+
+CREATE TABLE trackpoints (
+    workout_id TEXT NOT NULL REFERENCES workouts(workout_id) ON DELETE CASCADE,
+    sequence INTEGER NOT NULL,
+    time TIMESTAMP WITH TIME ZONE,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    elevation_meters DOUBLE PRECISION,
+    heart_rate INTEGER,
+    PRIMARY KEY (workout_id, sequence)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE workouts
+    DROP COLUMN avg_heart_rate,
+    DROP COLUMN max_heart_rate,
+    DROP COLUMN elevation_gain;
@@ -0,0 +1,5 @@
+-- optional measurements of a workout, distance_meters being one of them
+ALTER TABLE workouts
+    ADD COLUMN avg_heart_rate INTEGER,
+    ADD COLUMN max_heart_rate INTEGER,
+    ADD COLUMN elevation_gain DOUBLE PRECISION;
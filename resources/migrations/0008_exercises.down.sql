@@ -0,0 +1,3 @@
+DROP TABLE exercise_sets;
+
+DROP TABLE exercises;
@@ -0,0 +1,25 @@
+-- the exercises of a workout, in order. the activity catalog doubles as
+-- the exercise library. positions are kept contiguous from 0, and are only
+-- checked for uniqueness at commit so that they can be shifted around
+CREATE TABLE exercises (
+    exercise_id TEXT PRIMARY KEY,
+    workout_id TEXT NOT NULL REFERENCES workouts(workout_id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    activity_id TEXT NOT NULL REFERENCES activities(activity_id),
+    position INTEGER NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    CONSTRAINT exercises_workout_id_position_key UNIQUE (workout_id, position) DEFERRABLE INITIALLY DEFERRED
+);
+
+CREATE INDEX exercises_activity_id_idx ON exercises(activity_id);
+
+CREATE TABLE exercise_sets (
+    exercise_id TEXT NOT NULL REFERENCES exercises(exercise_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    reps INTEGER NOT NULL,
+    weight DOUBLE PRECISION,
+    weight_unit TEXT NOT NULL DEFAULT 'kg' CHECK (weight_unit IN ('kg', 'lb')),
+    rpe DOUBLE PRECISION,
+    rest INTERVAL,
+    PRIMARY KEY (exercise_id, position)
+);
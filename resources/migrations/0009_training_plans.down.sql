@@ -0,0 +1,5 @@
+DROP TABLE program_sessions;
+
+DROP TABLE programs;
+
+DROP TABLE workout_templates;
@@ -0,0 +1,41 @@
+-- what a planned workout should look like
+CREATE TABLE workout_templates (
+    template_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    activity_id TEXT NOT NULL REFERENCES activities(activity_id),
+    name TEXT NOT NULL,
+    target_duration INTERVAL,
+    target_calories_burned INTEGER,
+    target_sets INTEGER
+);
+
+CREATE INDEX workout_templates_user_id_idx ON workout_templates(user_id);
+
+-- programs schedule templates across weeks starting on start_date, with
+-- dates in the program's time zone
+CREATE TABLE programs (
+    program_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    start_date DATE NOT NULL,
+    time_zone TEXT NOT NULL DEFAULT 'UTC'
+);
+
+CREATE INDEX programs_user_id_idx ON programs(user_id);
+
+-- a session falls on day 1 to 7 of week 1 onwards of its program, and is
+-- completed by the workout it was matched to
+CREATE TABLE program_sessions (
+    session_id TEXT PRIMARY KEY,
+    program_id TEXT NOT NULL REFERENCES programs(program_id) ON DELETE CASCADE,
+    template_id TEXT NOT NULL REFERENCES workout_templates(template_id),
+    week INTEGER NOT NULL CHECK (week >= 1),
+    day INTEGER NOT NULL CHECK (day BETWEEN 1 AND 7),
+    workout_id TEXT REFERENCES workouts(workout_id) ON DELETE SET NULL
+);
+
+CREATE INDEX program_sessions_program_id_idx ON program_sessions(program_id);
+
+CREATE INDEX program_sessions_template_id_idx ON program_sessions(template_id);
+
+CREATE INDEX program_sessions_workout_id_idx ON program_sessions(workout_id);
@@ -0,0 +1,3 @@
+DROP TABLE goal_periods;
+
+DROP TABLE goals;
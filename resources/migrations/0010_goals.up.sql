@@ -0,0 +1,30 @@
+-- goals set a target for the total of a metric over every calendar period,
+-- counting the user's workouts of activity_id, or of every activity if null.
+-- targets are in kilocalories for calories_burned and in milliseconds for
+-- duration, and periods start at midnight in the goal's time zone
+CREATE TABLE goals (
+    goal_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    activity_id TEXT REFERENCES activities(activity_id),
+    metric TEXT NOT NULL CHECK (metric IN ('calories_burned', 'duration')),
+    target BIGINT NOT NULL CHECK (target > 0),
+    period TEXT NOT NULL CHECK (period IN ('day', 'week', 'month', 'year')),
+    time_zone TEXT NOT NULL DEFAULT 'UTC',
+    -- the start of the first period tracked, history is kept from here on
+    started_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX goals_user_id_idx ON goals(user_id);
+
+CREATE INDEX goals_activity_id_idx ON goals(activity_id);
+
+-- the outcome of every finished period of a goal, recorded with the target
+-- it had at the time
+CREATE TABLE goal_periods (
+    goal_id TEXT NOT NULL REFERENCES goals(goal_id) ON DELETE CASCADE,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    target BIGINT NOT NULL,
+    value BIGINT NOT NULL,
+    PRIMARY KEY (goal_id, period_start)
+);
@@ -0,0 +1,16 @@
+-- achievements are re-evaluated from a user's workouts on every change to
+-- them. each one is earned by a workout, and personal bests are per activity
+CREATE TABLE achievements (
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    activity_id TEXT REFERENCES activities(activity_id) ON DELETE CASCADE,
+    workout_id TEXT NOT NULL REFERENCES workouts(workout_id) ON DELETE CASCADE,
+    value BIGINT,
+    earned_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX achievements_user_id_code_activity_id_idx ON achievements(user_id, code, coalesce(activity_id, ''));
+
+CREATE INDEX achievements_activity_id_idx ON achievements(activity_id);
+
+CREATE INDEX achievements_workout_id_idx ON achievements(workout_id);
@@ -0,0 +1,8 @@
+ALTER TABLE workouts
+    DROP COLUMN calories_estimated;
+
+ALTER TABLE users
+    DROP COLUMN weight_kg;
+
+ALTER TABLE activities
+    DROP COLUMN met;
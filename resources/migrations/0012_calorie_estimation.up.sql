@@ -0,0 +1,47 @@
+-- the metabolic equivalent of an activity, which calories are estimated with
+ALTER TABLE activities
+    ADD COLUMN met DOUBLE PRECISION CHECK (met > 0);
+
+-- existing activities are seeded from the 2011 Compendium of Physical
+-- Activities by name, as new ones are when created
+UPDATE activities
+SET met = compendium.met
+FROM (VALUES
+    ('walk', 3.5),
+    ('walking', 3.5),
+    ('hike', 6.0),
+    ('hiking', 6.0),
+    ('jog', 7.0),
+    ('jogging', 7.0),
+    ('run', 9.8),
+    ('running', 9.8),
+    ('bike', 7.5),
+    ('biking', 7.5),
+    ('cycling', 7.5),
+    ('swim', 6.0),
+    ('swimming', 6.0),
+    ('rowing', 7.0),
+    ('elliptical', 5.0),
+    ('jump rope', 11.8),
+    ('climbing', 8.0),
+    ('yoga', 2.5),
+    ('pilates', 3.0),
+    ('dancing', 5.0),
+    ('strength training', 3.5),
+    ('weight lifting', 3.5),
+    ('weightlifting', 3.5),
+    ('basketball', 6.5),
+    ('soccer', 7.0),
+    ('tennis', 7.3),
+    ('golf', 4.8)
+) AS compendium (name, met)
+WHERE lower(trim(activities.name)) = compendium.name;
+
+-- the body weight calories are estimated with
+ALTER TABLE users
+    ADD COLUMN weight_kg DOUBLE PRECISION CHECK (weight_kg > 0);
+
+-- whether calories_burned was estimated from the MET of the activity and
+-- the weight of the user rather than measured
+ALTER TABLE workouts
+    ADD COLUMN calories_estimated BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,12 @@
+-- measurements are a time series of a user's body metrics, stored in the
+-- canonical unit of their kind: kilograms, percent, beats per minute or
+-- centimeters
+CREATE TABLE measurements (
+    measurement_id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('weight', 'body_fat', 'resting_heart_rate', 'waist', 'hip')),
+    value DOUBLE PRECISION NOT NULL CHECK (value > 0),
+    measured_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX measurements_user_id_kind_measured_at_idx ON measurements(user_id, kind, measured_at);
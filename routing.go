@@ -1,32 +1,159 @@
 package main
 
 import (
+	"context"
+	"net"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
-func listenAndServe(log *logrus.Logger, appData *appData) {
-	router := mux.NewRouter().PathPrefix("/v1").Subrouter()
+// streamingRoutes read or write their bodies as they go, and are bounded
+// by the stream timeout rather than the request timeout
+var streamingRoutes = map[string]bool{
+	"/v1/workouts/export": true,
+	"/v1/workouts/import": true,
+	"/v1/workouts/upload": true,
+}
+
+// isStreamingRoute reports whether the request was routed to a streaming
+// route
+func isStreamingRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	template, err := route.GetPathTemplate()
+	return err == nil && streamingRoutes[template]
+}
+
+// newRouter routes every endpoint of the API to its handler
+func newRouter(log *logrus.Logger, appData *appData) *mux.Router {
+	root := mux.NewRouter()
+	root.Use(getMetricsMiddleware(appData))
+	root.Use(getRequestIDMiddleware(appData))
+	root.Use(getTimeoutMiddleware(appData))
+
+	// health, build info and metrics, outside of /v1 and exempt from authentication
+	root.Path("/healthz").HandlerFunc(getHealthzGetHandlerFunc(log, appData)).Methods("GET")
+	root.Path("/readyz").HandlerFunc(getReadyzGetHandlerFunc(log, appData)).Methods("GET")
+	root.Path("/version").HandlerFunc(getVersionGetHandlerFunc(log, appData)).Methods("GET")
+	root.Path("/metrics").Handler(appData.metrics.handler()).Methods("GET")
+
+	// /auth, which is exempt from authentication
+	auth := root.PathPrefix("/v1/auth").Subrouter()
+	auth.Path("/signup").HandlerFunc(getAuthSignupPostHandlerFunc(log, appData)).Methods("POST")
+	auth.Path("/login").HandlerFunc(getAuthLoginPostHandlerFunc(log, appData)).Methods("POST")
+	auth.Path("/refresh").HandlerFunc(getAuthRefreshPostHandlerFunc(log, appData)).Methods("POST")
+	auth.Path("/logout").HandlerFunc(getAuthLogoutPostHandlerFunc(log, appData)).Methods("POST")
+
+	// everything else is scoped to the authenticated user
+	router := root.PathPrefix("/v1").Subrouter()
+	router.Use(getAuthenticationMiddleware(log, appData))
+
+	// /users
+	router.Path("/users/{id}").HandlerFunc(getUsersGetHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/users/{id}").HandlerFunc(getUsersPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/users/{id}").HandlerFunc(getUsersDeleteHandlerFunc(log, appData)).Methods("DELETE")
 
 	// /activities
 	router.Path("/activities/{id}").HandlerFunc(getActivitiesGetHandlerFunc(log, appData)).Methods("GET")
 	router.Path("/activities").HandlerFunc(getActivitiesGetAllHandlerFunc(log, appData)).Methods("GET")
 	router.Path("/activities").HandlerFunc(getActivitiesPostHandlerFunc(log, appData)).Methods("POST")
 	router.Path("/activities/{id}").HandlerFunc(getActivitiesPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/activities/{id}").HandlerFunc(getActivitiesPatchHandlerFunc(log, appData)).Methods("PATCH")
 	router.Path("/activities/{id}").HandlerFunc(getActivitiesDeleteHandlerFunc(log, appData)).Methods("DELETE")
 
 	// /workouts
+	router.Path("/workouts/export").HandlerFunc(getWorkoutsExportGetHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/workouts/import").HandlerFunc(getWorkoutsImportPostHandlerFunc(log, appData)).Methods("POST")
+	router.Path("/workouts/upload").HandlerFunc(getWorkoutsUploadPostHandlerFunc(log, appData)).Methods("POST")
 	router.Path("/workouts/{id}").HandlerFunc(getWorkoutsGetHandlerFunc(log, appData)).Methods("GET")
 	router.Path("/workouts").HandlerFunc(getWorkoutsGetAllHandlerFunc(log, appData)).Methods("GET")
 	router.Path("/workouts").HandlerFunc(getWorkoutsPostHandlerFunc(log, appData)).Methods("POST")
+	router.Path("/workouts:batch").HandlerFunc(getWorkoutsBatchPostHandlerFunc(log, appData)).Methods("POST")
 	router.Path("/workouts/{id}").HandlerFunc(getWorkoutsPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/workouts/{id}").HandlerFunc(getWorkoutsPatchHandlerFunc(log, appData)).Methods("PATCH")
 	router.Path("/workouts/{id}").HandlerFunc(getWorkoutsDeleteHandlerFunc(log, appData)).Methods("DELETE")
+	router.Path("/workouts/{id}/track").HandlerFunc(getWorkoutsTrackGetHandlerFunc(log, appData)).Methods("GET")
+
+	// /workouts/{id}/exercises
+	router.Path("/workouts/{id}/exercises/{exercise_id}").HandlerFunc(getExercisesGetHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/workouts/{id}/exercises").HandlerFunc(getExercisesGetAllHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/workouts/{id}/exercises").HandlerFunc(getExercisesPostHandlerFunc(log, appData)).Methods("POST")
+	router.Path("/workouts/{id}/exercises/{exercise_id}").HandlerFunc(getExercisesPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/workouts/{id}/exercises/{exercise_id}").HandlerFunc(getExercisesDeleteHandlerFunc(log, appData)).Methods("DELETE")
+
+	// /templates
+	router.Path("/templates/{id}").HandlerFunc(getWorkoutTemplatesGetHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/templates").HandlerFunc(getWorkoutTemplatesGetAllHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/templates").HandlerFunc(getWorkoutTemplatesPostHandlerFunc(log, appData)).Methods("POST")
+	router.Path("/templates/{id}").HandlerFunc(getWorkoutTemplatesPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/templates/{id}").HandlerFunc(getWorkoutTemplatesDeleteHandlerFunc(log, appData)).Methods("DELETE")
+
+	// /programs
+	router.Path("/programs/{id}").HandlerFunc(getProgramsGetHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/programs").HandlerFunc(getProgramsGetAllHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/programs").HandlerFunc(getProgramsPostHandlerFunc(log, appData)).Methods("POST")
+	router.Path("/programs/{id}").HandlerFunc(getProgramsPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/programs/{id}").HandlerFunc(getProgramsDeleteHandlerFunc(log, appData)).Methods("DELETE")
 
+	// /plan
+	router.Path("/plan").HandlerFunc(getPlanGetHandlerFunc(log, appData)).Methods("GET")
+
+	// /goals
+	router.Path("/goals/{id}").HandlerFunc(getGoalsGetHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/goals").HandlerFunc(getGoalsGetAllHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/goals").HandlerFunc(getGoalsPostHandlerFunc(log, appData)).Methods("POST")
+	router.Path("/goals/{id}").HandlerFunc(getGoalsPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/goals/{id}").HandlerFunc(getGoalsDeleteHandlerFunc(log, appData)).Methods("DELETE")
+	router.Path("/goals/{id}/history").HandlerFunc(getGoalsHistoryGetHandlerFunc(log, appData)).Methods("GET")
+
+	// /measurements
+	router.Path("/measurements/{id}").HandlerFunc(getMeasurementsGetHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/measurements").HandlerFunc(getMeasurementsGetAllHandlerFunc(log, appData)).Methods("GET")
+	router.Path("/measurements").HandlerFunc(getMeasurementsPostHandlerFunc(log, appData)).Methods("POST")
+	router.Path("/measurements/{id}").HandlerFunc(getMeasurementsPutHandlerFunc(log, appData)).Methods("PUT")
+	router.Path("/measurements/{id}").HandlerFunc(getMeasurementsDeleteHandlerFunc(log, appData)).Methods("DELETE")
+
+	// /achievements
+	router.Path("/achievements").HandlerFunc(getAchievementsGetHandlerFunc(log, appData)).Methods("GET")
+
+	// /stats
+	router.Path("/stats/workouts").HandlerFunc(getStatsWorkoutsGetHandlerFunc(log, appData)).Methods("GET")
+
+	return root
+}
+
+// newServer serves the API with the configured timeouts. they hold for
+// every route but the streaming ones, whose connections are given the
+// stream timeout by the timeout middleware
+func newServer(log *logrus.Logger, appData *appData) *http.Server {
+	return &http.Server{
+		Addr:         ":8080",
+		Handler:      newRouter(log, appData),
+		ReadTimeout:  appData.config.server.readTimeout,
+		WriteTimeout: appData.config.server.writeTimeout,
+		IdleTimeout:  appData.config.server.idleTimeout,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return contextWithConn(ctx, conn)
+		},
+	}
+}
+
+// listenAndServe starts the API server in the background. the returned
+// channel receives the error that stopped the server, unless it was shut down
+func listenAndServe(log *logrus.Logger, appData *appData) (*http.Server, <-chan error) {
+	server := newServer(log, appData)
+
+	serverErrors := make(chan error, 1)
 	go func() {
-		if err := http.ListenAndServe(":8080", router); err != nil {
-			log.WithError(err).Error("error in http.ListenAndServer()")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("error in server.ListenAndServe()")
+			serverErrors <- err
 		}
 	}()
+
+	return server, serverErrors
 }
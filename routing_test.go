@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testServer routes requests to the handlers of an API backed by the memory
+// repository
+type testServer struct {
+	t       *testing.T
+	log     *logrus.Logger
+	appData *appData
+	router  http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	metrics, err := initMetrics(log, nil)
+	if err != nil {
+		t.Fatalf("cannot initialize metrics: %v", err)
+	}
+
+	appData := &appData{
+		config: &config{
+			storage: "memory",
+			server: &serverConfig{
+				requestTimeout: 10 * time.Second,
+				streamTimeout:  time.Minute,
+				errorFormat:    errorFormatLegacy,
+			},
+			auth: &authConfig{
+				jwtSecret:       []byte("test-secret-test-secret-test-secret"),
+				accessTokenTTL:  15 * time.Minute,
+				refreshTokenTTL: time.Hour,
+			},
+		},
+		repository: newMemoryRepository(),
+		metrics:    metrics,
+	}
+
+	return &testServer{t: t, log: log, appData: appData, router: newRouter(log, appData)}
+}
+
+// do sends the request, authenticated with token unless it is empty
+func (s *testServer) do(method string, path string, token string, headers map[string]string, body io.Reader) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	r := httptest.NewRequest(method, path, body)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+
+	rw := httptest.NewRecorder()
+	s.router.ServeHTTP(rw, r)
+	return rw
+}
+
+// doJSON sends v encoded as JSON
+func (s *testServer) doJSON(method string, path string, token string, v interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		s.t.Fatalf("cannot encode request body: %v", err)
+	}
+	return s.do(method, path, token, map[string]string{"Content-Type": "application/json"}, bytes.NewReader(body))
+}
+
+// signup creates a user, returning their tokens
+func (s *testServer) signup(email string) TokenResponse {
+	s.t.Helper()
+
+	rw := s.doJSON("POST", "/v1/auth/signup", "", map[string]string{
+		"name":     "Test User",
+		"email":    email,
+		"password": "hunter22",
+	})
+	expectStatus(s.t, rw, http.StatusCreated)
+
+	var tokens TokenResponse
+	decodeBody(s.t, rw, &tokens)
+	return tokens
+}
+
+// createActivity creates an activity for the user, returning its id
+func (s *testServer) createActivity(token string) string {
+	s.t.Helper()
+
+	rw := s.doJSON("POST", "/v1/activities", token, map[string]interface{}{
+		"name": "Running",
+		"met":  9.8,
+	})
+	expectStatus(s.t, rw, http.StatusCreated)
+
+	var activity PostActivitiesResponse
+	decodeBody(s.t, rw, &activity)
+	return activity.ActivityID
+}
+
+// createWorkout creates a workout of the activity, returning it
+func (s *testServer) createWorkout(token string, activityID string, timestamp time.Time) PostWorkoutsResponse {
+	s.t.Helper()
+
+	rw := s.doJSON("POST", "/v1/workouts", token, map[string]interface{}{
+		"activity_id":     activityID,
+		"timestamp":       timestamp.UTC().Format(time.RFC3339),
+		"calories_burned": 300,
+		"duration":        int64(30 * time.Minute / time.Millisecond),
+	})
+	expectStatus(s.t, rw, http.StatusCreated)
+
+	var workout PostWorkoutsResponse
+	decodeBody(s.t, rw, &workout)
+	return workout
+}
+
+// createProgram creates a program starting on the day of start, planning
+// a session of a new template of the activity on each of days, counted
+// from 1
+func (s *testServer) createProgram(token string, activityID string, start time.Time, days ...int) GetProgramsResponse {
+	s.t.Helper()
+
+	rw := s.doJSON("POST", "/v1/templates", token, map[string]interface{}{
+		"activity_id": activityID,
+		"name":        "Easy run",
+	})
+	expectStatus(s.t, rw, http.StatusCreated)
+	var template GetWorkoutTemplatesResponse
+	decodeBody(s.t, rw, &template)
+
+	var sessions []map[string]interface{}
+	for _, day := range days {
+		sessions = append(sessions, map[string]interface{}{
+			"template_id": template.TemplateID,
+			"week":        (day-1)/7 + 1,
+			"day":         (day-1)%7 + 1,
+		})
+	}
+	rw = s.doJSON("POST", "/v1/programs", token, map[string]interface{}{
+		"name":       "Base building",
+		"start_date": start.UTC().Format(dateFormat),
+		"time_zone":  "UTC",
+		"sessions":   sessions,
+	})
+	expectStatus(s.t, rw, http.StatusCreated)
+
+	var program GetProgramsResponse
+	decodeBody(s.t, rw, &program)
+	return program
+}
+
+func expectStatus(t *testing.T, rw *httptest.ResponseRecorder, statusCode int) {
+	t.Helper()
+
+	if rw.Code != statusCode {
+		t.Fatalf("expected status %d, got %d: %s", statusCode, rw.Code, rw.Body.String())
+	}
+}
+
+func decodeBody(t *testing.T, rw *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(rw.Body.Bytes(), v); err != nil {
+		t.Fatalf("cannot decode response body %q: %v", rw.Body.String(), err)
+	}
+}
+
+// expectValidationErrors checks the response is a 422 listing exactly the
+// given field codes
+func expectValidationErrors(t *testing.T, rw *httptest.ResponseRecorder, fieldCodes map[string]string) {
+	t.Helper()
+
+	expectStatus(t, rw, http.StatusUnprocessableEntity)
+
+	var response ErrorResponse
+	decodeBody(t, rw, &response)
+	if response.ErrorInfo == nil || response.ErrorInfo.Code != errorCodeValidationFailed {
+		t.Fatalf("expected a %s error, got %s", errorCodeValidationFailed, rw.Body.String())
+	}
+
+	got := make(map[string]string)
+	for _, detail := range response.ErrorInfo.Details {
+		got[detail.Field] = detail.Code
+	}
+	if len(got) != len(fieldCodes) {
+		t.Fatalf("expected invalid fields %v, got %v", fieldCodes, got)
+	}
+	for field, code := range fieldCodes {
+		if got[field] != code {
+			t.Fatalf("expected invalid fields %v, got %v", fieldCodes, got)
+		}
+	}
+}
+
+// trickle writes body a byte at a time, pausing between bytes
+type trickle struct {
+	body  []byte
+	pause time.Duration
+}
+
+func (r *trickle) Read(p []byte) (int, error) {
+	if len(r.body) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.pause)
+	p[0] = r.body[0]
+	r.body = r.body[1:]
+	return 1, nil
+}
+
+func TestServerStreamingTimeouts(t *testing.T) {
+	s := newTestServer(t)
+	s.appData.config.server.readTimeout = 200 * time.Millisecond
+	s.appData.config.server.writeTimeout = 200 * time.Millisecond
+	s.appData.config.server.streamTimeout = 10 * time.Second
+
+	tokens := s.signup("ada@example.com")
+	activityID := s.createActivity(tokens.AccessToken)
+	timestamp := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = newServer(s.log, s.appData)
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		streaming   bool
+	}{
+		{
+			name:        "batch is held to the read timeout",
+			path:        "/v1/workouts:batch",
+			contentType: "application/json",
+			body: `[{"activity_id":"` + activityID + `","timestamp":"` + timestamp +
+				`","calories_burned":300,"duration":1800000}]`,
+		},
+		{
+			name:        "import has the stream timeout",
+			path:        "/v1/workouts/import",
+			contentType: "text/csv",
+			body:        "activity_id,timestamp,calories_burned,duration\n" + activityID + "," + timestamp + ",300,1800000\n",
+			streaming:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// sent over about twice the read timeout
+			pause := 400 * time.Millisecond / time.Duration(len(test.body))
+			r, err := http.NewRequest("POST", server.URL+test.path, &trickle{body: []byte(test.body), pause: pause})
+			if err != nil {
+				t.Fatalf("cannot create request: %v", err)
+			}
+			r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+			r.Header.Set("Content-Type", test.contentType)
+
+			response, err := server.Client().Do(r)
+			if err == nil {
+				defer response.Body.Close()
+			}
+
+			if test.streaming {
+				if err != nil {
+					t.Fatalf("expected the request to complete, got %v", err)
+				}
+				if response.StatusCode != http.StatusOK {
+					t.Fatalf("expected status %d, got %d", http.StatusOK, response.StatusCode)
+				}
+			} else if err == nil && response.StatusCode == http.StatusOK {
+				t.Fatalf("expected the request to time out")
+			}
+		})
+	}
+}
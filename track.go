@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/tormoder/fit"
+)
+
+const (
+	trackFormatGPX = "gpx"
+	trackFormatTCX = "tcx"
+	trackFormatFIT = "fit"
+)
+
+// parsedTrack is what an uploaded activity file says about a workout.
+// summary values are nil when the file does not record them
+type parsedTrack struct {
+	trackpoints []*trackpoint
+
+	start          time.Time
+	duration       time.Duration
+	distanceMeters *float64
+	caloriesBurned *int
+	avgHeartRate   *int
+	maxHeartRate   *int
+	elevationGain  *float64
+}
+
+// detectTrackFormat tells the supported activity file formats apart by
+// their contents: FIT files carry a .FIT signature in their header, while
+// GPX and TCX are XML documents with different root elements
+func detectTrackFormat(data []byte) (string, error) {
+	if len(data) >= 12 && string(data[8:12]) == ".FIT" {
+		return trackFormatFIT, nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("unrecognized activity file format")
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "gpx":
+				return trackFormatGPX, nil
+			case "TrainingCenterDatabase":
+				return trackFormatTCX, nil
+			default:
+				return "", fmt.Errorf("unrecognized activity file format")
+			}
+		}
+	}
+}
+
+func parseTrack(format string, data []byte) (*parsedTrack, error) {
+	var track *parsedTrack
+	var err error
+	switch format {
+	case trackFormatGPX:
+		track, err = parseGPX(data)
+	case trackFormatTCX:
+		track, err = parseTCX(data)
+	case trackFormatFIT:
+		track, err = parseFIT(data)
+	default:
+		return nil, fmt.Errorf("unsupported activity file format: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s file: %w", strings.ToUpper(format), err)
+	}
+
+	for i, t := range track.trackpoints {
+		t.sequence = i
+	}
+	// fall back to the recorded times for whatever the file does not summarize
+	first, last := trackTimeRange(track.trackpoints)
+	if track.start.IsZero() {
+		if first == nil {
+			return nil, fmt.Errorf("%s file records no time", strings.ToUpper(format))
+		}
+		track.start = *first
+	}
+	if track.duration == 0 && last != nil {
+		track.duration = last.Sub(track.start)
+	}
+	if track.distanceMeters == nil {
+		track.distanceMeters = trackDistance(track.trackpoints)
+	}
+	if track.avgHeartRate == nil && track.maxHeartRate == nil {
+		track.avgHeartRate, track.maxHeartRate = trackHeartRate(track.trackpoints)
+	}
+	if track.elevationGain == nil {
+		track.elevationGain = trackElevationGain(track.trackpoints)
+	}
+	return track, nil
+}
+
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []struct {
+				Latitude   float64    `xml:"lat,attr"`
+				Longitude  float64    `xml:"lon,attr"`
+				Elevation  *float64   `xml:"ele"`
+				Time       *time.Time `xml:"time"`
+				Extensions struct {
+					// garmin's TrackPointExtension, the de facto standard for heart rate
+					HeartRate *int `xml:"TrackPointExtension>hr"`
+				} `xml:"extensions"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// parseGPX reads the tracks of a GPX file, which has no summary values,
+// so everything is derived from the points
+func parseGPX(data []byte) (*parsedTrack, error) {
+	var file gpxFile
+	err := xml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &parsedTrack{}
+	for _, t := range file.Tracks {
+		for _, segment := range t.Segments {
+			for _, p := range segment.Points {
+				latitude, longitude := p.Latitude, p.Longitude
+				track.trackpoints = append(track.trackpoints, &trackpoint{
+					time:            p.Time,
+					latitude:        &latitude,
+					longitude:       &longitude,
+					elevationMeters: p.Elevation,
+					heartRate:       p.Extensions.HeartRate,
+				})
+			}
+		}
+	}
+	return track, nil
+}
+
+type tcxFile struct {
+	Activities []struct {
+		Laps []struct {
+			StartTime        time.Time `xml:"StartTime,attr"`
+			TotalTimeSeconds float64   `xml:"TotalTimeSeconds"`
+			DistanceMeters   *float64  `xml:"DistanceMeters"`
+			Calories         *int      `xml:"Calories"`
+			Points           []struct {
+				Time     *time.Time `xml:"Time"`
+				Position *struct {
+					Latitude  float64 `xml:"LatitudeDegrees"`
+					Longitude float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				AltitudeMeters *float64 `xml:"AltitudeMeters"`
+				HeartRate      *int     `xml:"HeartRateBpm>Value"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// parseTCX reads the first activity of a TCX file, summing up its laps
+func parseTCX(data []byte) (*parsedTrack, error) {
+	var file tcxFile
+	err := xml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Activities) == 0 || len(file.Activities[0].Laps) == 0 {
+		return nil, fmt.Errorf("no activity laps")
+	}
+
+	track := &parsedTrack{}
+	var totalSeconds float64
+	for i, lap := range file.Activities[0].Laps {
+		if i == 0 {
+			track.start = lap.StartTime
+		}
+		totalSeconds += lap.TotalTimeSeconds
+		if lap.DistanceMeters != nil {
+			track.distanceMeters = addFloat(track.distanceMeters, *lap.DistanceMeters)
+		}
+		if lap.Calories != nil {
+			track.caloriesBurned = addInt(track.caloriesBurned, *lap.Calories)
+		}
+
+		for _, p := range lap.Points {
+			t := &trackpoint{
+				time:            p.Time,
+				elevationMeters: p.AltitudeMeters,
+				heartRate:       p.HeartRate,
+			}
+			if p.Position != nil {
+				latitude, longitude := p.Position.Latitude, p.Position.Longitude
+				t.latitude, t.longitude = &latitude, &longitude
+			}
+			track.trackpoints = append(track.trackpoints, t)
+		}
+	}
+	track.duration = time.Duration(totalSeconds * float64(time.Second))
+	return track, nil
+}
+
+// parseFIT reads a FIT activity file, taking summary values from its sessions
+func parseFIT(data []byte) (*parsedTrack, error) {
+	file, err := fit.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	activity, err := file.Activity()
+	if err != nil {
+		return nil, err
+	}
+
+	// times missing from the file decode to the FIT epoch rather than zero
+	track := &parsedTrack{}
+	var timerSeconds float64
+	for i, session := range activity.Sessions {
+		if i == 0 && !fit.IsBaseTime(session.StartTime) {
+			track.start = session.StartTime
+		}
+		if s := session.GetTotalTimerTimeScaled(); !math.IsNaN(s) {
+			timerSeconds += s
+		}
+		if d := session.GetTotalDistanceScaled(); !math.IsNaN(d) {
+			track.distanceMeters = addFloat(track.distanceMeters, d)
+		}
+		if session.TotalCalories != 0xFFFF {
+			track.caloriesBurned = addInt(track.caloriesBurned, int(session.TotalCalories))
+		}
+		if session.TotalAscent != 0xFFFF {
+			track.elevationGain = addFloat(track.elevationGain, float64(session.TotalAscent))
+		}
+	}
+	track.duration = time.Duration(timerSeconds * float64(time.Second))
+
+	for _, record := range activity.Records {
+		t := &trackpoint{}
+		if !record.Timestamp.IsZero() && !fit.IsBaseTime(record.Timestamp) {
+			timestamp := record.Timestamp
+			t.time = &timestamp
+		}
+		if !record.PositionLat.Invalid() && !record.PositionLong.Invalid() {
+			latitude, longitude := record.PositionLat.Degrees(), record.PositionLong.Degrees()
+			t.latitude, t.longitude = &latitude, &longitude
+		}
+		elevation := record.GetEnhancedAltitudeScaled()
+		if math.IsNaN(elevation) {
+			elevation = record.GetAltitudeScaled()
+		}
+		if !math.IsNaN(elevation) {
+			t.elevationMeters = &elevation
+		}
+		if record.HeartRate != 0xFF {
+			heartRate := int(record.HeartRate)
+			t.heartRate = &heartRate
+		}
+		track.trackpoints = append(track.trackpoints, t)
+	}
+	return track, nil
+}
+
+// trackTimeRange returns the first and last recorded times of a track
+func trackTimeRange(trackpoints []*trackpoint) (*time.Time, *time.Time) {
+	var first, last *time.Time
+	for _, t := range trackpoints {
+		if t.time == nil {
+			continue
+		}
+		if first == nil {
+			first = t.time
+		}
+		last = t.time
+	}
+	return first, last
+}
+
+// trackDistance sums the great circle distances between consecutive
+// positions, or returns nil if the track has fewer than two of them
+func trackDistance(trackpoints []*trackpoint) *float64 {
+	const earthRadiusMeters = 6371008.8
+
+	var previous *trackpoint
+	var distance float64
+	positions := 0
+	for _, t := range trackpoints {
+		if t.latitude == nil || t.longitude == nil {
+			continue
+		}
+		positions++
+		if previous != nil {
+			lat1, lat2 := *previous.latitude*math.Pi/180, *t.latitude*math.Pi/180
+			dLat := lat2 - lat1
+			dLon := (*t.longitude - *previous.longitude) * math.Pi / 180
+			a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+			distance += 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+		}
+		previous = t
+	}
+	if positions < 2 {
+		return nil
+	}
+	return &distance
+}
+
+// trackHeartRate returns the average and maximum of the recorded heart
+// rates, or nil if the track records none
+func trackHeartRate(trackpoints []*trackpoint) (*int, *int) {
+	var total, count int
+	var max *int
+	for _, t := range trackpoints {
+		if t.heartRate == nil {
+			continue
+		}
+		total += *t.heartRate
+		count++
+		if max == nil || *t.heartRate > *max {
+			max = t.heartRate
+		}
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	avg := int(math.Round(float64(total) / float64(count)))
+	return &avg, max
+}
+
+// trackElevationGain sums the climbs between consecutive recorded
+// elevations, or returns nil if the track has fewer than two of them
+func trackElevationGain(trackpoints []*trackpoint) *float64 {
+	var previous *float64
+	var gain float64
+	elevations := 0
+	for _, t := range trackpoints {
+		if t.elevationMeters == nil {
+			continue
+		}
+		elevations++
+		if previous != nil && *t.elevationMeters > *previous {
+			gain += *t.elevationMeters - *previous
+		}
+		previous = t.elevationMeters
+	}
+	if elevations < 2 {
+		return nil
+	}
+	return &gain
+}
+
+func addFloat(total *float64, v float64) *float64 {
+	if total == nil {
+		return &v
+	}
+	sum := *total + v
+	return &sum
+}
+
+func addInt(total *int, v int) *int {
+	if total == nil {
+		return &v
+	}
+	sum := *total + v
+	return &sum
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tormoder/fit"
+)
+
+var trackStart = time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+
+// testTrackPoint is a point of the test tracks, which run east along the
+// equator 0.001 degrees, about 111.2 m, at a time
+type testTrackPoint struct {
+	seconds   int
+	longitude float64
+	elevation float64
+	heartRate int
+}
+
+var testTrackPoints = []testTrackPoint{
+	{0, 0, 10, 120},
+	{30, 0.001, 15, 140},
+	{60, 0.002, 12, 151},
+}
+
+func testGPX(points []testTrackPoint, timed bool) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+	xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+<trk><trkseg>`)
+	for _, p := range points {
+		fmt.Fprintf(&b, `<trkpt lat="0" lon="%g"><ele>%g</ele>`, p.longitude, p.elevation)
+		if timed {
+			fmt.Fprintf(&b, `<time>%s</time>`, trackStart.Add(time.Duration(p.seconds)*time.Second).Format(time.RFC3339))
+		}
+		fmt.Fprintf(&b, `<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>%d</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>`, p.heartRate)
+	}
+	b.WriteString(`</trkseg></trk></gpx>`)
+	return []byte(b.String())
+}
+
+// testTCX writes a lap per point, with the lap's summary values when summarized
+func testTCX(points []testTrackPoint, summarized bool) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+<Activities><Activity Sport="Running">`)
+	for _, p := range points {
+		timestamp := trackStart.Add(time.Duration(p.seconds) * time.Second).Format(time.RFC3339)
+		fmt.Fprintf(&b, `<Lap StartTime="%s">`, timestamp)
+		if summarized {
+			b.WriteString(`<TotalTimeSeconds>30</TotalTimeSeconds><DistanceMeters>100</DistanceMeters><Calories>10</Calories>`)
+		}
+		fmt.Fprintf(&b, `<Track><Trackpoint><Time>%s</Time>`+
+			`<Position><LatitudeDegrees>0</LatitudeDegrees><LongitudeDegrees>%g</LongitudeDegrees></Position>`+
+			`<AltitudeMeters>%g</AltitudeMeters><HeartRateBpm><Value>%d</Value></HeartRateBpm></Trackpoint></Track></Lap>`,
+			timestamp, p.longitude, p.elevation, p.heartRate)
+	}
+	b.WriteString(`</Activity></Activities></TrainingCenterDatabase>`)
+	return []byte(b.String())
+}
+
+// testFIT encodes an activity file of the points, with a session
+// summarizing them when summarized
+func testFIT(t *testing.T, points []testTrackPoint, summarized bool) []byte {
+	t.Helper()
+
+	file, err := fit.NewFile(fit.FileTypeActivity, fit.NewHeader(fit.V20, false))
+	if err != nil {
+		t.Fatalf("cannot create FIT file: %v", err)
+	}
+	activity, err := file.Activity()
+	if err != nil {
+		t.Fatalf("cannot create FIT activity: %v", err)
+	}
+
+	session := fit.NewSessionMsg()
+	session.Timestamp = trackStart
+	if summarized {
+		session.StartTime = trackStart
+		session.TotalTimerTime = 90 * 1000
+		session.TotalDistance = 500 * 100
+		session.TotalCalories = 40
+		session.TotalAscent = 9
+	}
+	activity.Sessions = append(activity.Sessions, session)
+
+	for _, p := range points {
+		record := fit.NewRecordMsg()
+		record.Timestamp = trackStart.Add(time.Duration(p.seconds) * time.Second)
+		record.PositionLat = fit.NewLatitudeDegrees(0)
+		record.PositionLong = fit.NewLongitudeDegrees(p.longitude)
+		record.Altitude = uint16((p.elevation + 500) * 5)
+		record.HeartRate = uint8(p.heartRate)
+		activity.Records = append(activity.Records, record)
+	}
+
+	var b bytes.Buffer
+	err = fit.Encode(&b, file, binary.LittleEndian)
+	if err != nil {
+		t.Fatalf("cannot encode FIT file: %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestDetectTrackFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"GPX", testGPX(testTrackPoints, true), trackFormatGPX},
+		{"TCX", testTCX(testTrackPoints, true), trackFormatTCX},
+		{"FIT", testFIT(t, testTrackPoints, true), trackFormatFIT},
+		{"other XML", []byte(`<?xml version="1.0"?><kml></kml>`), ""},
+		{"not XML", []byte(`{"type": "FeatureCollection"}`), ""},
+		{"empty", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := detectTrackFormat(test.data)
+			if test.expected == "" {
+				if err == nil {
+					t.Fatalf("expected the format to be unrecognized, got %s", got)
+				}
+				return
+			}
+			if err != nil || got != test.expected {
+				t.Fatalf("expected %s, got %s (%v)", test.expected, got, err)
+			}
+		})
+	}
+}
+
+func TestParseTrack(t *testing.T) {
+	oneStep := 111.195
+	tests := []struct {
+		name           string
+		format         string
+		data           []byte
+		start          time.Time
+		duration       time.Duration
+		distanceMeters *float64
+		caloriesBurned *int
+		avgHeartRate   *int
+		maxHeartRate   *int
+		elevationGain  *float64
+	}{
+		{"GPX is derived from its points", trackFormatGPX, testGPX(testTrackPoints, true),
+			trackStart, time.Minute, floatPointer(2 * oneStep), nil, intPointer(137), intPointer(151), floatPointer(5)},
+		{"GPX of a single point", trackFormatGPX, testGPX(testTrackPoints[:1], true),
+			trackStart, 0, nil, nil, intPointer(120), intPointer(120), nil},
+		{"TCX sums up its laps", trackFormatTCX, testTCX(testTrackPoints, true),
+			trackStart, 90 * time.Second, floatPointer(300), intPointer(30), intPointer(137), intPointer(151), floatPointer(5)},
+		{"TCX without summaries is derived from its points", trackFormatTCX, testTCX(testTrackPoints, false),
+			trackStart, time.Minute, floatPointer(2 * oneStep), nil, intPointer(137), intPointer(151), floatPointer(5)},
+		{"FIT sums up its sessions", trackFormatFIT, testFIT(t, testTrackPoints, true),
+			trackStart, 90 * time.Second, floatPointer(500), intPointer(40), intPointer(137), intPointer(151), floatPointer(9)},
+		{"FIT without summaries is derived from its records", trackFormatFIT, testFIT(t, testTrackPoints, false),
+			trackStart, time.Minute, floatPointer(2 * oneStep), nil, intPointer(137), intPointer(151), floatPointer(5)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			track, err := parseTrack(test.format, test.data)
+			if err != nil {
+				t.Fatalf("cannot parse track: %v", err)
+			}
+			if !track.start.Equal(test.start) || track.duration != test.duration {
+				t.Fatalf("expected %s from %s, got %s from %s", test.duration, test.start, track.duration, track.start)
+			}
+			expectApproximately(t, "distance", track.distanceMeters, test.distanceMeters)
+			expectApproximately(t, "elevation gain", track.elevationGain, test.elevationGain)
+			expectEqualInts(t, "calories burned", track.caloriesBurned, test.caloriesBurned)
+			expectEqualInts(t, "average heart rate", track.avgHeartRate, test.avgHeartRate)
+			expectEqualInts(t, "maximum heart rate", track.maxHeartRate, test.maxHeartRate)
+			for i, p := range track.trackpoints {
+				if p.sequence != i {
+					t.Fatalf("expected trackpoint %d to be numbered in sequence, got %d", i, p.sequence)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTrackInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   []byte
+	}{
+		{"GPX without times", trackFormatGPX, testGPX(testTrackPoints, false)},
+		{"GPX without points", trackFormatGPX, []byte(`<gpx version="1.1"></gpx>`)},
+		{"malformed GPX", trackFormatGPX, []byte(`<gpx><trk>`)},
+		{"TCX without laps", trackFormatTCX, []byte(`<TrainingCenterDatabase><Activities><Activity></Activity></Activities></TrainingCenterDatabase>`)},
+		{"truncated FIT", trackFormatFIT, testFIT(t, testTrackPoints, true)[:20]},
+		{"unsupported format", "kml", testGPX(testTrackPoints, true)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseTrack(test.format, test.data); err == nil {
+				t.Fatalf("expected the track to be rejected")
+			}
+		})
+	}
+}
+
+func TestTrackDerivations(t *testing.T) {
+	point := func(longitude *float64, elevation *float64, heartRate *int) *trackpoint {
+		p := &trackpoint{elevationMeters: elevation, heartRate: heartRate}
+		if longitude != nil {
+			p.latitude, p.longitude = floatPointer(0), longitude
+		}
+		return p
+	}
+
+	tests := []struct {
+		name          string
+		trackpoints   []*trackpoint
+		distance      *float64
+		elevationGain *float64
+		avgHeartRate  *int
+		maxHeartRate  *int
+	}{
+		{"no points", nil, nil, nil, nil, nil},
+		{"points without measurements", []*trackpoint{point(nil, nil, nil), point(nil, nil, nil)}, nil, nil, nil, nil},
+		{"points missing measurements are skipped", []*trackpoint{
+			point(floatPointer(0), floatPointer(100), intPointer(100)),
+			point(nil, nil, nil),
+			point(floatPointer(0.001), floatPointer(110), intPointer(101)),
+		}, floatPointer(111.195), floatPointer(10), intPointer(101), intPointer(101)},
+		{"descents gain no elevation", []*trackpoint{
+			point(nil, floatPointer(100), nil),
+			point(nil, floatPointer(90), nil),
+			point(nil, floatPointer(95), nil),
+			point(nil, floatPointer(80), nil),
+		}, nil, floatPointer(5), nil, nil},
+		{"heart rates average to the nearest beat", []*trackpoint{
+			point(nil, nil, intPointer(150)),
+			point(nil, nil, intPointer(171)),
+			point(nil, nil, intPointer(160)),
+			point(nil, nil, intPointer(152)),
+		}, nil, nil, intPointer(158), intPointer(171)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectApproximately(t, "distance", trackDistance(test.trackpoints), test.distance)
+			expectApproximately(t, "elevation gain", trackElevationGain(test.trackpoints), test.elevationGain)
+			avg, max := trackHeartRate(test.trackpoints)
+			expectEqualInts(t, "average heart rate", avg, test.avgHeartRate)
+			expectEqualInts(t, "maximum heart rate", max, test.maxHeartRate)
+		})
+	}
+}
+
+func intPointer(i int) *int {
+	return &i
+}
+
+// expectApproximately checks got is within a centimeter of expected, or that
+// both are nil
+func expectApproximately(t *testing.T, name string, got *float64, expected *float64) {
+	t.Helper()
+
+	if (got == nil) != (expected == nil) || got != nil && math.Abs(*got-*expected) > 0.01 {
+		t.Fatalf("expected %s %q, got %q", name, formatOptionalFloat(expected), formatOptionalFloat(got))
+	}
+}
+
+func expectEqualInts(t *testing.T, name string, got *int, expected *int) {
+	t.Helper()
+
+	if (got == nil) != (expected == nil) || got != nil && *got != *expected {
+		t.Fatalf("expected %s %q, got %q", name, formatOptionalInt(expected), formatOptionalInt(got))
+	}
+}
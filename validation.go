@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// codes of the ways a field can fail validation, stable for clients to
+// switch on
+const (
+	validationCodeRequired      = "required"
+	validationCodeBlank         = "blank"
+	validationCodeTooShort      = "too_short"
+	validationCodeTooSmall      = "too_small"
+	validationCodeTooLarge      = "too_large"
+	validationCodeInvalidFormat = "invalid_format"
+	validationCodeInvalidValue  = "invalid_value"
+	validationCodeInFuture      = "in_future"
+	validationCodeNotFound      = "not_found"
+)
+
+// maxClockSkew is how far ahead of the server a timestamp may be before it
+// is considered in the future, to allow for clients with a clock running
+// slightly ahead
+const maxClockSkew = 5 * time.Minute
+
+// ValidationError is a single invalid field of a request, named as in JSON
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationErrors are all of the invalid fields of a request
+type validationErrors []ValidationError
+
+func (errs validationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Field + " " + err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validateRequest checks the fields of a request struct against the rules
+// of their validate tags, separated by commas:
+//
+//	required     the field must be given
+//	notblank     a string must not be empty or only whitespace
+//	minlen=N     a string must be at least N characters long
+//	min=N        a number must be at least N
+//	max=N        a number must be at most N
+//	gt=N         a number must be greater than N
+//	oneof=A B    a string must be one of the values separated by spaces
+//	maxitems=N   a list must not have more than N items
+//	rfc3339      a string must be an RFC 3339 timestamp
+//	past         an RFC 3339 timestamp must not be in the future
+//	date         a string must be a calendar day, as in 2006-01-02
+//	timezone     a string must be an IANA time zone name
+//	ltefield=F   a number must not be greater than the field F, if given
+//
+// rules other than required only apply to given fields. fields of embedded
+// structs are checked as if they were the request's own, and the items of
+// lists of structs each as a request of their own, named by their index,
+// as in sets[0].reps. it returns
+// validationErrors listing the first failed rule of every invalid field,
+// or another error if a tag is malformed
+func validateRequest(request interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(request))
+	var errs validationErrors
+	err := validateStruct(v, "", &errs)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *validationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			err := validateStruct(v.Field(i), prefix, errs)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		valid := true
+		if tag := field.Tag.Get("validate"); tag != "" {
+			for _, rule := range strings.Split(tag, ",") {
+				validationErr, err := validateRule(v, prefix, field, rule)
+				if err != nil {
+					return fmt.Errorf("invalid validate tag of %s.%s: %w", t.Name(), field.Name, err)
+				}
+				if validationErr != nil {
+					*errs = append(*errs, *validationErr)
+					valid = false
+					break
+				}
+			}
+		}
+
+		value := v.Field(i)
+		if valid && value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Struct {
+			for j := 0; j < value.Len(); j++ {
+				itemPrefix := fmt.Sprintf("%s%s[%d].", prefix, jsonFieldName(field), j)
+				err := validateStruct(value.Index(j), itemPrefix, errs)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateRule checks a single rule against a field of the struct v, named
+// in errors after prefix
+func validateRule(v reflect.Value, prefix string, field reflect.StructField, rule string) (*ValidationError, error) {
+	name := prefix + jsonFieldName(field)
+	value := v.FieldByIndex(field.Index)
+	invalid := func(code string, format string, args ...interface{}) (*ValidationError, error) {
+		return &ValidationError{
+			Field:   name,
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		}, nil
+	}
+
+	ruleName, param := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		ruleName, param = rule[:i], rule[i+1:]
+	}
+
+	if ruleName == "required" {
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return invalid(validationCodeRequired, "is required")
+		}
+		return nil, nil
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	switch ruleName {
+	case "notblank":
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("%s applies to strings only", ruleName)
+		}
+		if strings.TrimSpace(value.String()) == "" {
+			return invalid(validationCodeBlank, "must not be blank")
+		}
+	case "minlen":
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("%s applies to strings only", ruleName)
+		}
+		length, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid length of %s: %w", ruleName, err)
+		}
+		if utf8.RuneCountInString(value.String()) < length {
+			return invalid(validationCodeTooShort, "must be at least %d characters long", length)
+		}
+	case "oneof":
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("%s applies to strings only", ruleName)
+		}
+		values := strings.Fields(param)
+		for _, allowed := range values {
+			if value.String() == allowed {
+				return nil, nil
+			}
+		}
+		return invalid(validationCodeInvalidValue, "must be one of %s", strings.Join(values, ", "))
+	case "maxitems":
+		if value.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("%s applies to lists only", ruleName)
+		}
+		count, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid count of %s: %w", ruleName, err)
+		}
+		if value.Len() > count {
+			return invalid(validationCodeTooLarge, "must not have more than %d items", count)
+		}
+	case "min", "max", "gt":
+		number, ok := numberValue(value)
+		if !ok {
+			return nil, fmt.Errorf("%s applies to numbers only", ruleName)
+		}
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bound of %s: %w", ruleName, err)
+		}
+		switch {
+		case ruleName == "min" && number < bound:
+			return invalid(validationCodeTooSmall, "must be at least %s", param)
+		case ruleName == "max" && number > bound:
+			return invalid(validationCodeTooLarge, "must be at most %s", param)
+		case ruleName == "gt" && number <= bound:
+			return invalid(validationCodeTooSmall, "must be greater than %s", param)
+		}
+	case "rfc3339", "past":
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("%s applies to strings only", ruleName)
+		}
+		t, err := time.Parse(time.RFC3339, value.String())
+		if err != nil {
+			return invalid(validationCodeInvalidFormat, "must be an RFC 3339 timestamp")
+		}
+		if ruleName == "past" && t.After(time.Now().Add(maxClockSkew)) {
+			return invalid(validationCodeInFuture, "must not be in the future")
+		}
+	case "date":
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("%s applies to strings only", ruleName)
+		}
+		_, err := time.Parse(dateFormat, value.String())
+		if err != nil {
+			return invalid(validationCodeInvalidFormat, "must be formatted as %s", dateFormat)
+		}
+	case "timezone":
+		if value.Kind() != reflect.String {
+			return nil, fmt.Errorf("%s applies to strings only", ruleName)
+		}
+		_, err := loadTimeZone(value.String())
+		if err != nil {
+			return invalid(validationCodeInvalidFormat, "must be an IANA time zone name")
+		}
+	case "ltefield":
+		other, ok := v.Type().FieldByName(param)
+		if !ok {
+			return nil, fmt.Errorf("no field %s to compare with", param)
+		}
+		otherValue := v.FieldByIndex(other.Index)
+		if otherValue.Kind() == reflect.Ptr {
+			if otherValue.IsNil() {
+				return nil, nil
+			}
+			otherValue = otherValue.Elem()
+		}
+		number, ok := numberValue(value)
+		otherNumber, otherOK := numberValue(otherValue)
+		if !ok || !otherOK {
+			return nil, fmt.Errorf("%s applies to numbers only", ruleName)
+		}
+		if number > otherNumber {
+			return invalid(validationCodeTooLarge, "must not be greater than %s", jsonFieldName(other))
+		}
+	default:
+		return nil, fmt.Errorf("unknown rule %s", ruleName)
+	}
+	return nil, nil
+}
+
+// jsonFieldName returns the name of the field in JSON
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// numberValue returns the value of an integer or floating point number
+func numberValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
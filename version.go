@@ -0,0 +1,8 @@
+package main
+
+// build information, injected at link time, see the Makefile
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)